@@ -0,0 +1,191 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"shortgenerator/models"
+	"shortgenerator/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBrandAssetSize limits logo, image and card uploads
+const maxBrandAssetSize = 200 << 20
+
+func ListBrandKitsHandler(brandKitService *services.BrandKitService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kits, err := brandKitService.GetBrandKits()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load brand kits"})
+			return
+		}
+
+		c.JSON(http.StatusOK, kits)
+	}
+}
+
+func GetBrandKitHandler(brandKitService *services.BrandKitService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kit, err := brandKitService.GetBrandKit(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Brand kit not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, kit)
+	}
+}
+
+// CreateBrandKitHandler creates a kit from its settings; assets are uploaded afterwards
+func CreateBrandKitHandler(brandKitService *services.BrandKitService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var kit models.BrandKit
+		if err := c.ShouldBindJSON(&kit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Asset paths only come from uploads
+		kit.LogoPath = ""
+		clearCardAssets(kit.Intro, nil)
+		clearCardAssets(kit.Outro, nil)
+
+		if err := services.ValidateBrandKit(&kit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := brandKitService.CreateBrandKit(&kit); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create brand kit"})
+			return
+		}
+
+		log.Printf("🏷️  Brand kit created: %s (%s)", kit.Name, kit.ID)
+		c.JSON(http.StatusCreated, kit)
+	}
+}
+
+// UpdateBrandKitHandler replaces the settings of a kit. Uploaded assets are kept;
+// they are changed through the asset endpoints.
+func UpdateBrandKitHandler(brandKitService *services.BrandKitService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		existing, err := brandKitService.GetBrandKit(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Brand kit not found"})
+			return
+		}
+
+		var kit models.BrandKit
+		if err := c.ShouldBindJSON(&kit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		kit.ID = existing.ID
+		kit.CreatedAt = existing.CreatedAt
+		kit.LogoPath = existing.LogoPath
+		kit.Intro = keepCardAssets(kit.Intro, existing.Intro)
+		kit.Outro = keepCardAssets(kit.Outro, existing.Outro)
+
+		if err := services.ValidateBrandKit(&kit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := brandKitService.UpdateBrandKit(&kit); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update brand kit"})
+			return
+		}
+
+		c.JSON(http.StatusOK, kit)
+	}
+}
+
+// clearCardAssets replaces the asset paths of a card with those of from (none if nil)
+func clearCardAssets(card, from *models.BrandCard) {
+	if card == nil {
+		return
+	}
+	card.VideoPath, card.ImagePath = "", ""
+	if from != nil {
+		card.VideoPath, card.ImagePath = from.VideoPath, from.ImagePath
+	}
+}
+
+// keepCardAssets carries the uploaded files of a card over to its new settings
+func keepCardAssets(card, existing *models.BrandCard) *models.BrandCard {
+	if card == nil && existing != nil && (existing.VideoPath != "" || existing.ImagePath != "") {
+		card = &models.BrandCard{}
+	}
+	clearCardAssets(card, existing)
+	return card
+}
+
+func DeleteBrandKitHandler(brandKitService *services.BrandKitService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := brandKitService.DeleteBrandKit(c.Param("id")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete brand kit"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Brand kit deleted"})
+	}
+}
+
+// UploadBrandAssetHandler stores a logo, image or card video sent as
+// multipart/form-data (field "file") for the asset kind in the path
+func UploadBrandAssetHandler(brandKitService *services.BrandKitService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kit, err := brandKitService.GetBrandKit(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Brand kit not found"})
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBrandAssetSize)
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+			return
+		}
+
+		kind := c.Param("kind")
+		path, err := brandKitService.AssetPath(kit.ID, kind, file.Filename)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := c.SaveUploadedFile(file, path); err != nil {
+			log.Printf("❌ [%s] Failed to save brand asset: %v", kit.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+			return
+		}
+
+		if err := brandKitService.SetAsset(kit, kind, path); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update brand kit"})
+			return
+		}
+
+		log.Printf("🏷️  [%s] Brand asset %s uploaded: %s", kit.ID, kind, file.Filename)
+		c.JSON(http.StatusOK, kit)
+	}
+}
+
+// DeleteBrandAssetHandler removes an uploaded asset from a kit
+func DeleteBrandAssetHandler(brandKitService *services.BrandKitService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kit, err := brandKitService.GetBrandKit(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Brand kit not found"})
+			return
+		}
+
+		if err := brandKitService.SetAsset(kit, c.Param("kind"), ""); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, kit)
+	}
+}
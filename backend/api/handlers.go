@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"shortgenerator/models"
 	"shortgenerator/services"
 	"strings"
@@ -17,10 +18,11 @@ import (
 	"github.com/google/uuid"
 )
 
-func ProcessVideoHandler(videoService *services.VideoService, processingService *services.ProcessingService) gin.HandlerFunc {
+func ProcessVideoHandler(videoService *services.VideoService, processingService *services.ProcessingService, pipelineService *services.PipelineService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var request struct {
 			URL string `json:"url" binding:"required"`
+			models.AnalysisOptions
 		}
 
 		if err := c.ShouldBindJSON(&request); err != nil {
@@ -28,103 +30,113 @@ func ProcessVideoHandler(videoService *services.VideoService, processingService
 			return
 		}
 
+		if err := validateAnalysisOptions(processingService, &request.AnalysisOptions); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Create video record
-		video, err := videoService.CreateVideo(request.URL)
+		video, err := videoService.CreateVideo(request.URL, models.SourceTypeURL, request.AnalysisOptions)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create video"})
 			return
 		}
 
-		// Start processing in background
-		go func() {
-			// Update status to downloading
-			log.Printf("📥 [%s] Starting download phase", video.ID)
-			video.Status = "downloading"
-			videoService.UpdateVideo(video)
-			BroadcastVideoStatus(video.ID, "downloading")
-
-			// Small delay to ensure WebSocket message is processed
-			time.Sleep(500 * time.Millisecond)
-
-			// Download video
-			log.Printf("⬇️  [%s] Downloading video from URL: %s", video.ID, request.URL)
-			downloadedVideo, err := processingService.DownloadVideo(request.URL, video.ID)
-			if err != nil {
-				log.Printf("❌ [%s] Failed to download video: %v", video.ID, err)
-				video.Status = "error"
-				videoService.UpdateVideo(video)
-				BroadcastVideoStatus(video.ID, "error")
-				return
-			}
+		// Queue download → transcribe → analyze on the job workers
+		pipelineService.Submit(video.ID)
 
-			log.Printf("✅ [%s] Video downloaded successfully: %s", video.ID, downloadedVideo.Title)
+		c.JSON(http.StatusOK, video)
+	}
+}
 
-			video.Title = downloadedVideo.Title
-			video.Duration = downloadedVideo.Duration
-			video.FilePath = downloadedVideo.FilePath
-			video.ThumbnailURL = downloadedVideo.ThumbnailURL
+var languageCodeRe = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
 
-			// Update to transcribing phase
-			log.Printf("📝 [%s] Starting transcription phase", video.ID)
-			video.Status = "transcribing"
-			videoService.UpdateVideo(video)
-			BroadcastVideoStatus(video.ID, "transcribing")
+// validateAnalysisOptions normalizes the per-video prompt options sent at submission
+// and rejects unknown prompt templates before any work is queued
+func validateAnalysisOptions(processingService *services.ProcessingService, options *models.AnalysisOptions) error {
+	options.OutputLanguage = strings.ToLower(strings.TrimSpace(options.OutputLanguage))
+	options.Platform = strings.TrimSpace(options.Platform)
+	options.PromptTemplate = strings.TrimSpace(options.PromptTemplate)
 
-			// Small delay to ensure WebSocket message is processed
-			time.Sleep(300 * time.Millisecond)
+	if options.OutputLanguage != "" && !languageCodeRe.MatchString(options.OutputLanguage) {
+		return fmt.Errorf("invalid output_language %q, expected a code like es, en or pt-br", options.OutputLanguage)
+	}
+	if len(options.Platform) > 64 {
+		return fmt.Errorf("platform is too long")
+	}
+	if options.PromptTemplate != "" {
+		if !strings.HasPrefix(options.PromptTemplate, services.PromptClipAnalysis) {
+			return fmt.Errorf("prompt_template must be a %s template", services.PromptClipAnalysis)
+		}
+		if _, err := processingService.Prompts().Get(options.PromptTemplate); err != nil {
+			return err
+		}
+	}
 
-			// Transcribe video
-			log.Printf("🎤 [%s] Transcribing audio...", video.ID)
-			transcript, err := processingService.TranscribeVideo(video.FilePath, video.ID)
-			if err != nil {
-				log.Printf("❌ [%s] Failed to transcribe video: %v", video.ID, err)
-				video.Status = "error"
-				videoService.UpdateVideo(video)
-				BroadcastVideoStatus(video.ID, "error")
-				return
-			}
+	return nil
+}
 
-			log.Printf("✅ [%s] Transcription completed: %d segments", video.ID, len(transcript.Segments))
+// ListPromptTemplatesHandler lists the prompt templates that can be selected per video
+func ListPromptTemplatesHandler(processingService *services.ProcessingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		templates := []gin.H{}
+		for _, t := range processingService.Prompts().List() {
+			templates = append(templates, gin.H{
+				"name":       t.Name,
+				"version":    t.Version,
+				"version_id": t.VersionID(),
+				"override":   t.Override,
+			})
+		}
 
-			if err := videoService.SaveTranscript(transcript); err != nil {
-				log.Printf("⚠️  [%s] Failed to save transcript: %v", video.ID, err)
-			}
+		c.JSON(http.StatusOK, templates)
+	}
+}
 
-			// Analyze with AI
-			log.Printf("🤖 [%s] Starting AI analysis phase", video.ID)
-			video.Status = "analyzing"
-			videoService.UpdateVideo(video)
-			BroadcastVideoStatus(video.ID, "analyzing")
+// RetryVideoHandler re-queues a failed video, resuming from its last completed stage.
+// Completed videos can be retried while an optional stage (scenes) is not completed.
+func RetryVideoHandler(videoService *services.VideoService, pipelineService *services.PipelineService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
 
-			// Small delay to ensure WebSocket message is processed
-			time.Sleep(300 * time.Millisecond)
+		video, err := videoService.GetVideo(id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			return
+		}
 
-			log.Printf("🔍 [%s] Analyzing transcript for viral clips...", video.ID)
-			suggestedClips, err := processingService.AnalyzeTranscript(transcript, video.ID)
+		if video.Status == "completed" {
+			incomplete, err := pipelineService.IncompleteStages(video)
 			if err != nil {
-				log.Printf("❌ [%s] Failed to analyze transcript: %v", video.ID, err)
-				video.Status = "error"
-				videoService.UpdateVideo(video)
-				BroadcastVideoStatus(video.ID, "error")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load completed stages"})
 				return
 			}
+			if len(incomplete) == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Video already processed"})
+				return
+			}
+		}
 
-			log.Printf("✅ [%s] Analysis completed: %d clips suggested", video.ID, len(suggestedClips))
+		if !pipelineService.Submit(video.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Video is already being processed"})
+			return
+		}
 
-			if err := videoService.SaveSuggestedClips(suggestedClips); err != nil {
-				log.Printf("⚠️  [%s] Failed to save suggested clips: %v", video.ID, err)
-			}
+		c.JSON(http.StatusAccepted, video)
+	}
+}
 
-			// Mark as completed
-			log.Printf("🎉 [%s] All processing completed successfully!", video.ID)
-			video.Status = "completed"
-			videoService.UpdateVideo(video)
-			BroadcastVideoStatus(video.ID, "completed")
+func GetVideoJobsHandler(jobService *services.JobService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
 
-			log.Printf("✅ [%s] Video ready: %s (Duration: %ds, %d clips)", video.ID, video.Title, video.Duration, len(suggestedClips))
-		}()
+		jobs, err := jobService.GetJobsByVideo(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get jobs"})
+			return
+		}
 
-		c.JSON(http.StatusOK, video)
+		c.JSON(http.StatusOK, jobs)
 	}
 }
 
@@ -182,6 +194,26 @@ func GetSuggestedClipsHandler(videoService *services.VideoService) gin.HandlerFu
 	}
 }
 
+// GetScenesHandler returns the shots detected in a video
+func GetScenesHandler(videoService *services.VideoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		if _, err := videoService.GetVideo(id); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			return
+		}
+
+		scenes, err := videoService.GetScenes(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get scenes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, scenes)
+	}
+}
+
 func CreateClipHandler(clipService *services.ClipService, processingService *services.ProcessingService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var clip models.Clip
@@ -207,7 +239,7 @@ func CreateClipHandler(clipService *services.ClipService, processingService *ser
 				FilePath: "", // This should be fetched from DB
 			}
 
-			if err := processingService.CreateClip(video, &clip); err != nil {
+			if err := processingService.CreateClip(video, &clip, nil, nil, nil); err != nil {
 				log.Printf("Failed to process clip: %v", err)
 				clip.Status = "error"
 				clipService.UpdateClip(&clip)
@@ -236,7 +268,32 @@ func GetClipHandler(clipService *services.ClipService) gin.HandlerFunc {
 	}
 }
 
-func ExportClipHandler(videoService *services.VideoService, clipService *services.ClipService, processingService *services.ProcessingService) gin.HandlerFunc {
+// validateRenderOptions normalizes the per-export render options
+func validateRenderOptions(options *models.RenderOptions) error {
+	options.SubtitleRenderer = strings.ToLower(strings.TrimSpace(options.SubtitleRenderer))
+
+	switch options.SubtitleRenderer {
+	case "", models.SubtitleRendererDrawtext, models.SubtitleRendererASS:
+	default:
+		return fmt.Errorf("invalid subtitle_renderer %q, expected %s or %s", options.SubtitleRenderer, models.SubtitleRendererDrawtext, models.SubtitleRendererASS)
+	}
+
+	if err := services.ValidateOutputProfile(options); err != nil {
+		return err
+	}
+	if err := services.ValidateAudioOptions(options); err != nil {
+		return err
+	}
+	if err := services.ValidateMusicOptions(options); err != nil {
+		return err
+	}
+	if err := services.ValidateOverlays(options); err != nil {
+		return err
+	}
+	return services.ValidatePreviewOptions(options)
+}
+
+func ExportClipHandler(videoService *services.VideoService, clipService *services.ClipService, brandKitService *services.BrandKitService, musicService *services.MusicService, renderService *services.RenderService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		videoID := c.Param("id")
 
@@ -246,6 +303,9 @@ func ExportClipHandler(videoService *services.VideoService, clipService *service
 			StartTime float64                 `json:"start_time"`
 			EndTime   float64                 `json:"end_time"`
 			Subtitles []models.SubtitleConfig `json:"subtitles"`
+			Segments  []models.ClipSegment    `json:"segments"` // multi-part clip, replaces start_time/end_time
+
+			models.RenderOptions
 		}
 
 		if err := c.ShouldBindJSON(&request); err != nil {
@@ -253,6 +313,11 @@ func ExportClipHandler(videoService *services.VideoService, clipService *service
 			return
 		}
 
+		if err := validateRenderOptions(&request.RenderOptions); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Get video
 		video, err := videoService.GetVideo(videoID)
 		if err != nil {
@@ -268,11 +333,40 @@ func ExportClipHandler(videoService *services.VideoService, clipService *service
 			return
 		}
 
+		if err := services.ValidateClipSegments(&request.StartTime, &request.EndTime, request.Segments); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if request.BrandKitID != "" {
+			if _, err := brandKitService.GetBrandKit(request.BrandKitID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Brand kit not found"})
+				return
+			}
+		}
+
+		if request.MusicID != "" {
+			track, err := musicService.GetTrack(request.MusicID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Music track not found"})
+				return
+			}
+			if track.Duration > 0 && request.MusicStart >= track.Duration {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("music_start %.2f is past the end of the track (%.2fs)", request.MusicStart, track.Duration)})
+				return
+			}
+		}
+
+		// Exact word timings let the renderer highlight each word when it is spoken
+		if transcript, err := videoService.GetTranscript(videoID); err == nil {
+			services.AttachWordTimings(request.Subtitles, transcript, services.SourceRanges(request.StartTime, request.EndTime, request.Segments))
+		}
+
 		log.Printf("📹 Exporting clip from video: %s, path: %s", videoID, video.FilePath)
-		log.Printf("⏱️  Time range: %.2f - %.2f", request.StartTime, request.EndTime)
+		log.Printf("⏱️  Time range: %.2f - %.2f (%d segments)", request.StartTime, request.EndTime, len(request.Segments))
 		log.Printf("📝 Subtitles count: %d", len(request.Subtitles))
 
-		// Create clip
+		// Create clip, the render queue picks it up from here
 		clip := &models.Clip{
 			ID:        "",
 			VideoID:   videoID,
@@ -280,7 +374,10 @@ func ExportClipHandler(videoService *services.VideoService, clipService *service
 			StartTime: request.StartTime,
 			EndTime:   request.EndTime,
 			Subtitles: request.Subtitles,
-			Status:    "processing",
+			Segments:  request.Segments,
+			Status:    services.ClipStatusQueued,
+
+			RenderOptions: request.RenderOptions,
 		}
 
 		if err := clipService.CreateClip(clip); err != nil {
@@ -288,32 +385,23 @@ func ExportClipHandler(videoService *services.VideoService, clipService *service
 			return
 		}
 
-		// Process clip with subtitles
-		if err := processingService.CreateClip(video, clip); err != nil {
-			log.Printf("Failed to process clip: %v", err)
-			clip.Status = "error"
-			clipService.UpdateClip(clip)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process clip"})
-			return
-		}
+		renderService.Submit(clip.ID)
 
-		// Update clip status
-		if err := clipService.UpdateClip(clip); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update clip"})
-			return
-		}
-
-		log.Printf("✅ Clip exported successfully: %s", clip.ID)
-
-		// Return clip info with download URL
-		c.JSON(http.StatusOK, gin.H{
+		response := gin.H{
 			"id":           clip.ID,
+			"status":       clip.Status,
+			"status_url":   "/api/clips/" + clip.ID,
 			"download_url": "/api/clips/" + clip.ID + "/download",
-			"status":       "completed",
-		})
+		}
+		if clip.Preview != nil {
+			response["preview_url"] = "/api/clips/" + clip.ID + "/download?format=" + clip.Preview.Format
+		}
+		c.JSON(http.StatusAccepted, response)
 	}
 }
 
+// DownloadClipHandler downloads the exported MP4, or its animated preview with
+// ?format=gif or ?format=webp
 func DownloadClipHandler(clipService *services.ClipService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
@@ -329,25 +417,64 @@ func DownloadClipHandler(clipService *services.ClipService) gin.HandlerFunc {
 			return
 		}
 
+		format := strings.ToLower(c.DefaultQuery("format", "mp4"))
+		filePath := clip.FilePath
+		switch format {
+		case "mp4":
+		case models.PreviewGIF, models.PreviewWebP:
+			if clip.Preview == nil || clip.Preview.Format != format || clip.PreviewPath == "" {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Clip has no %s preview", format)})
+				return
+			}
+			filePath = clip.PreviewPath
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected mp4, gif or webp"})
+			return
+		}
+
 		// Force download instead of opening in browser
-		filename := "clip_" + clip.ID + ".mp4"
+		filename := "clip_" + clip.ID + "." + format
 		c.Header("Content-Description", "File Transfer")
 		c.Header("Content-Transfer-Encoding", "binary")
 		c.Header("Content-Disposition", "attachment; filename="+filename)
 		c.Header("Content-Type", "application/octet-stream")
-		c.File(clip.FilePath)
+		c.File(filePath)
 	}
 }
 
-func DeleteClipHandler(clipService *services.ClipService) gin.HandlerFunc {
+// DeleteClipHandler deletes a clip with its thumbnails and animated preview. Clips
+// that are queued or rendering cannot be deleted until the render ends.
+func DeleteClipHandler(clipService *services.ClipService, thumbnailService *services.ThumbnailService, renderService *services.RenderService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 
+		clip, err := clipService.GetClip(id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Clip not found"})
+			return
+		}
+
+		// Only new clips are queued, so a clip that is not active now stays that way
+		if renderService.IsActive(clip.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Clip is being rendered", "status": clip.Status})
+			return
+		}
+
 		if err := clipService.DeleteClip(id); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete clip"})
 			return
 		}
 
+		// The clip is gone either way; leftover thumbnails are only logged
+		if err := thumbnailService.DeleteClipThumbnails(clip); err != nil {
+			log.Printf("⚠️  [%s] Failed to delete clip thumbnails: %v", clip.ID, err)
+		}
+		if clip.PreviewPath != "" {
+			if err := os.Remove(clip.PreviewPath); err != nil && !os.IsNotExist(err) {
+				log.Printf("⚠️  [%s] Failed to delete clip preview: %v", clip.ID, err)
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{"message": "Clip deleted"})
 	}
 }
@@ -380,6 +507,8 @@ func ExtractClipOnlyHandler(videoService *services.VideoService, processingServi
 		var request struct {
 			StartTime float64 `json:"start_time" binding:"required"`
 			EndTime   float64 `json:"end_time" binding:"required"`
+
+			models.RenderOptions
 		}
 
 		if err := c.ShouldBindJSON(&request); err != nil {
@@ -387,6 +516,11 @@ func ExtractClipOnlyHandler(videoService *services.VideoService, processingServi
 			return
 		}
 
+		if err := validateRenderOptions(&request.RenderOptions); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Validar tiempos
 		if request.StartTime < 0 || request.EndTime <= request.StartTime {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time range"})
@@ -411,7 +545,7 @@ func ExtractClipOnlyHandler(videoService *services.VideoService, processingServi
 		log.Printf("⏱️  Time range: %.2f - %.2f (duration: %.2f)", request.StartTime, request.EndTime, request.EndTime-request.StartTime)
 
 		// Extraer clip sin subtítulos
-		clipPath, err := processingService.ExtractClipOnly(video.FilePath, videoID, request.StartTime, request.EndTime)
+		clipPath, err := processingService.ExtractClipOnly(video.FilePath, videoID, request.StartTime, request.EndTime, request.RenderOptions)
 		if err != nil {
 			log.Printf("❌ Failed to extract clip: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to extract clip"})
@@ -515,8 +649,8 @@ func ConvertWebMToMP4(processingService *services.ProcessingService) gin.Handler
 	}
 }
 
-// GenerateYouTubeSEOHandler generates professional SEO content using DeepSeek with Redis cache
-func GenerateYouTubeSEOHandler(videoService *services.VideoService, cacheService *services.CacheService) gin.HandlerFunc {
+// GenerateYouTubeSEOHandler generates professional SEO content with the LLM provider and Redis cache
+func GenerateYouTubeSEOHandler(videoService *services.VideoService, processingService *services.ProcessingService, cacheService *services.CacheService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var request struct {
 			VideoID       string  `json:"video_id" binding:"required"`
@@ -579,16 +713,8 @@ func GenerateYouTubeSEOHandler(videoService *services.VideoService, cacheService
 				request.VideoID, segmentsFound, len(transcriptText))
 		}
 
-		// Call DeepSeek API to generate professional SEO content
-		deepseekAPIKey := os.Getenv("DEEPSEEK_API_KEY")
-		if deepseekAPIKey == "" {
-			log.Printf("❌ DEEPSEEK_API_KEY not configured")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "DeepSeek API key not configured"})
-			return
-		}
-
-		log.Printf("🤖 [%s] Calling DeepSeek API for SEO generation...", request.VideoID)
-		seoContent, err := services.GenerateProfessionalSEO(deepseekAPIKey, transcriptText, request.ClipTitle, video.Title)
+		log.Printf("🤖 [%s] Calling LLM provider for SEO generation...", request.VideoID)
+		seoContent, err := processingService.GenerateSEO(video, transcriptText, request.ClipTitle)
 		if err != nil {
 			log.Printf("❌ [%s] Failed to generate SEO: %v", request.VideoID, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate SEO content"})
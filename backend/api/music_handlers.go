@@ -0,0 +1,114 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"shortgenerator/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxMusicSize limits music uploads
+const maxMusicSize = 200 << 20
+
+func ListMusicHandler(musicService *services.MusicService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tracks, err := musicService.GetTracks()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load music tracks"})
+			return
+		}
+
+		c.JSON(http.StatusOK, tracks)
+	}
+}
+
+func GetMusicHandler(musicService *services.MusicService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		track, err := musicService.GetTrack(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Music track not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, track)
+	}
+}
+
+// UploadMusicHandler stores a music track sent as multipart/form-data (field "file",
+// "name" optional) after checking with ffprobe that it has audio
+func UploadMusicHandler(musicService *services.MusicService, processingService *services.ProcessingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxMusicSize)
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+			return
+		}
+
+		if !services.IsSupportedMusic(file.Filename) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported audio format"})
+			return
+		}
+
+		track, err := musicService.NewTrack(c.PostForm("name"), file.Filename)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create music track"})
+			return
+		}
+
+		if err := c.SaveUploadedFile(file, track.FilePath); err != nil {
+			log.Printf("❌ [%s] Failed to save music track: %v", track.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+			return
+		}
+
+		info, err := processingService.ProbeMedia(track.FilePath)
+		if err != nil || !info.HasAudio {
+			os.Remove(track.FilePath)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File has no readable audio"})
+			return
+		}
+		track.Duration = info.Duration
+
+		if err := musicService.CreateTrack(track); err != nil {
+			os.Remove(track.FilePath)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create music track"})
+			return
+		}
+
+		log.Printf("🎵 Music track uploaded: %s (%.1fs)", track.Name, track.Duration)
+		c.JSON(http.StatusCreated, track)
+	}
+}
+
+func StreamMusicHandler(musicService *services.MusicService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		track, err := musicService.GetTrack(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Music track not found"})
+			return
+		}
+
+		c.File(track.FilePath)
+	}
+}
+
+func DeleteMusicHandler(musicService *services.MusicService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		track, err := musicService.GetTrack(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Music track not found"})
+			return
+		}
+
+		if err := musicService.DeleteTrack(track); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete music track"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Music track deleted"})
+	}
+}
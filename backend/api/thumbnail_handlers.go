@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"shortgenerator/models"
+	"shortgenerator/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GenerateThumbnailsHandler scores the frames of a clip (clip_id) or of a range of
+// the video (start_time, end_time) and returns the best cover candidates
+func GenerateThumbnailsHandler(videoService *services.VideoService, clipService *services.ClipService, thumbnailService *services.ThumbnailService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		videoID := c.Param("id")
+
+		var request struct {
+			ClipID    string               `json:"clip_id"`
+			StartTime float64              `json:"start_time"`
+			EndTime   float64              `json:"end_time"`
+			Segments  []models.ClipSegment `json:"segments"`
+			Count     int                  `json:"count"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if request.Count == 0 {
+			request.Count = services.DefaultThumbnailCount
+		}
+		if request.Count < 1 || request.Count > services.MaxThumbnailCount {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("count must be between 1 and %d", services.MaxThumbnailCount)})
+			return
+		}
+
+		video, err := videoService.GetVideo(videoID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			return
+		}
+		if video.FilePath == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Video file not available"})
+			return
+		}
+
+		// A stored clip brings its own ranges
+		if request.ClipID != "" {
+			clip, err := clipService.GetClip(request.ClipID)
+			if err != nil || clip.VideoID != videoID {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Clip not found"})
+				return
+			}
+			request.StartTime, request.EndTime, request.Segments = clip.StartTime, clip.EndTime, clip.Segments
+		}
+
+		if err := services.ValidateClipSegments(&request.StartTime, &request.EndTime, request.Segments); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ranges := services.SourceRanges(request.StartTime, request.EndTime, request.Segments)
+		thumbs, err := thumbnailService.GenerateCandidates(video, request.ClipID, ranges, request.Count)
+		if err != nil {
+			log.Printf("❌ [%s] Failed to generate thumbnails: %v", videoID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate thumbnails"})
+			return
+		}
+
+		c.JSON(http.StatusOK, thumbs)
+	}
+}
+
+// ListThumbnailsHandler lists the candidates and covers of a video, or of one clip
+// with ?clip_id=
+func ListThumbnailsHandler(thumbnailService *services.ThumbnailService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		thumbs, err := thumbnailService.GetThumbnails(c.Param("id"), c.Query("clip_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load thumbnails"})
+			return
+		}
+
+		c.JSON(http.StatusOK, thumbs)
+	}
+}
+
+// SelectThumbnailHandler makes a candidate the cover of its clip and renders the
+// covers, with the optional title (e.g. the SEO title) drawn on them
+func SelectThumbnailHandler(thumbnailService *services.ThumbnailService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Title string `json:"title"`
+		}
+
+		// The body is optional, covers are rendered without a title
+		if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		candidate, err := thumbnailService.GetThumbnail(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not found"})
+			return
+		}
+		if candidate.Kind != models.ThumbnailCandidate {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only candidates can be selected"})
+			return
+		}
+
+		covers, err := thumbnailService.SelectCandidate(candidate, request.Title)
+		if err != nil {
+			log.Printf("❌ [%s] Failed to render covers: %v", candidate.VideoID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render covers"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"selected": candidate,
+			"covers":   covers,
+		})
+	}
+}
+
+func DownloadThumbnailHandler(thumbnailService *services.ThumbnailService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		thumb, err := thumbnailService.GetThumbnail(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not found"})
+			return
+		}
+
+		if c.Query("download") != "" {
+			c.FileAttachment(thumb.FilePath, "thumbnail_"+thumb.ID+".jpg")
+			return
+		}
+		c.File(thumb.FilePath)
+	}
+}
+
+// ClipThumbnailHandler serves the vertical cover of a clip
+func ClipThumbnailHandler(thumbnailService *services.ThumbnailService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		thumb, err := thumbnailService.ClipCover(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not found"})
+			return
+		}
+
+		c.File(thumb.FilePath)
+	}
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"shortgenerator/models"
+	"shortgenerator/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadVideoHandler ingests a local video sent as multipart/form-data (field "video")
+func UploadVideoHandler(videoService *services.VideoService, processingService *services.ProcessingService, uploadService *services.UploadService, pipelineService *services.PipelineService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, uploadService.MaxSize())
+
+		file, err := c.FormFile("video")
+		if err != nil {
+			log.Printf("❌ Failed to read uploaded file: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No video file provided"})
+			return
+		}
+
+		if !services.IsSupportedUpload(file.Filename) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported video format"})
+			return
+		}
+
+		options := models.AnalysisOptions{
+			OutputLanguage: c.PostForm("output_language"),
+			Platform:       c.PostForm("platform"),
+			PromptTemplate: c.PostForm("prompt_template"),
+		}
+		if err := validateAnalysisOptions(processingService, &options); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		log.Printf("📥 Received upload: %s (%.2f MB)", file.Filename, float64(file.Size)/1024/1024)
+
+		video, err := videoService.CreateVideo(filepath.Base(file.Filename), models.SourceTypeUpload, options)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create video"})
+			return
+		}
+
+		video.FilePath = processingService.VideoStoragePath(video.ID, filepath.Ext(file.Filename))
+		if err := c.SaveUploadedFile(file, video.FilePath); err != nil {
+			log.Printf("❌ [%s] Failed to save uploaded file: %v", video.ID, err)
+			video.Status = "error"
+			videoService.UpdateVideo(video)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+			return
+		}
+
+		video.Title = strings.TrimSpace(c.PostForm("title"))
+		if err := videoService.UpdateVideo(video); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update video"})
+			return
+		}
+
+		pipelineService.Submit(video.ID)
+
+		c.JSON(http.StatusOK, video)
+	}
+}
+
+// CreateUploadHandler starts a resumable upload session
+func CreateUploadHandler(processingService *services.ProcessingService, uploadService *services.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Filename string `json:"filename" binding:"required"`
+			Title    string `json:"title"`
+			Size     int64  `json:"size" binding:"required"`
+			models.AnalysisOptions
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validateAnalysisOptions(processingService, &request.AnalysisOptions); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		session, err := uploadService.CreateSession(request.Filename, request.Title, request.Size, request.AnalysisOptions)
+		if errors.Is(err, services.ErrUploadTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		log.Printf("📤 Upload session %s started: %s (%.2f MB)", session.ID, session.Filename, float64(session.Size)/1024/1024)
+		c.JSON(http.StatusCreated, session)
+	}
+}
+
+// GetUploadHandler returns the session so clients know which offset to resume from
+func GetUploadHandler(uploadService *services.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, err := uploadService.GetSession(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+			return
+		}
+
+		c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// UploadChunkHandler appends the raw request body at the offset given in the
+// Upload-Offset header. Once the last byte arrives the video enters the pipeline.
+func UploadChunkHandler(videoService *services.VideoService, processingService *services.ProcessingService, uploadService *services.UploadService, pipelineService *services.PipelineService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid Upload-Offset header"})
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, uploadService.MaxSize())
+
+		var tooLarge *http.MaxBytesError
+		session, err := uploadService.AppendChunk(id, offset, c.Request.Body)
+		switch {
+		case errors.Is(err, services.ErrUploadNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+			return
+		case errors.Is(err, services.ErrUploadOffsetMismatch):
+			c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+			c.JSON(http.StatusConflict, gin.H{"error": "Offset mismatch", "offset": session.Offset})
+			return
+		case errors.Is(err, services.ErrUploadTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Chunk exceeds declared size"})
+			return
+		case errors.As(err, &tooLarge):
+			// Bytes up to the limit were written; the client resumes from there
+			c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Chunk exceeds %d bytes", tooLarge.Limit), "offset": session.Offset})
+			return
+		case err != nil && session == nil:
+			// The session could not be read, so there is no offset to resume from
+			log.Printf("⚠️  Upload %s chunk failed: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write chunk"})
+			return
+		case err != nil:
+			// Partial writes are kept; the client resumes from the reported offset
+			log.Printf("⚠️  Upload %s chunk failed at %d bytes: %v", id, session.Offset, err)
+			c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write chunk", "offset": session.Offset})
+			return
+		}
+
+		c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+
+		if !session.Complete() {
+			c.JSON(http.StatusOK, session)
+			return
+		}
+
+		// Only one request creates the video; a retried last chunk gets it back
+		var video *models.Video
+		session, created, err := uploadService.Finalize(id, func(session *services.UploadSession) (string, string, error) {
+			v, err := videoService.CreateVideo(session.Filename, models.SourceTypeUpload, session.Options)
+			if err != nil {
+				return "", "", fmt.Errorf("failed to create video: %v", err)
+			}
+			video = v
+
+			video.FilePath = processingService.VideoStoragePath(video.ID, filepath.Ext(session.Filename))
+			video.Title = strings.TrimSpace(session.Title)
+			if err := videoService.UpdateVideo(video); err != nil {
+				return "", "", fmt.Errorf("failed to update video: %v", err)
+			}
+			return video.ID, video.FilePath, nil
+		})
+		switch {
+		case err != nil && !created:
+			log.Printf("❌ Failed to finalize upload %s: %v", id, err)
+			if video != nil {
+				video.Status = "error"
+				videoService.UpdateVideo(video)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to finalize upload"})
+			return
+		case err != nil:
+			// The video exists and owns the file; only a retried chunk cannot find it
+			log.Printf("⚠️  [%s] Upload %s: %v", video.ID, id, err)
+		}
+
+		if !created {
+			video, err = videoService.GetVideo(session.VideoID)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"upload": session,
+				"video":  video,
+			})
+			return
+		}
+
+		log.Printf("✅ [%s] Upload %s completed: %s", video.ID, id, session.Filename)
+		pipelineService.Submit(video.ID)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"upload": session,
+			"video":  video,
+		})
+	}
+}
+
+// CancelUploadHandler discards a resumable upload
+func CancelUploadHandler(uploadService *services.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := uploadService.Cancel(c.Param("id")); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Upload cancelled"})
+	}
+}
+
+// VideoThumbnailHandler serves the locally extracted poster frame of uploaded videos
+func VideoThumbnailHandler(processingService *services.ProcessingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := processingService.ThumbnailPath(c.Param("id"))
+		if _, err := os.Stat(path); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not found"})
+			return
+		}
+
+		c.File(path)
+	}
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"log"
 	"net/http"
+	"shortgenerator/models"
+	"shortgenerator/services"
 	"sync"
 
 	"github.com/gin-gonic/gin"
@@ -73,6 +75,43 @@ func VideoWebSocketHandler() gin.HandlerFunc {
 
 // BroadcastVideoStatus sends status updates to all clients watching a specific video
 func BroadcastVideoStatus(videoID string, status string) {
+	broadcast(videoID, WSMessage{
+		Type:   "status",
+		Status: status,
+	})
+}
+
+// BroadcastClipStatus tells the clients watching the clip's video how its render is going
+func BroadcastClipStatus(clip *models.Clip) {
+	payload := gin.H{
+		"clip_id": clip.ID,
+		"title":   clip.Title,
+	}
+	if clip.Status == services.ClipStatusCompleted {
+		payload["download_url"] = "/api/clips/" + clip.ID + "/download"
+	}
+	if clip.Error != "" {
+		payload["error"] = clip.Error
+	}
+
+	broadcast(clip.VideoID, WSMessage{
+		Type:    "clip",
+		Status:  clip.Status,
+		Payload: payload,
+	})
+}
+
+// BroadcastProgress sends download, audio extraction, transcription and render
+// progress to all clients watching the update's video
+func BroadcastProgress(update services.ProgressUpdate) {
+	broadcast(update.VideoID, WSMessage{
+		Type:    "progress",
+		Payload: update,
+	})
+}
+
+// broadcast writes msg to every client watching videoID, dropping broken connections
+func broadcast(videoID string, msg WSMessage) {
 	wsManager.mu.RLock()
 	clients := wsManager.clients[videoID]
 	wsManager.mu.RUnlock()
@@ -81,11 +120,6 @@ func BroadcastVideoStatus(videoID string, status string) {
 		return
 	}
 
-	msg := WSMessage{
-		Type:   "status",
-		Status: status,
-	}
-
 	wsManager.mu.Lock()
 	defer wsManager.mu.Unlock()
 
@@ -102,8 +136,3 @@ func BroadcastVideoStatus(videoID string, status string) {
 		delete(wsManager.clients, videoID)
 	}
 }
-
-// BroadcastProgress sends progress updates to all clients watching a specific video
-func BroadcastProgress(videoID string, status string, progress int, message string) {
-	BroadcastVideoStatus(videoID, status)
-}
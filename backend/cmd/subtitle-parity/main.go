@@ -0,0 +1,97 @@
+// subtitle-parity renders reference frames of every editor subtitle template with
+// both backend renderers, at several points of the entrance transition, so they can
+// be compared side by side with the editor preview. It is a visual helper only: the
+// filters and ASS events themselves are checked against the editor in
+// services/subtitle_parity_test.go.
+//
+//	go run ./cmd/subtitle-parity -out ../storage/parity
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"shortgenerator/models"
+	"shortgenerator/services"
+
+	"github.com/joho/godotenv"
+)
+
+// Frame times in seconds after the subtitle appears: start, mid-transition, settled
+var frameTimes = []float64{0.1, 0.3, 1.5}
+
+func main() {
+	templatesPath := flag.String("templates", "../frontend/src/lib/data/templates.ts", "editor templates file")
+	outDir := flag.String("out", "parity", "output directory for the frames")
+	text := flag.String("text", "Every template, frame by frame", "subtitle text")
+	italic := flag.Bool("italic", false, "render the italic variant")
+	only := flag.String("template", "", "render only the template with this name")
+	aspectRatio := flag.String("aspect", "9:16", "output aspect ratio (9:16, 4:5, 1:1 or 16:9)")
+	flag.Parse()
+
+	profile := models.RenderOptions{AspectRatio: *aspectRatio}
+	if err := services.ValidateOutputProfile(&profile); err != nil {
+		log.Fatalf("Invalid output profile: %v", err)
+	}
+
+	if err := godotenv.Load("../.env"); err != nil {
+		log.Println("No .env file found, using system environment variables")
+	}
+
+	templates, err := services.LoadEditorTemplates(*templatesPath)
+	if err != nil {
+		log.Fatalf("Failed to load templates: %v", err)
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+
+	processingService := services.NewProcessingService()
+	renderers := []string{models.SubtitleRendererDrawtext, models.SubtitleRendererASS}
+
+	failed := 0
+	for _, tpl := range templates {
+		if *only != "" && !strings.EqualFold(tpl.Name, *only) {
+			continue
+		}
+
+		// Shown long enough for every frame time
+		subtitles := []models.SubtitleConfig{tpl.Subtitle(*text, *italic, 0, frameTimes[len(frameTimes)-1]+0.5)}
+		for _, renderer := range renderers {
+			for _, at := range frameTimes {
+				name := fmt.Sprintf("%s_%s_%04dms.png", slug(tpl.Name), renderer, int(at*1000))
+				path := filepath.Join(*outDir, name)
+				options := profile
+				options.SubtitleRenderer = renderer
+				if err := processingService.RenderSubtitleFrame(subtitles, options, at, path); err != nil {
+					log.Printf("❌ %s (%s @ %.1fs): %v", tpl.Name, renderer, at, err)
+					failed++
+					continue
+				}
+			}
+		}
+		log.Printf("✅ %s (%s)", tpl.Name, tpl.Settings.Transition)
+	}
+
+	if failed > 0 {
+		log.Fatalf("%d frames failed", failed)
+	}
+	log.Printf("🖼️  Frames written to %s", *outDir)
+}
+
+func slug(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
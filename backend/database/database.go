@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -45,6 +46,7 @@ func createTables(db *sql.DB) error {
 	CREATE TABLE IF NOT EXISTS videos (
 		id TEXT PRIMARY KEY,
 		url TEXT NOT NULL,
+		source_type TEXT DEFAULT 'url',
 		title TEXT,
 		duration INTEGER,
 		file_path TEXT,
@@ -93,6 +95,7 @@ func createTables(db *sql.DB) error {
 
 	CREATE TABLE IF NOT EXISTS processing_jobs (
 		id TEXT PRIMARY KEY,
+		video_id TEXT,
 		type TEXT NOT NULL,
 		status TEXT DEFAULT 'pending',
 		progress INTEGER DEFAULT 0,
@@ -101,11 +104,129 @@ func createTables(db *sql.DB) error {
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 
+	CREATE TABLE IF NOT EXISTS scenes (
+		video_id TEXT NOT NULL,
+		idx INTEGER NOT NULL,
+		start_time REAL NOT NULL,
+		end_time REAL NOT NULL,
+		score REAL,
+		PRIMARY KEY (video_id, idx),
+		FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS brand_kits (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		logo_path TEXT DEFAULT '',
+		logo_position TEXT DEFAULT 'top_right',
+		logo_opacity REAL DEFAULT 0.9,
+		logo_width REAL DEFAULT 0.18,
+		intro TEXT DEFAULT 'null', -- JSON models.BrandCard
+		outro TEXT DEFAULT 'null', -- JSON models.BrandCard
+		safe_zone TEXT DEFAULT '{}', -- JSON models.SafeZone
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS music_tracks (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		file_path TEXT NOT NULL,
+		duration REAL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS thumbnails (
+		id TEXT PRIMARY KEY,
+		video_id TEXT NOT NULL,
+		clip_id TEXT DEFAULT '',
+		kind TEXT NOT NULL,
+		time REAL DEFAULT 0,
+		score REAL DEFAULT 0,
+		width INTEGER DEFAULT 0,
+		height INTEGER DEFAULT 0,
+		title TEXT DEFAULT '',
+		source_id TEXT DEFAULT '',
+		selected INTEGER DEFAULT 0,
+		file_path TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_videos_status ON videos(status);
 	CREATE INDEX IF NOT EXISTS idx_clips_video_id ON clips(video_id);
+	CREATE INDEX IF NOT EXISTS idx_thumbnails_video_id ON thumbnails(video_id, clip_id);
 	CREATE INDEX IF NOT EXISTS idx_transcripts_video_id ON transcripts(video_id);
 	`
 
-	_, err := db.Exec(schema)
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	return migrate(db)
+}
+
+// migrate adds columns introduced after the initial schema to existing databases
+func migrate(db *sql.DB) error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"processing_jobs", "video_id", "TEXT"},
+		{"videos", "source_type", "TEXT DEFAULT 'url'"},
+		{"videos", "output_language", "TEXT DEFAULT ''"},
+		{"videos", "platform", "TEXT DEFAULT ''"},
+		{"videos", "prompt_template", "TEXT DEFAULT ''"},
+		{"suggested_clips", "prompt_version", "TEXT DEFAULT ''"},
+		{"clips", "error_message", "TEXT DEFAULT ''"},
+		{"clips", "render_options", "TEXT DEFAULT '{}'"},     // JSON models.RenderOptions
+		{"clips", "segments", "TEXT DEFAULT '[]'"},           // JSON []models.ClipSegment
+		{"suggested_clips", "segments", "TEXT DEFAULT '[]'"}, // JSON []models.ClipSegment
+		{"clips", "preview_path", "TEXT DEFAULT ''"},
+	}
+
+	for _, c := range columns {
+		if err := addColumnIfMissing(db, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_processing_jobs_video_id ON processing_jobs(video_id);`)
+	return err
+}
+
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err == nil {
+		log.Printf("🔧 Migrated %s: added column %s", table, column)
+	}
 	return err
 }
@@ -30,8 +30,30 @@ func main() {
 	videoService := services.NewVideoService(db)
 	clipService := services.NewClipService(db)
 	processingService := services.NewProcessingService()
+	processingService.SetProgressNotifier(api.BroadcastProgress)
 	cacheService := services.NewCacheService()
 	defer cacheService.Close()
+	jobService := services.NewJobService(db)
+	uploadService := services.NewUploadService(processingService.StoragePath())
+	brandKitService := services.NewBrandKitService(db, processingService.StoragePath())
+	musicService := services.NewMusicService(db, processingService.StoragePath())
+	thumbnailService := services.NewThumbnailService(db, processingService)
+
+	// Background pipeline: bounded by MAX_CONCURRENT_JOBS and resumed after restarts
+	pipelineService := services.NewPipelineService(videoService, jobService, processingService)
+	pipelineService.SetNotifier(api.BroadcastVideoStatus)
+	pipelineService.Start()
+	if err := pipelineService.Recover(); err != nil {
+		log.Printf("⚠️  Failed to recover unfinished videos: %v", err)
+	}
+
+	// Clip exports render in the background, at most MAX_PARALLEL_RENDERS at a time
+	renderService := services.NewRenderService(videoService, clipService, processingService, brandKitService, musicService)
+	renderService.SetNotifier(api.BroadcastClipStatus)
+	renderService.Start()
+	if err := renderService.Recover(); err != nil {
+		log.Printf("⚠️  Failed to recover unfinished renders: %v", err)
+	}
 
 	// Setup Gin router
 	router := gin.Default()
@@ -39,8 +61,9 @@ func main() {
 	// CORS configuration
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"http://localhost:5173", "http://localhost:4173"}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept"}
+	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Upload-Offset"}
+	config.ExposeHeaders = []string{"Upload-Offset"}
 	router.Use(cors.New(config))
 
 	// Health check
@@ -52,12 +75,26 @@ func main() {
 	apiRouter := router.Group("/api")
 	{
 		// Videos
-		apiRouter.POST("/videos", api.ProcessVideoHandler(videoService, processingService))
+		apiRouter.POST("/videos", api.ProcessVideoHandler(videoService, processingService, pipelineService))
+		apiRouter.POST("/videos/upload", api.UploadVideoHandler(videoService, processingService, uploadService, pipelineService))
 		apiRouter.GET("/videos", api.GetVideosHandler(videoService))
 		apiRouter.GET("/videos/:id", api.GetVideoHandler(videoService))
 		apiRouter.GET("/videos/:id/stream", api.StreamVideoHandler(videoService))
+		apiRouter.GET("/videos/:id/thumbnail", api.VideoThumbnailHandler(processingService))
 		apiRouter.GET("/videos/:id/transcript", api.GetTranscriptHandler(videoService))
 		apiRouter.GET("/videos/:id/clips", api.GetSuggestedClipsHandler(videoService))
+		apiRouter.GET("/videos/:id/scenes", api.GetScenesHandler(videoService))
+		apiRouter.GET("/videos/:id/jobs", api.GetVideoJobsHandler(jobService))
+		apiRouter.POST("/videos/:id/thumbnails", api.GenerateThumbnailsHandler(videoService, clipService, thumbnailService))
+		apiRouter.GET("/videos/:id/thumbnails", api.ListThumbnailsHandler(thumbnailService))
+		apiRouter.POST("/videos/:id/retry", api.RetryVideoHandler(videoService, pipelineService))
+		apiRouter.GET("/prompts", api.ListPromptTemplatesHandler(processingService))
+
+		// Resumable uploads for large local files
+		apiRouter.POST("/uploads", api.CreateUploadHandler(processingService, uploadService))
+		apiRouter.GET("/uploads/:id", api.GetUploadHandler(uploadService))
+		apiRouter.PATCH("/uploads/:id", api.UploadChunkHandler(videoService, processingService, uploadService, pipelineService))
+		apiRouter.DELETE("/uploads/:id", api.CancelUploadHandler(uploadService))
 
 		// NEW: Extract raw clip without subtitles (frontend will handle rendering)
 		apiRouter.POST("/videos/:id/extract-clip", api.ExtractClipOnlyHandler(videoService, processingService))
@@ -65,15 +102,36 @@ func main() {
 		// NEW: Convert WebM to MP4 using native FFmpeg
 		apiRouter.POST("/convert-webm-to-mp4", api.ConvertWebMToMP4(processingService))
 
-		// NEW: Generate professional YouTube SEO using DeepSeek (with Redis cache)
-		apiRouter.POST("/videos/:id/generate-seo", api.GenerateYouTubeSEOHandler(videoService, cacheService))
+		// NEW: Generate professional YouTube SEO with the LLM provider (with Redis cache)
+		apiRouter.POST("/videos/:id/generate-seo", api.GenerateYouTubeSEOHandler(videoService, processingService, cacheService))
 
 		// Clips (legacy - con subtítulos procesados en backend)
 		apiRouter.POST("/clips", api.CreateClipHandler(clipService, processingService))
-		apiRouter.POST("/clips/:id/export", api.ExportClipHandler(videoService, clipService, processingService))
+		apiRouter.POST("/clips/:id/export", api.ExportClipHandler(videoService, clipService, brandKitService, musicService, renderService))
 		apiRouter.GET("/clips/:id", api.GetClipHandler(clipService))
 		apiRouter.GET("/clips/:id/download", api.DownloadClipHandler(clipService))
-		apiRouter.DELETE("/clips/:id", api.DeleteClipHandler(clipService))
+		apiRouter.GET("/clips/:id/thumbnail", api.ClipThumbnailHandler(thumbnailService))
+		apiRouter.DELETE("/clips/:id", api.DeleteClipHandler(clipService, thumbnailService, renderService))
+
+		// Cover candidates and rendered covers
+		apiRouter.POST("/thumbnails/:id/select", api.SelectThumbnailHandler(thumbnailService))
+		apiRouter.GET("/thumbnails/:id/download", api.DownloadThumbnailHandler(thumbnailService))
+
+		// Brand kits: logo watermark, intro/outro cards and safe zone, selected per export
+		apiRouter.GET("/brand-kits", api.ListBrandKitsHandler(brandKitService))
+		apiRouter.POST("/brand-kits", api.CreateBrandKitHandler(brandKitService))
+		apiRouter.GET("/brand-kits/:id", api.GetBrandKitHandler(brandKitService))
+		apiRouter.PUT("/brand-kits/:id", api.UpdateBrandKitHandler(brandKitService))
+		apiRouter.DELETE("/brand-kits/:id", api.DeleteBrandKitHandler(brandKitService))
+		apiRouter.POST("/brand-kits/:id/assets/:kind", api.UploadBrandAssetHandler(brandKitService))
+		apiRouter.DELETE("/brand-kits/:id/assets/:kind", api.DeleteBrandAssetHandler(brandKitService))
+
+		// Music tracks for the background bed of exports
+		apiRouter.GET("/music", api.ListMusicHandler(musicService))
+		apiRouter.POST("/music", api.UploadMusicHandler(musicService, processingService))
+		apiRouter.GET("/music/:id", api.GetMusicHandler(musicService))
+		apiRouter.GET("/music/:id/stream", api.StreamMusicHandler(musicService))
+		apiRouter.DELETE("/music/:id", api.DeleteMusicHandler(musicService))
 
 		// WebSocket for progress updates (video-specific)
 		apiRouter.GET("/videos/:id/ws", api.VideoWebSocketHandler())
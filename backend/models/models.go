@@ -2,9 +2,155 @@ package models
 
 import "time"
 
+// Video source types
+const (
+	SourceTypeURL    = "url"    // downloaded with yt-dlp
+	SourceTypeUpload = "upload" // uploaded from a local file
+)
+
+// AnalysisOptions are chosen when a video is submitted and shape the LLM prompts.
+// Empty values fall back to the server defaults.
+type AnalysisOptions struct {
+	OutputLanguage string `json:"output_language"` // language of generated titles/descriptions, e.g. es, en, pt
+	Platform       string `json:"platform"`        // tiktok, youtube_shorts, instagram_reels or free text
+	PromptTemplate string `json:"prompt_template"` // clip analysis template name
+}
+
+// Subtitle renderers selectable per export
+const (
+	SubtitleRendererDrawtext = "drawtext"
+	SubtitleRendererASS      = "ass"
+)
+
+// Framing modes: how the source is fitted into the output aspect ratio
+const (
+	FramingCrop      = "crop"      // fill the frame and crop the overflow
+	FramingLetterbox = "letterbox" // show the whole frame with black bars
+	FramingBlur      = "blur"      // show the whole frame over a blurred, zoomed copy of itself
+)
+
+// Animated preview formats
+const (
+	PreviewGIF  = "gif"
+	PreviewWebP = "webp"
+)
+
+// RenderOptions are chosen when a clip is exported and control how it is encoded.
+// Empty values fall back to the server defaults.
+type RenderOptions struct {
+	SubtitleRenderer string `json:"subtitle_renderer,omitempty"` // drawtext or ass
+	AspectRatio      string `json:"aspect_ratio,omitempty"`      // 9:16 (default), 1:1, 4:5 or 16:9
+	Resolution       int    `json:"resolution,omitempty"`        // short side in pixels, 1080 by default
+	Framing          string `json:"framing,omitempty"`           // crop (default), letterbox or blur
+	Reframe          bool   `json:"reframe,omitempty"`           // with crop framing, follow the speaker's face
+
+	Loudness    string  `json:"loudness,omitempty"`     // loudness target: tiktok, youtube_shorts, instagram_reels, youtube, podcast or broadcast
+	TrimSilence bool    `json:"trim_silence,omitempty"` // cut leading and trailing silence
+	MaxPause    float64 `json:"max_pause,omitempty"`    // cut internal pauses longer than this many seconds (0 keeps them)
+	JumpCut     bool    `json:"jump_cut,omitempty"`     // cut filler words ("um", "eh", "o sea") and pauses
+
+	BrandKitID string `json:"brand_kit_id,omitempty"` // brand kit composited onto the clip
+
+	MusicID     string  `json:"music_id,omitempty"`     // music track mixed under the speech
+	MusicStart  float64 `json:"music_start,omitempty"`  // offset into the track, in seconds
+	MusicVolume float64 `json:"music_volume,omitempty"` // 0 to 1, 0.25 by default
+
+	Headline    *HeadlineOverlay    `json:"headline,omitempty"`     // hook headline shown for the whole clip
+	ProgressBar *ProgressBarOverlay `json:"progress_bar,omitempty"` // bar that fills up as the clip plays
+
+	Preview *PreviewOptions `json:"preview,omitempty"` // silent looping GIF or WebP rendered with the MP4
+}
+
+// PreviewOptions ask for an animated preview of the clip, for chat apps and social
+// posts that autoplay it muted
+type PreviewOptions struct {
+	Format    string `json:"format"`    // gif (default) or webp
+	Width     int    `json:"width"`     // pixels, 480 by default; the height follows the aspect ratio
+	FPS       int    `json:"fps"`       // 12 by default
+	Subtitles bool   `json:"subtitles"` // burn in the clip subtitles
+}
+
+// HeadlineOverlay is a static headline drawn over the whole clip. Sizes are editor
+// canvas pixels, like subtitles.
+type HeadlineOverlay struct {
+	Text       string  `json:"text"`
+	FontFamily string  `json:"font_family"`
+	FontSize   int     `json:"font_size"`   // 28 by default
+	FontWeight int     `json:"font_weight"` // 800 by default
+	Italic     bool    `json:"italic"`
+	Color      string  `json:"color"`      // #FFFFFF by default
+	BgColor    string  `json:"bg_color"`   // box behind each line, none when empty
+	BgOpacity  float64 `json:"bg_opacity"` // 0.8 by default with bg_color
+	Position   string  `json:"position"`   // top (default), center or bottom
+}
+
+// ProgressBarOverlay is a bar along the top or bottom edge that fills up as the
+// clip plays
+type ProgressBarOverlay struct {
+	Color     string  `json:"color"`      // #FFFFFF by default
+	BgColor   string  `json:"bg_color"`   // track behind the bar, none when empty
+	BgOpacity float64 `json:"bg_opacity"` // 0.35 by default with bg_color
+	Thickness int     `json:"thickness"`  // editor canvas pixels, 4 by default
+	Position  string  `json:"position"`   // bottom (default) or top
+}
+
+// MusicTrack is an uploaded music file that exports can use as a background bed
+type MusicTrack struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	FilePath  string    `json:"file_path"`
+	Duration  float64   `json:"duration"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Logo corners of a brand kit
+const (
+	LogoTopLeft     = "top_left"
+	LogoTopRight    = "top_right"
+	LogoBottomLeft  = "bottom_left"
+	LogoBottomRight = "bottom_right"
+)
+
+// BrandKit is the channel branding added to exported clips: a logo watermark and
+// intro/outro cards. Asset paths are set by uploading the files, never from JSON.
+type BrandKit struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	LogoPath     string     `json:"logo_path"`     // uploaded image, a PNG with transparency works best
+	LogoPosition string     `json:"logo_position"` // top_left, top_right (default), bottom_left or bottom_right
+	LogoOpacity  float64    `json:"logo_opacity"`  // 0 to 1
+	LogoWidth    float64    `json:"logo_width"`    // fraction of the frame width
+	Intro        *BrandCard `json:"intro,omitempty"`
+	Outro        *BrandCard `json:"outro,omitempty"`
+	SafeZone     SafeZone   `json:"safe_zone"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// BrandCard is an intro or outro: an uploaded video, or an image and/or text shown
+// for Duration seconds
+type BrandCard struct {
+	VideoPath string  `json:"video_path"`
+	ImagePath string  `json:"image_path"`
+	Text      string  `json:"text,omitempty"`
+	TextColor string  `json:"text_color,omitempty"` // #FFFFFF by default
+	BgColor   string  `json:"bg_color,omitempty"`   // behind the text and around images, #000000 by default
+	Duration  float64 `json:"duration,omitempty"`   // image and text cards, 2 seconds by default
+}
+
+// SafeZone are the margins of the frame covered by the platform interface, as
+// fractions of the frame size. The logo and the subtitles stay inside.
+type SafeZone struct {
+	Top    float64 `json:"top"`
+	Bottom float64 `json:"bottom"`
+	Left   float64 `json:"left"`
+	Right  float64 `json:"right"`
+}
+
 type Video struct {
 	ID           string    `json:"id"`
-	URL          string    `json:"url"`
+	URL          string    `json:"url"`         // original file name for uploads
+	SourceType   string    `json:"source_type"` // url, upload
 	Title        string    `json:"title"`
 	Duration     int       `json:"duration"`
 	FilePath     string    `json:"file_path"`
@@ -12,6 +158,8 @@ type Video struct {
 	Status       string    `json:"status"` // pending, processing, completed, error
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+
+	AnalysisOptions
 }
 
 type Transcript struct {
@@ -27,17 +175,44 @@ type Segment struct {
 	Start float64 `json:"start"`
 	End   float64 `json:"end"`
 	Text  string  `json:"text"`
+	Words []Word  `json:"words,omitempty"`
+}
+
+// Word is a single spoken word with its own timing (seconds)
+type Word struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// Scene is a shot of a video, from one detected cut to the next
+type Scene struct {
+	Index     int     `json:"index"`
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+	Score     float64 `json:"score"` // scene change score of the cut that opens it, 0 for the first shot
+}
+
+// ClipSegment is one source range of a multi-part clip, in seconds of the video
+type ClipSegment struct {
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
 }
 
 type SuggestedClip struct {
-	ID          string  `json:"id"`
-	VideoID     string  `json:"video_id"`
-	StartTime   float64 `json:"start_time"`
-	EndTime     float64 `json:"end_time"`
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	Score       float64 `json:"score"`
-	Reason      string  `json:"reason"`
+	ID            string  `json:"id"`
+	VideoID       string  `json:"video_id"`
+	StartTime     float64 `json:"start_time"`
+	EndTime       float64 `json:"end_time"`
+	Title         string  `json:"title"`
+	Description   string  `json:"description"`
+	Score         float64 `json:"score"`
+	Reason        string  `json:"reason"`
+	PromptVersion string  `json:"prompt_version"` // template name@version#hash that produced it
+
+	// Segments are the source ranges of a multi-part clip in playback order; empty
+	// for a single range. StartTime and EndTime are then their span.
+	Segments []ClipSegment `json:"segments,omitempty"`
 }
 
 type Clip struct {
@@ -47,10 +222,20 @@ type Clip struct {
 	StartTime   float64          `json:"start_time"`
 	EndTime     float64          `json:"end_time"`
 	FilePath    string           `json:"file_path"`
-	Status      string           `json:"status"` // processing, completed, error
+	Status      string           `json:"status"` // queued, processing, completed, error
+	Error       string           `json:"error,omitempty"`
 	Subtitles   []SubtitleConfig `json:"subtitles"`
 	CreatedAt   time.Time        `json:"created_at"`
 	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+
+	// Segments are the source ranges of a multi-part clip in playback order; empty
+	// for a single range. StartTime and EndTime are then their span, and subtitle
+	// times are relative to the segments played back to back.
+	Segments []ClipSegment `json:"segments,omitempty"`
+
+	PreviewPath string `json:"preview_path,omitempty"` // animated preview, when the export asked for one
+
+	RenderOptions
 }
 
 type SubtitleConfig struct {
@@ -70,12 +255,39 @@ type SubtitleConfig struct {
 	ShadowBlur      int     `json:"shadow_blur"`
 	Transition      string  `json:"transition"`
 	ActiveTextColor string  `json:"active_text_color"`
+	Words           []Word  `json:"words,omitempty"` // clip-relative word timings for karaoke
+}
+
+// Thumbnail kinds
+const (
+	ThumbnailCandidate = "candidate" // frame extracted from a clip range
+	ThumbnailCover     = "cover"     // candidate rendered at a cover size, with the title
+)
+
+// Thumbnail is a cover frame of a clip: a scored candidate taken from the source, or
+// a cover rendered from the chosen candidate
+type Thumbnail struct {
+	ID        string    `json:"id"`
+	VideoID   string    `json:"video_id"`
+	ClipID    string    `json:"clip_id,omitempty"`
+	Kind      string    `json:"kind"`
+	Time      float64   `json:"time"`  // source seconds of the frame
+	Score     float64   `json:"score"` // 0 to 1, candidates only
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	Title     string    `json:"title,omitempty"`     // text composited on covers
+	SourceID  string    `json:"source_id,omitempty"` // candidate a cover was rendered from
+	Selected  bool      `json:"selected"`            // candidate chosen as the cover
+	FilePath  string    `json:"file_path"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type ProcessingJob struct {
 	ID        string    `json:"id"`
-	Type      string    `json:"type"` // download, transcribe, analyze, create_clip
-	Status    string    `json:"status"`
+	VideoID   string    `json:"video_id"`
+	Type      string    `json:"type"`   // download, probe, transcribe, analyze, create_clip
+	Status    string    `json:"status"` // pending, running, completed, error, interrupted
 	Progress  int       `json:"progress"`
 	Message   string    `json:"message"`
 	CreatedAt time.Time `json:"created_at"`
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"shortgenerator/models"
+	"strings"
+)
+
+// assTransitionSteps is how many \t segments approximate a transition's easing curve
+const assTransitionSteps = 8
+
+// writeASSSubtitles renders subtitles to an .ass file next to the clip and returns
+// the ass filter that burns it in
+func (s *ProcessingService) writeASSSubtitles(subtitles []models.SubtitleConfig, layout subtitleLayout, path string) (string, error) {
+	if err := os.WriteFile(path, []byte(s.buildASSSubtitles(subtitles, layout)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write ASS subtitles: %v", err)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	return fmt.Sprintf("ass=filename='%s'", escapeDrawtext(filepath.ToSlash(absPath))), nil
+}
+
+// buildASSSubtitles converts subtitles into an Advanced SubStation Alpha script that
+// mimics SubtitleCanvas.svelte: every subtitle gets a style, a rounded background box
+// (a vector drawing, since ASS boxes are square) with a soft shadow, word wrapping,
+// \k karaoke from word timings and its entrance transition.
+func (s *ProcessingService) buildASSSubtitles(subtitles []models.SubtitleConfig, layout subtitleLayout) string {
+	styles := []string{}
+	styleNames := map[string]string{}
+	events := []string{}
+
+	for _, sub := range subtitles {
+		if strings.TrimSpace(sub.Text) == "" || sub.EndTime <= sub.StartTime {
+			continue
+		}
+
+		fontPath := resolveFontPath(sub.FontFamily, sub.FontWeight, sub.Bold, sub.Italic)
+		fontSize := layout.fontSize(sub)
+		words := strings.Fields(sub.Text)
+		wordTimed := len(sub.Words) > 0 && len(sub.Words) == len(words)
+
+		baseColor := assColor(sub.Color, "#FFFFFF")
+		activeColor := ""
+		if sub.ActiveTextColor != "" && sub.ActiveTextColor != sub.Color {
+			activeColor = assColor(sub.ActiveTextColor, sub.Color)
+		}
+
+		// With \k the sung part uses the primary colour and the rest the secondary
+		primary, secondary := baseColor, baseColor
+		if wordTimed && activeColor != "" {
+			primary = activeColor
+		}
+
+		bold := 0
+		if strings.Contains(filepath.Base(fontPath), "Bold") {
+			bold = -1
+		}
+		italic := 0
+		if sub.Italic {
+			italic = -1
+		}
+
+		style := fmt.Sprintf("%s,%.1f,%s,%s,&H00000000,&H80000000,%d,%d,0,0,100,100,0,0,1,0,0,5,0,0,0,1",
+			assFontName(fontPath), fontLineHeight(fontPath, fontSize), primary, secondary, bold, italic)
+		name, ok := styleNames[style]
+		if !ok {
+			name = fmt.Sprintf("S%d", len(styleNames))
+			styleNames[style] = name
+			styles = append(styles, "Style: "+name+","+style)
+		}
+
+		// Wrap to the frame width minus the side margins and the box padding
+		boxBorder := layout.boxBorder(sub)
+		sideMargin := int(math.Round(bottomMargin * layout.scale))
+		maxWidth := int(layout.area.width) - 2*sideMargin - 2*boxBorder
+		lineStarts := wrapWords(fontPath, fontSize, words, maxWidth)
+
+		textWidth := 0
+		for i, start := range lineStarts {
+			end := len(words)
+			if i+1 < len(lineStarts) {
+				end = lineStarts[i+1]
+			}
+			if w := measureTextWidth(fontPath, fontSize, strings.Join(words[start:end], " ")); w > textWidth {
+				textWidth = w
+			}
+		}
+		lineHeight := fontLineHeight(fontPath, fontSize)
+		boxWidth := float64(textWidth + 2*boxBorder)
+		boxHeight := lineHeight*float64(len(lineStarts)) + float64(2*boxBorder)
+
+		// Centered on the same point as the drawtext renderer, kept inside the frame
+		y := clampFloat(layout.targetY(sub.Position), boxHeight/2, float64(layout.height)-boxHeight/2)
+		x := float64(layout.width) / 2
+
+		start, end := assTime(sub.StartTime), assTime(sub.EndTime)
+		anim := assAnimation(sub.Transition, layout.scale, sub.EndTime-sub.StartTime, x, y, 0)
+
+		bgOpacity := subtitleBgOpacity(sub)
+		if bgOpacity > 0 {
+			radius := math.Min(float64(sub.BorderRadius)*layout.scale, math.Min(boxWidth, boxHeight)/2)
+			box := assRoundedRect(boxWidth, boxHeight, radius)
+
+			shadowBlur := sub.ShadowBlur
+			if shadowBlur <= 0 {
+				shadowBlur = 12
+			}
+			shadowOpacity := clampFloat(0.18+float64(shadowBlur)/60.0, 0.2, 0.55)
+			shadowOffset := math.Round(bgShadowOffset * layout.scale)
+			shadowBlurPx := float64(shadowBlur) * layout.scale / 6
+			shadowAnim := assAnimation(sub.Transition, layout.scale, sub.EndTime-sub.StartTime, x, y+shadowOffset, shadowBlurPx)
+
+			events = append(events,
+				fmt.Sprintf("Dialogue: 0,%s,%s,%s,,0,0,0,,{\\blur%.1f%s\\bord0\\shad0\\1c&H000000&\\1a%s\\p1}%s",
+					start, end, name, shadowBlurPx, shadowAnim, assAlpha(shadowOpacity), box),
+				fmt.Sprintf("Dialogue: 1,%s,%s,%s,,0,0,0,,{%s\\bord0\\shad0\\1c%s\\1a%s\\p1}%s",
+					start, end, name, anim, assColorBGR(sub.BgColor, "#000000"), assAlpha(bgOpacity), box),
+			)
+		}
+
+		// Text, with a soft drop shadow like the drawtext renderer
+		textShadow := math.Max(1, math.Round(textShadowOffset*layout.scale))
+		tags := fmt.Sprintf("%s\\xshad0\\yshad%.0f", anim, textShadow)
+		if !wordTimed && activeColor != "" {
+			// Without word timings the line fades into the active colour
+			tags += fmt.Sprintf("\\t(0,%d,\\1c%s)", int((sub.EndTime-sub.StartTime)*0.85*1000), assColorBGR(sub.ActiveTextColor, sub.Color))
+		}
+
+		var text strings.Builder
+		if wordTimed && activeColor != "" {
+			text.WriteString(assKaraoke(sub, words, lineStarts))
+		} else {
+			for i, start := range lineStarts {
+				end := len(words)
+				if i+1 < len(lineStarts) {
+					end = lineStarts[i+1]
+				}
+				if i > 0 {
+					text.WriteString("\\N")
+				}
+				text.WriteString(escapeASS(strings.Join(words[start:end], " ")))
+			}
+		}
+
+		events = append(events, fmt.Sprintf("Dialogue: 2,%s,%s,%s,,0,0,0,,{%s}%s", start, end, name, tags, text.String()))
+	}
+
+	var b strings.Builder
+	b.WriteString("[Script Info]\n")
+	b.WriteString("ScriptType: v4.00+\n")
+	// The output size as PlayRes, so positions are in output pixels
+	fmt.Fprintf(&b, "PlayResX: %d\nPlayResY: %d\n", layout.width, layout.height)
+	b.WriteString("WrapStyle: 2\n") // lines are wrapped above
+	b.WriteString("ScaledBorderAndShadow: yes\n")
+	b.WriteString("YCbCr Matrix: TV.709\n\n")
+
+	b.WriteString("[V4+ Styles]\n")
+	b.WriteString("Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\n")
+	for _, style := range styles {
+		b.WriteString(style + "\n")
+	}
+
+	b.WriteString("\n[Events]\n")
+	b.WriteString("Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n")
+	for _, event := range events {
+		b.WriteString(event + "\n")
+	}
+
+	return b.String()
+}
+
+// assKaraoke tags every word with \k for the time until the next word starts, so each
+// word switches to the active colour when it is spoken and stays highlighted
+func assKaraoke(sub models.SubtitleConfig, words []string, lineStarts []int) string {
+	var b strings.Builder
+	centis := func(t float64) int { return int(math.Round((t - sub.StartTime) * 100)) }
+
+	// Silence before the first word
+	elapsed := centis(clampFloat(sub.Words[0].Start, sub.StartTime, sub.EndTime))
+	if elapsed > 0 {
+		fmt.Fprintf(&b, "{\\k%d}", elapsed)
+	}
+
+	line := 0
+	for i, word := range words {
+		if line+1 < len(lineStarts) && lineStarts[line+1] == i {
+			b.WriteString("\\N")
+			line++
+		} else if i > 0 {
+			b.WriteString(" ")
+		}
+
+		next := sub.EndTime
+		if i+1 < len(sub.Words) {
+			next = clampFloat(sub.Words[i+1].Start, sub.StartTime, sub.EndTime)
+		}
+		to := maxInt(elapsed, centis(next))
+		fmt.Fprintf(&b, "{\\k%d}%s", to-elapsed, escapeASS(word))
+		elapsed = to
+	}
+
+	return b.String()
+}
+
+// assAnimation returns the position and entrance transition tags for a layer
+// centered at (x, y) and shown for the given seconds, with canvas offsets scaled to
+// the output by scale. restBlur is the layer's own
+// \blur, which the transition settles on. \t interpolates linearly, so the easing
+// curve is followed in assTransitionSteps segments; \move and \fad stay linear.
+func assAnimation(transition string, scale, shown, x, y, restBlur float64) string {
+	t, ok := lookupSubtitleTransition(transition)
+	if !ok {
+		return fmt.Sprintf("\\an5\\pos(%.0f,%.0f)", x, y)
+	}
+	durationMs := int(math.Round(t.duration(shown) * 1000))
+
+	var b strings.Builder
+	b.WriteString("\\an5")
+	if t.fromOffset != 0 {
+		fmt.Fprintf(&b, "\\move(%.0f,%.0f,%.0f,%.0f,0,%d)", x, y+t.fromOffset*scale, x, y, durationMs)
+	} else {
+		fmt.Fprintf(&b, "\\pos(%.0f,%.0f)", x, y)
+	}
+	fmt.Fprintf(&b, "\\fad(%d,0)", durationMs)
+
+	// The canvas rotates clockwise, \frz counter-clockwise
+	state := func(eased float64) string {
+		var tags string
+		if t.fromScale != 1 {
+			scale := (t.fromScale + (1-t.fromScale)*eased) * 100
+			tags += fmt.Sprintf("\\fscx%.1f\\fscy%.1f", scale, scale)
+		}
+		if t.fromRotate != 0 {
+			tags += fmt.Sprintf("\\frz%.1f", -t.fromRotate*(1-eased))
+		}
+		if t.fromBlur != 0 {
+			tags += fmt.Sprintf("\\blur%.1f", restBlur+t.fromBlur*scale*math.Max(0, 1-eased))
+		}
+		return tags
+	}
+
+	if first := state(0); first != "" {
+		b.WriteString(first)
+		for step := 1; step <= assTransitionSteps; step++ {
+			from := durationMs * (step - 1) / assTransitionSteps
+			to := durationMs * step / assTransitionSteps
+			fmt.Fprintf(&b, "\\t(%d,%d,%s)", from, to, state(t.ease(float64(step)/assTransitionSteps)))
+		}
+	}
+
+	return b.String()
+}
+
+// assRoundedRect draws a w x h rectangle with corner radius r in ASS drawing commands
+func assRoundedRect(w, h, r float64) string {
+	if r <= 0 {
+		return fmt.Sprintf("m 0 0 l %.0f 0 %.0f %.0f 0 %.0f", w, w, h, h)
+	}
+
+	c := r * 0.4477 // distance of the bezier control points from the corner
+	return fmt.Sprintf("m %.0f 0 l %.0f 0 b %.0f 0 %.0f %.0f %.0f %.0f l %.0f %.0f b %.0f %.0f %.0f %.0f %.0f %.0f l %.0f %.0f b %.0f %.0f %.0f %.0f %.0f %.0f l %.0f %.0f b %.0f %.0f %.0f %.0f %.0f %.0f",
+		r, w-r,
+		w-c, w, c, w, r,
+		w, h-r,
+		w, h-c, w-c, h, w-r, h,
+		r, h,
+		c, h, 0.0, h-c, 0.0, h-r,
+		0.0, r,
+		0.0, c, c, 0.0, r, 0.0,
+	)
+}
+
+// assTime formats seconds as H:MM:SS.cc
+func assTime(seconds float64) string {
+	centis := int(math.Round(math.Max(0, seconds) * 100))
+	return fmt.Sprintf("%d:%02d:%02d.%02d", centis/360000, centis/6000%60, centis/100%60, centis%100)
+}
+
+// assColor converts a CSS colour to the &HAABBGGRR form of styles (alpha 00 is opaque)
+func assColor(color, fallback string) string {
+	r, g, b, a := parseCSSColor(color, fallback)
+	return fmt.Sprintf("&H%02X%02X%02X%02X", 255-int(math.Round(a*255)), b, g, r)
+}
+
+// assColorBGR converts a CSS colour to the &HBBGGRR& form of the \1c..\4c tags
+func assColorBGR(color, fallback string) string {
+	r, g, b, _ := parseCSSColor(color, fallback)
+	return fmt.Sprintf("&H%02X%02X%02X&", b, g, r)
+}
+
+// assAlpha converts an opacity to the &HAA& form of the \1a..\4a tags
+func assAlpha(opacity float64) string {
+	return fmt.Sprintf("&H%02X&", 255-int(math.Round(clampFloat(opacity, 0, 1)*255)))
+}
+
+var cssNamedColors = map[string][3]int{
+	"white":  {255, 255, 255},
+	"black":  {0, 0, 0},
+	"red":    {255, 0, 0},
+	"green":  {0, 128, 0},
+	"blue":   {0, 0, 255},
+	"yellow": {255, 255, 0},
+	"orange": {255, 165, 0},
+}
+
+// parseCSSColor reads #RGB, #RRGGBB, #RRGGBBAA, rgb(), rgba() and a few named
+// colours, using fallback when color is empty or unreadable
+func parseCSSColor(color, fallback string) (r, g, b int, a float64) {
+	color = strings.ToLower(strings.TrimSpace(color))
+	a = 1
+
+	switch {
+	case strings.HasPrefix(color, "#"):
+		hex := strings.TrimPrefix(color, "#")
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) == 6 || len(hex) == 8 {
+			if n, _ := fmt.Sscanf(hex[:6], "%02x%02x%02x", &r, &g, &b); n == 3 {
+				if len(hex) == 8 {
+					var alpha int
+					fmt.Sscanf(hex[6:], "%02x", &alpha)
+					a = float64(alpha) / 255
+				}
+				return r, g, b, a
+			}
+		}
+	case strings.HasPrefix(color, "rgba"):
+		if n, _ := fmt.Sscanf(strings.ReplaceAll(color, " ", ""), "rgba(%d,%d,%d,%f)", &r, &g, &b, &a); n == 4 {
+			return r, g, b, clampFloat(a, 0, 1)
+		}
+	case strings.HasPrefix(color, "rgb"):
+		if n, _ := fmt.Sscanf(strings.ReplaceAll(color, " ", ""), "rgb(%d,%d,%d)", &r, &g, &b); n == 3 {
+			return r, g, b, 1
+		}
+	default:
+		if rgb, ok := cssNamedColors[color]; ok {
+			return rgb[0], rgb[1], rgb[2], 1
+		}
+	}
+
+	if fallback != "" && fallback != color {
+		return parseCSSColor(fallback, "")
+	}
+	return 255, 255, 255, 1
+}
+
+// assFontName maps the font file chosen by resolveFontPath to the family name
+// fontconfig knows it by, so libass picks the same face as drawtext
+func assFontName(fontPath string) string {
+	base := strings.TrimSuffix(filepath.Base(fontPath), filepath.Ext(fontPath))
+	for _, family := range []struct{ prefix, name string }{
+		{"DejaVuSansMono", "DejaVu Sans Mono"},
+		{"DejaVuSans", "DejaVu Sans"},
+		{"DejaVuSerif", "DejaVu Serif"},
+		{"LiberationSans", "Liberation Sans"},
+		{"LiberationSerif", "Liberation Serif"},
+		{"LiberationMono", "Liberation Mono"},
+		{"FreeSerif", "FreeSerif"},
+		{"FreeSans", "FreeSans"},
+		{"FreeMono", "FreeMono"},
+	} {
+		if strings.HasPrefix(base, family.prefix) {
+			return family.name
+		}
+	}
+	return "DejaVu Sans"
+}
+
+// escapeASS keeps subtitle text from being read as override tags or line breaks
+func escapeASS(text string) string {
+	return strings.NewReplacer(
+		"\\", "\\\u2060", // a word joiner after the backslash breaks \N, \h and \n
+		"{", "\\{",
+		"}", "\\}",
+		"\n", " ",
+	).Replace(text)
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"shortgenerator/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Brand kit defaults
+const (
+	defaultLogoOpacity   = 0.9
+	defaultLogoWidth     = 0.18
+	defaultCardDuration  = 2.0
+	maxBrandCardDuration = 15.0
+)
+
+// defaultSafeZone keeps clear of the captions, buttons and header of vertical players
+var defaultSafeZone = models.SafeZone{Top: 0.06, Bottom: 0.15, Left: 0.05, Right: 0.12}
+
+// Brand asset kinds, each stored as one file per kit
+const (
+	BrandAssetLogo       = "logo"
+	BrandAssetIntroVideo = "intro_video"
+	BrandAssetIntroImage = "intro_image"
+	BrandAssetOutroVideo = "outro_video"
+	BrandAssetOutroImage = "outro_image"
+)
+
+var brandImageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".webp": true}
+
+type BrandKitService struct {
+	db          *sql.DB
+	storagePath string
+}
+
+func NewBrandKitService(db *sql.DB, storagePath string) *BrandKitService {
+	return &BrandKitService{db: db, storagePath: storagePath}
+}
+
+// ValidateBrandKit normalizes a brand kit, filling defaults, and rejects unsupported
+// values. An all-zero safe zone takes the default margins.
+func ValidateBrandKit(kit *models.BrandKit) error {
+	kit.Name = strings.TrimSpace(kit.Name)
+	kit.LogoPosition = strings.ToLower(strings.TrimSpace(kit.LogoPosition))
+
+	if kit.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	switch kit.LogoPosition {
+	case "":
+		kit.LogoPosition = models.LogoTopRight
+	case models.LogoTopLeft, models.LogoTopRight, models.LogoBottomLeft, models.LogoBottomRight:
+	default:
+		return fmt.Errorf("invalid logo_position %q, expected %s, %s, %s or %s", kit.LogoPosition,
+			models.LogoTopLeft, models.LogoTopRight, models.LogoBottomLeft, models.LogoBottomRight)
+	}
+
+	if kit.LogoOpacity == 0 {
+		kit.LogoOpacity = defaultLogoOpacity
+	}
+	if kit.LogoOpacity < 0 || kit.LogoOpacity > 1 {
+		return fmt.Errorf("invalid logo_opacity %.2f, expected 0 to 1", kit.LogoOpacity)
+	}
+	if kit.LogoWidth == 0 {
+		kit.LogoWidth = defaultLogoWidth
+	}
+	if kit.LogoWidth < 0.02 || kit.LogoWidth > 0.5 {
+		return fmt.Errorf("invalid logo_width %.2f, expected 0.02 to 0.5", kit.LogoWidth)
+	}
+
+	if kit.SafeZone == (models.SafeZone{}) {
+		kit.SafeZone = defaultSafeZone
+	}
+	for name, margin := range map[string]float64{"top": kit.SafeZone.Top, "bottom": kit.SafeZone.Bottom, "left": kit.SafeZone.Left, "right": kit.SafeZone.Right} {
+		if margin < 0 || margin > 0.4 {
+			return fmt.Errorf("invalid safe_zone %s %.2f, expected 0 to 0.4", name, margin)
+		}
+	}
+
+	for name, card := range map[string]*models.BrandCard{"intro": kit.Intro, "outro": kit.Outro} {
+		if card == nil {
+			continue
+		}
+		if card.Duration == 0 {
+			card.Duration = defaultCardDuration
+		}
+		if card.Duration < 0.5 || card.Duration > maxBrandCardDuration {
+			return fmt.Errorf("invalid %s duration %.1f, expected 0.5 to %.0f seconds", name, card.Duration, maxBrandCardDuration)
+		}
+	}
+
+	return nil
+}
+
+// brandCardHasContent reports whether a card has anything to show
+func brandCardHasContent(card *models.BrandCard) bool {
+	return card != nil && (card.VideoPath != "" || card.ImagePath != "" || strings.TrimSpace(card.Text) != "")
+}
+
+const brandKitColumns = `id, name, COALESCE(logo_path, ''), COALESCE(logo_position, 'top_right'), COALESCE(logo_opacity, 0.9),
+			  COALESCE(logo_width, 0.18), COALESCE(intro, 'null'), COALESCE(outro, 'null'), COALESCE(safe_zone, '{}'),
+			  created_at, updated_at`
+
+func scanBrandKit(row interface{ Scan(...interface{}) error }, kit *models.BrandKit) error {
+	var introJSON, outroJSON, safeZoneJSON string
+
+	err := row.Scan(
+		&kit.ID, &kit.Name, &kit.LogoPath, &kit.LogoPosition, &kit.LogoOpacity,
+		&kit.LogoWidth, &introJSON, &outroJSON, &safeZoneJSON,
+		&kit.CreatedAt, &kit.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(introJSON), &kit.Intro); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(outroJSON), &kit.Outro); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(safeZoneJSON), &kit.SafeZone)
+}
+
+func (s *BrandKitService) CreateBrandKit(kit *models.BrandKit) error {
+	kit.ID = uuid.New().String()
+	kit.CreatedAt = time.Now()
+	kit.UpdatedAt = kit.CreatedAt
+
+	introJSON, outroJSON, safeZoneJSON, err := brandKitJSON(kit)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO brand_kits (id, name, logo_path, logo_position, logo_opacity, logo_width, intro, outro, safe_zone, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = s.db.Exec(query, kit.ID, kit.Name, kit.LogoPath, kit.LogoPosition, kit.LogoOpacity, kit.LogoWidth,
+		introJSON, outroJSON, safeZoneJSON, kit.CreatedAt, kit.UpdatedAt)
+
+	return err
+}
+
+func (s *BrandKitService) UpdateBrandKit(kit *models.BrandKit) error {
+	kit.UpdatedAt = time.Now()
+
+	introJSON, outroJSON, safeZoneJSON, err := brandKitJSON(kit)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE brand_kits
+			  SET name = ?, logo_path = ?, logo_position = ?, logo_opacity = ?, logo_width = ?, intro = ?, outro = ?, safe_zone = ?, updated_at = ?
+			  WHERE id = ?`
+	_, err = s.db.Exec(query, kit.Name, kit.LogoPath, kit.LogoPosition, kit.LogoOpacity, kit.LogoWidth,
+		introJSON, outroJSON, safeZoneJSON, kit.UpdatedAt, kit.ID)
+
+	return err
+}
+
+func brandKitJSON(kit *models.BrandKit) (intro, outro, safeZone string, err error) {
+	introJSON, err := json.Marshal(kit.Intro)
+	if err != nil {
+		return "", "", "", err
+	}
+	outroJSON, err := json.Marshal(kit.Outro)
+	if err != nil {
+		return "", "", "", err
+	}
+	safeZoneJSON, err := json.Marshal(kit.SafeZone)
+	if err != nil {
+		return "", "", "", err
+	}
+	return string(introJSON), string(outroJSON), string(safeZoneJSON), nil
+}
+
+func (s *BrandKitService) GetBrandKit(id string) (*models.BrandKit, error) {
+	kit := &models.BrandKit{}
+
+	query := `SELECT ` + brandKitColumns + ` FROM brand_kits WHERE id = ?`
+	if err := scanBrandKit(s.db.QueryRow(query, id), kit); err != nil {
+		return nil, err
+	}
+
+	return kit, nil
+}
+
+func (s *BrandKitService) GetBrandKits() ([]models.BrandKit, error) {
+	rows, err := s.db.Query(`SELECT ` + brandKitColumns + ` FROM brand_kits ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	kits := []models.BrandKit{}
+	for rows.Next() {
+		var kit models.BrandKit
+		if err := scanBrandKit(rows, &kit); err != nil {
+			return nil, err
+		}
+		kits = append(kits, kit)
+	}
+
+	return kits, rows.Err()
+}
+
+// DeleteBrandKit removes the kit and its uploaded assets
+func (s *BrandKitService) DeleteBrandKit(id string) error {
+	if _, err := s.db.Exec(`DELETE FROM brand_kits WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.assetDir(id))
+}
+
+func (s *BrandKitService) assetDir(kitID string) string {
+	return filepath.Join(s.storagePath, "brand", kitID)
+}
+
+// AssetPath is where an uploaded asset of the given kind is stored. It rejects
+// unknown kinds and files that do not match the kind (image or video).
+func (s *BrandKitService) AssetPath(kitID, kind, filename string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch kind {
+	case BrandAssetLogo, BrandAssetIntroImage, BrandAssetOutroImage:
+		if !brandImageExtensions[ext] {
+			return "", fmt.Errorf("unsupported image format %q, expected png, jpg or webp", ext)
+		}
+	case BrandAssetIntroVideo, BrandAssetOutroVideo:
+		if !IsSupportedUpload(filename) {
+			return "", fmt.Errorf("unsupported video format %q", ext)
+		}
+	default:
+		return "", fmt.Errorf("invalid asset %q, expected %s, %s, %s, %s or %s", kind,
+			BrandAssetLogo, BrandAssetIntroVideo, BrandAssetIntroImage, BrandAssetOutroVideo, BrandAssetOutroImage)
+	}
+
+	if err := os.MkdirAll(s.assetDir(kitID), 0755); err != nil {
+		return "", fmt.Errorf("failed to create brand directory: %v", err)
+	}
+	return filepath.Join(s.assetDir(kitID), kind+ext), nil
+}
+
+// SetAsset records (or, with an empty path, clears) an asset of the kit, removing the
+// file it replaces
+func (s *BrandKitService) SetAsset(kit *models.BrandKit, kind, path string) error {
+	var field *string
+	switch kind {
+	case BrandAssetLogo:
+		field = &kit.LogoPath
+	case BrandAssetIntroVideo, BrandAssetIntroImage:
+		if kit.Intro == nil {
+			kit.Intro = &models.BrandCard{Duration: defaultCardDuration}
+		}
+		field = &kit.Intro.VideoPath
+		if kind == BrandAssetIntroImage {
+			field = &kit.Intro.ImagePath
+		}
+	case BrandAssetOutroVideo, BrandAssetOutroImage:
+		if kit.Outro == nil {
+			kit.Outro = &models.BrandCard{Duration: defaultCardDuration}
+		}
+		field = &kit.Outro.VideoPath
+		if kind == BrandAssetOutroImage {
+			field = &kit.Outro.ImagePath
+		}
+	default:
+		return fmt.Errorf("invalid asset %q", kind)
+	}
+
+	if *field != "" && *field != path {
+		os.Remove(*field)
+	}
+	*field = path
+
+	return s.UpdateBrandKit(kit)
+}
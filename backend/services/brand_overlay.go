@@ -0,0 +1,209 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"shortgenerator/models"
+	"strings"
+)
+
+// Brand cards are rendered at a fixed frame rate and audio format so they can be
+// joined to any clip
+const (
+	brandCardFPS      = 30
+	stereoAudioFormat = "aformat=sample_rates=48000:channel_layouts=stereo"
+)
+
+// brandLogoGraph overlays the logo of the kit (read from input) on the video label
+// in, in the corner of its position just inside the safe zone, and outputs out
+func brandLogoGraph(kit *models.BrandKit, profile outputProfile, input int, in, out string) string {
+	width := evenInt(float64(profile.width) * kit.LogoWidth)
+
+	x := fmt.Sprintf("%d", int(math.Round(kit.SafeZone.Left*float64(profile.width))))
+	if kit.LogoPosition == models.LogoTopRight || kit.LogoPosition == models.LogoBottomRight {
+		x = fmt.Sprintf("main_w-overlay_w-%d", int(math.Round(kit.SafeZone.Right*float64(profile.width))))
+	}
+	y := fmt.Sprintf("%d", int(math.Round(kit.SafeZone.Top*float64(profile.height))))
+	if kit.LogoPosition == models.LogoBottomLeft || kit.LogoPosition == models.LogoBottomRight {
+		y = fmt.Sprintf("main_h-overlay_h-%d", int(math.Round(kit.SafeZone.Bottom*float64(profile.height))))
+	}
+
+	return fmt.Sprintf("[%d:v]scale=%d:-1,format=rgba,colorchannelmixer=aa=%.2f[logo];%s[logo]overlay=%s:%s%s",
+		input, width, kit.LogoOpacity, in, x, y, out)
+}
+
+// withinSafeZone keeps the subtitle area clear of the safe zone margins
+func (l subtitleLayout) withinSafeZone(zone models.SafeZone) subtitleLayout {
+	width, height := float64(l.width), float64(l.height)
+	left := math.Max(l.area.x, zone.Left*width)
+	top := math.Max(l.area.y, zone.Top*height)
+	right := math.Min(l.area.x+l.area.width, width-zone.Right*width)
+	bottom := math.Min(l.area.y+l.area.height, height-zone.Bottom*height)
+	if right <= left || bottom <= top {
+		return l
+	}
+
+	l.area = subtitleArea{x: left, y: top, width: right - left, height: bottom - top}
+	return l
+}
+
+// brandCard is an intro or outro as ffmpeg inputs and the filters that turn them
+// into [<name>v] and, with audio, [<name>a]
+type brandCard struct {
+	name     string
+	args     []string
+	graph    string
+	duration float64
+}
+
+// brandCards are the cards played around a clip, in order
+type brandCards []brandCard
+
+// newBrandCards prepares the intro and outro of the kit, reading their inputs from
+// firstInput on. Cards without a video, image or text are skipped.
+func (s *ProcessingService) newBrandCards(kit *models.BrandKit, profile outputProfile, firstInput int, withAudio bool) (brandCards, error) {
+	cards := brandCards{}
+	input := firstInput
+	for _, c := range []struct {
+		name string
+		card *models.BrandCard
+	}{{"intro", kit.Intro}, {"outro", kit.Outro}} {
+		if !brandCardHasContent(c.card) {
+			continue
+		}
+
+		card, err := s.newBrandCard(c.name, c.card, profile, input, withAudio)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+		if len(card.args) > 0 {
+			input++
+		}
+	}
+	return cards, nil
+}
+
+// newBrandCard fits a video or image card into the output frame (over its
+// background color) or generates a plain one for text cards, then draws the text.
+// Image and text cards are silent, and so are videos without audio.
+func (s *ProcessingService) newBrandCard(name string, card *models.BrandCard, profile outputProfile, input int, withAudio bool) (brandCard, error) {
+	bgColor := s.parseColorToFFmpeg(card.BgColor, "#000000")
+	fit := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:color=%s,setsar=1,fps=%d",
+		profile.width, profile.height, profile.width, profile.height, bgColor, brandCardFPS)
+
+	result := brandCard{name: name, duration: card.Duration}
+	source, audio := "", ""
+	switch {
+	case card.VideoPath != "":
+		info, err := s.ProbeMedia(card.VideoPath)
+		if err != nil {
+			return result, fmt.Errorf("failed to probe %s video: %v", name, err)
+		}
+		result.duration = info.Duration
+		result.args = []string{"-i", card.VideoPath}
+		source = fmt.Sprintf("[%d:v]%s", input, fit)
+		if info.HasAudio {
+			audio = fmt.Sprintf("[%d:a]%s", input, stereoAudioFormat)
+		}
+	case card.ImagePath != "":
+		result.args = []string{"-loop", "1", "-framerate", fmt.Sprintf("%d", brandCardFPS), "-t", fmt.Sprintf("%.3f", card.Duration), "-i", card.ImagePath}
+		source = fmt.Sprintf("[%d:v]%s", input, fit)
+	default:
+		source = fmt.Sprintf("color=c=%s:s=%s:r=%d:d=%.3f,setsar=1", bgColor, profile, brandCardFPS, card.Duration)
+	}
+
+	if text := strings.TrimSpace(card.Text); text != "" {
+		source += "," + s.brandCardText(card, text, profile)
+	}
+	graph := []string{fmt.Sprintf("%s,format=yuv420p[%sv]", source, name)}
+
+	if withAudio {
+		if audio == "" {
+			audio = fmt.Sprintf("anullsrc=r=48000:cl=stereo,atrim=duration=%.3f", result.duration)
+		}
+		graph = append(graph, fmt.Sprintf("%s[%sa]", audio, name))
+	}
+	result.graph = strings.Join(graph, ";")
+
+	return result, nil
+}
+
+// brandCardText draws the card text centered, or under the middle of the frame when
+// it goes over an image or video. Long text shrinks to fit the frame width.
+func (s *ProcessingService) brandCardText(card *models.BrandCard, text string, profile outputProfile) string {
+	fontPath := resolveFontPath("default", 700, true, false)
+	fontSize := profile.height / 24
+	if width := measureTextWidth(fontPath, fontSize, text); float64(width) > 0.9*float64(profile.width) {
+		fontSize = int(float64(fontSize) * 0.9 * float64(profile.width) / float64(width))
+	}
+
+	y := "(h-text_h)/2"
+	if card.VideoPath != "" || card.ImagePath != "" {
+		y = "h*0.78-text_h/2"
+	}
+
+	return fmt.Sprintf("drawtext=text='%s':fontfile=%s:fontsize=%d:fontcolor=%s:x=(w-text_w)/2:y=%s",
+		escapeDrawtext(text), fontPath, fontSize, s.parseColorToFFmpeg(card.TextColor, "#FFFFFF"), y)
+}
+
+// inputArgs are the ffmpeg inputs of the cards
+func (cards brandCards) inputArgs() []string {
+	args := []string{}
+	for _, card := range cards {
+		args = append(args, card.args...)
+	}
+	return args
+}
+
+// duration is how much the cards add to the export
+func (cards brandCards) duration() float64 {
+	total := 0.0
+	for _, card := range cards {
+		total += card.duration
+	}
+	return total
+}
+
+// join plays the intro, the clip (video and, unless empty, audio labels) and the
+// outro back to back into [vbrand] and [abrand]
+func (cards brandCards) join(video, audio string) (graph, videoOut, audioOut string) {
+	filters := []string{}
+	for _, card := range cards {
+		filters = append(filters, card.graph)
+	}
+
+	clip := video
+	if audio != "" {
+		filters = append(filters, audio+stereoAudioFormat+"[amain]")
+		clip += "[amain]"
+	}
+
+	segments := []string{}
+	for _, card := range cards {
+		if card.name == "intro" {
+			segments = append(segments, card.labels(audio != ""))
+		}
+	}
+	segments = append(segments, clip)
+	for _, card := range cards {
+		if card.name == "outro" {
+			segments = append(segments, card.labels(audio != ""))
+		}
+	}
+
+	if audio == "" {
+		concat := fmt.Sprintf("%sconcat=n=%d:v=1:a=0[vbrand]", strings.Join(segments, ""), len(segments))
+		return strings.Join(append(filters, concat), ";"), "[vbrand]", ""
+	}
+	concat := fmt.Sprintf("%sconcat=n=%d:v=1:a=1[vbrand][abrand]", strings.Join(segments, ""), len(segments))
+	return strings.Join(append(filters, concat), ";"), "[vbrand]", "[abrand]"
+}
+
+// labels are the outputs of the card graph, as concat inputs
+func (card brandCard) labels(withAudio bool) string {
+	if withAudio {
+		return fmt.Sprintf("[%sv][%sa]", card.name, card.name)
+	}
+	return fmt.Sprintf("[%sv]", card.name)
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"shortgenerator/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	wavBytesPerSecond = 16000 * 2 // 16 kHz mono pcm_s16le, as extracted by TranscribeVideo
+	wavHeaderBytes    = 44
+
+	whisperMaxUploadBytes = 25 * 1000 * 1000
+	chunkOverlapSeconds   = 2
+)
+
+// maxChunkSeconds is the longest chunk target whose WAV, overlap included, still fits
+// the Whisper API upload limit
+func maxChunkSeconds(overlap float64) float64 {
+	return float64(whisperMaxUploadBytes-wavHeaderBytes)/wavBytesPerSecond - 2*overlap
+}
+
+// chunkedTranscriber splits long audio into overlapping chunks, preferably cut at
+// silences, transcribes them in parallel and stitches the results back together.
+// It exists because the Whisper API rejects uploads over 25 MB (~13 min of WAV).
+type chunkedTranscriber struct {
+	inner        Transcriber
+	ffmpegPath   string
+	chunkSeconds float64 // target chunk length
+	overlap      float64 // extra audio added on both sides of each cut
+	searchWindow float64 // how far before a target cut to look for silence
+	concurrency  int
+	attempts     int
+	progress     func(update ProgressUpdate)
+}
+
+func newChunkedTranscriber(inner Transcriber, ffmpegPath string) *chunkedTranscriber {
+	chunkSeconds, err := strconv.ParseFloat(getEnv("TRANSCRIBE_CHUNK_SECONDS", "600"), 64)
+	if err != nil || chunkSeconds < 60 {
+		chunkSeconds = 600
+	}
+	if limit := maxChunkSeconds(chunkOverlapSeconds); chunkSeconds > limit {
+		log.Printf("⚠️  TRANSCRIBE_CHUNK_SECONDS=%.0f exceeds the 25 MB Whisper limit, using %.0f", chunkSeconds, limit)
+		chunkSeconds = limit
+	}
+	concurrency, err := strconv.Atoi(getEnv("TRANSCRIBE_CONCURRENCY", "3"))
+	if err != nil || concurrency < 1 {
+		concurrency = 3
+	}
+
+	return &chunkedTranscriber{
+		inner:        inner,
+		ffmpegPath:   ffmpegPath,
+		chunkSeconds: chunkSeconds,
+		overlap:      chunkOverlapSeconds,
+		searchWindow: 60,
+		concurrency:  concurrency,
+		attempts:     2,
+		progress:     func(ProgressUpdate) {},
+	}
+}
+
+func (t *chunkedTranscriber) Name() string { return t.inner.Name() }
+
+// audioChunk is one slice of the source audio. [cutStart, cutEnd) is the range the
+// chunk owns when stitching; [start, end) adds the overlap that is actually sent.
+type audioChunk struct {
+	index    int
+	start    float64
+	end      float64
+	cutStart float64
+	cutEnd   float64
+	path     string
+}
+
+func (t *chunkedTranscriber) Transcribe(audioPath string, videoID string) (*models.Transcript, error) {
+	info, err := os.Stat(audioPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat audio file: %v", err)
+	}
+
+	duration := float64(info.Size()-wavHeaderBytes) / wavBytesPerSecond
+	if duration <= t.chunkSeconds {
+		return t.inner.Transcribe(audioPath, videoID)
+	}
+
+	silences, err := t.detectSilences(audioPath)
+	if err != nil {
+		log.Printf("⚠️  [%s] Silence detection failed, cutting at fixed intervals: %v", videoID, err)
+	}
+
+	cuts := planChunkCuts(duration, t.chunkSeconds, t.searchWindow, silences)
+	chunkDir, err := os.MkdirTemp(filepath.Dir(audioPath), videoID+"_chunks_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chunk directory: %v", err)
+	}
+	defer os.RemoveAll(chunkDir)
+
+	chunks := make([]audioChunk, len(cuts)-1)
+	for i := range chunks {
+		chunks[i] = audioChunk{
+			index:    i,
+			cutStart: cuts[i],
+			cutEnd:   cuts[i+1],
+			start:    maxFloat(0, cuts[i]-t.overlap),
+			end:      minFloat(duration, cuts[i+1]+t.overlap),
+			path:     filepath.Join(chunkDir, fmt.Sprintf("chunk_%03d.wav", i)),
+		}
+	}
+
+	log.Printf("✂️  [%s] Splitting %.0fs of audio into %d chunks (%d silences found)", videoID, duration, len(chunks), len(silences))
+
+	results := make([]*models.Transcript, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, t.concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	finished := 0
+
+	for i := range chunks {
+		wg.Add(1)
+		go func(chunk audioChunk) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[chunk.index], errs[chunk.index] = t.transcribeChunk(audioPath, videoID, chunk, len(chunks))
+
+			mu.Lock()
+			finished++
+			update := ProgressUpdate{
+				VideoID: videoID,
+				Stage:   StageTranscribe,
+				Percent: float64(finished) / float64(len(chunks)) * 100,
+				Chunk:   finished,
+				Chunks:  len(chunks),
+			}
+			mu.Unlock()
+			t.progress(update)
+		}(chunks[i])
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d/%d failed: %v", i+1, len(chunks), err)
+		}
+	}
+
+	return stitchChunkTranscripts(videoID, chunks, results), nil
+}
+
+func (t *chunkedTranscriber) transcribeChunk(audioPath, videoID string, chunk audioChunk, total int) (*models.Transcript, error) {
+	cmd := exec.Command(t.ffmpegPath,
+		"-y",
+		"-ss", fmt.Sprintf("%.3f", chunk.start),
+		"-t", fmt.Sprintf("%.3f", chunk.end-chunk.start),
+		"-i", audioPath,
+		"-acodec", "copy",
+		chunk.path,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to extract chunk: %v, output: %s", err, lastLines(string(output), 10))
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= t.attempts; attempt++ {
+		log.Printf("🎤 [%s] Transcribing chunk %d/%d (%.0fs - %.0fs, attempt %d)", videoID, chunk.index+1, total, chunk.start, chunk.end, attempt)
+		transcript, err := t.inner.Transcribe(chunk.path, videoID)
+		if err == nil {
+			return transcript, nil
+		}
+		lastErr = err
+		time.Sleep(time.Duration(attempt) * 2 * time.Second)
+	}
+
+	return nil, lastErr
+}
+
+var (
+	silenceStartRe = regexp.MustCompile(`silence_start: (-?[\d.]+)`)
+	silenceEndRe   = regexp.MustCompile(`silence_end: (-?[\d.]+)`)
+)
+
+// detectSilences runs ffmpeg silencedetect and returns the midpoint of every silence
+func (t *chunkedTranscriber) detectSilences(audioPath string) ([]float64, error) {
+	cmd := exec.Command(t.ffmpegPath,
+		"-hide_banner",
+		"-i", audioPath,
+		"-af", "silencedetect=noise=-35dB:d=0.4",
+		"-f", "null", "-",
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("silencedetect failed: %v", err)
+	}
+
+	return parseSilenceMidpoints(string(output)), nil
+}
+
+// parseSilenceMidpoints pairs silence_start/silence_end lines from ffmpeg output
+func parseSilenceMidpoints(output string) []float64 {
+	midpoints := []float64{}
+	start := -1.0
+
+	for _, line := range strings.Split(output, "\n") {
+		if m := silenceStartRe.FindStringSubmatch(line); m != nil {
+			start, _ = strconv.ParseFloat(m[1], 64)
+			if start < 0 {
+				start = 0
+			}
+			continue
+		}
+		if m := silenceEndRe.FindStringSubmatch(line); m != nil && start >= 0 {
+			end, _ := strconv.ParseFloat(m[1], 64)
+			midpoints = append(midpoints, (start+end)/2)
+			start = -1
+		}
+	}
+
+	sort.Float64s(midpoints)
+	return midpoints
+}
+
+// planChunkCuts returns cut points [0, c1, ..., duration]. Each cut is the silence
+// closest to (but not after) the target length, or the target itself if none is near.
+func planChunkCuts(duration, chunkSeconds, searchWindow float64, silences []float64) []float64 {
+	cuts := []float64{0}
+	last := 0.0
+
+	for duration-last > chunkSeconds {
+		target := last + chunkSeconds
+		cut := target
+		for _, silence := range silences {
+			if silence > target {
+				break
+			}
+			if silence >= target-searchWindow && silence > last {
+				cut = silence
+			}
+		}
+		cuts = append(cuts, cut)
+		last = cut
+	}
+
+	return append(cuts, duration)
+}
+
+// stitchChunkTranscripts shifts chunk timestamps back to the source timeline, keeps
+// segments starting inside each chunk's own range and removes text repeated across
+// the overlap. Overlap segments with no textual match are left to the earlier chunk.
+func stitchChunkTranscripts(videoID string, chunks []audioChunk, results []*models.Transcript) *models.Transcript {
+	transcript := &models.Transcript{
+		ID:        uuid.New().String(),
+		VideoID:   videoID,
+		Segments:  []models.Segment{},
+		CreatedAt: time.Now(),
+	}
+
+	for i, chunk := range chunks {
+		result := results[i]
+		if result == nil {
+			continue
+		}
+		if transcript.Language == "" {
+			transcript.Language = result.Language
+		}
+
+		for _, seg := range result.Segments {
+			seg.Start += chunk.start
+			seg.End += chunk.start
+			// Segments starting in the trailing overlap belong to the next chunk
+			if seg.Start >= chunk.cutEnd || seg.End <= chunk.cutStart {
+				continue
+			}
+
+			words := make([]models.Word, 0, len(seg.Words))
+			for _, w := range seg.Words {
+				w.Start += chunk.start
+				w.End += chunk.start
+				words = append(words, w)
+			}
+			seg.Words = words
+
+			// Segments in the leading overlap usually repeat the tail of the previous chunk
+			if n := len(transcript.Segments); n > 0 && seg.Start < chunk.cutStart+(chunk.cutStart-chunk.start) {
+				trimmed := trimRepeatedLead(segmentsTail(transcript.Segments, 3), seg)
+				if trimmed.Text == seg.Text && (seg.Start+seg.End)/2 < chunk.cutStart {
+					continue
+				}
+				if strings.TrimSpace(trimmed.Text) == "" {
+					continue
+				}
+				seg = trimmed
+			}
+
+			transcript.Segments = append(transcript.Segments, seg)
+		}
+	}
+
+	texts := make([]string, 0, len(transcript.Segments))
+	for _, seg := range transcript.Segments {
+		texts = append(texts, seg.Text)
+	}
+	transcript.FullText = strings.Join(texts, " ")
+
+	return transcript
+}
+
+// segmentsTail joins the text of the last n segments
+func segmentsTail(segments []models.Segment, n int) string {
+	if len(segments) > n {
+		segments = segments[len(segments)-n:]
+	}
+	texts := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		texts = append(texts, seg.Text)
+	}
+	return strings.Join(texts, " ")
+}
+
+// trimRepeatedLead drops leading words of next that repeat the trailing words of prev
+func trimRepeatedLead(prev string, next models.Segment) models.Segment {
+	prevWords := strings.Fields(prev)
+	nextWords := strings.Fields(next.Text)
+
+	maxOverlap := min(12, min(len(prevWords), len(nextWords)))
+	overlap := 0
+	for n := maxOverlap; n > 0; n-- {
+		match := true
+		for j := 0; j < n; j++ {
+			if normalizeWord(prevWords[len(prevWords)-n+j]) != normalizeWord(nextWords[j]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			overlap = n
+			break
+		}
+	}
+
+	if overlap == 0 {
+		return next
+	}
+
+	next.Text = strings.Join(nextWords[overlap:], " ")
+	if len(next.Words) == len(nextWords) {
+		next.Words = next.Words[overlap:]
+		if len(next.Words) > 0 {
+			next.Start = next.Words[0].Start
+		}
+	}
+
+	return next
+}
+
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.Trim(word, ".,;:!?¡¿\"'()…-"))
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
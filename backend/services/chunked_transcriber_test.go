@@ -0,0 +1,246 @@
+package services
+
+import (
+	"math"
+	"reflect"
+	"shortgenerator/models"
+	"testing"
+)
+
+func TestParseSilenceMidpoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []float64
+	}{
+		{
+			name:   "no silence",
+			output: "size=N/A time=00:10:00.00 bitrate=N/A speed= 512x\n",
+			want:   []float64{},
+		},
+		{
+			name: "pairs",
+			output: "[silencedetect @ 0x1] silence_start: 10.5\n" +
+				"[silencedetect @ 0x1] silence_end: 11.5 | silence_duration: 1\n" +
+				"[silencedetect @ 0x1] silence_start: 30\n" +
+				"[silencedetect @ 0x1] silence_end: 31 | silence_duration: 1\n",
+			want: []float64{11, 30.5},
+		},
+		{
+			name: "negative start at the beginning of the file",
+			output: "[silencedetect @ 0x1] silence_start: -0.02\n" +
+				"[silencedetect @ 0x1] silence_end: 2 | silence_duration: 2.02\n",
+			want: []float64{1},
+		},
+		{
+			name: "silence running to the end of the file has no end",
+			output: "[silencedetect @ 0x1] silence_start: 5\n" +
+				"[silencedetect @ 0x1] silence_end: 6 | silence_duration: 1\n" +
+				"[silencedetect @ 0x1] silence_start: 58\n",
+			want: []float64{5.5},
+		},
+		{
+			name:   "end without start",
+			output: "[silencedetect @ 0x1] silence_end: 6 | silence_duration: 1\n",
+			want:   []float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSilenceMidpoints(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSilenceMidpoints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanChunkCuts(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration float64
+		silences []float64
+		want     []float64
+	}{
+		{
+			name:     "shorter than a chunk",
+			duration: 200,
+			silences: []float64{100},
+			want:     []float64{0, 200},
+		},
+		{
+			name:     "exactly one chunk",
+			duration: 300,
+			want:     []float64{0, 300},
+		},
+		{
+			name:     "no silence cuts at the target",
+			duration: 700,
+			want:     []float64{0, 300, 600, 700},
+		},
+		{
+			name:     "silence outside the search window is ignored",
+			duration: 500,
+			silences: []float64{200, 320},
+			want:     []float64{0, 300, 500},
+		},
+		{
+			name:     "closest silence before the target wins",
+			duration: 500,
+			silences: []float64{275, 285, 295},
+			want:     []float64{0, 295, 500},
+		},
+		{
+			name:     "last chunk shorter than the search window",
+			duration: 600,
+			silences: []float64{290},
+			want:     []float64{0, 290, 590, 600},
+		},
+		{
+			name:     "silences are relative to the previous cut",
+			duration: 900,
+			silences: []float64{280, 560, 575},
+			want:     []float64{0, 280, 575, 875, 900},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planChunkCuts(tt.duration, 300, 30, tt.silences); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planChunkCuts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStitchChunkTranscripts(t *testing.T) {
+	// Two chunks cut at 10s with 2s of overlap on each side
+	chunks := []audioChunk{
+		{index: 0, start: 0, end: 12, cutStart: 0, cutEnd: 10},
+		{index: 1, start: 8, end: 20, cutStart: 10, cutEnd: 20},
+	}
+	results := []*models.Transcript{
+		{
+			Language: "es",
+			Segments: []models.Segment{
+				{Start: 0, End: 4, Text: "hola a todos", Words: []models.Word{
+					{Start: 0, End: 0.5, Text: "hola"}, {Start: 0.6, End: 0.8, Text: "a"}, {Start: 0.9, End: 1.4, Text: "todos"},
+				}},
+				{Start: 5, End: 9.8, Text: "bienvenidos al canal"},
+				// Starts in the trailing overlap, the next chunk has it
+				{Start: 10.5, End: 11.8, Text: "hoy vamos"},
+			},
+		},
+		{
+			Language: "es",
+			Segments: []models.Segment{
+				// Ends before the cut: only the previous chunk covers it
+				{Start: 0.2, End: 1.0, Text: "canal"},
+				// Mostly before the cut with no textual match: left to the previous chunk
+				{Start: 0.5, End: 2.5, Text: "algo distinto"},
+				// Repeats the tail of the previous chunk, which is trimmed with its words
+				{Start: 1.0, End: 3.0, Text: "al canal hoy vamos", Words: []models.Word{
+					{Start: 1.0, End: 1.3, Text: "al"}, {Start: 1.3, End: 1.8, Text: "canal"},
+					{Start: 2.4, End: 2.7, Text: "hoy"}, {Start: 2.7, End: 3.0, Text: "vamos"},
+				}},
+				{Start: 4, End: 6, Text: "a hablar de Go", Words: []models.Word{
+					{Start: 4, End: 4.2, Text: "a"}, {Start: 4.2, End: 4.8, Text: "hablar"},
+					{Start: 4.8, End: 5.1, Text: "de"}, {Start: 5.1, End: 6, Text: "Go"},
+				}},
+				// Past the end of the chunk's own range
+				{Start: 12.5, End: 13, Text: "fuera"},
+			},
+		},
+	}
+
+	got := stitchChunkTranscripts("video", chunks, results)
+
+	want := []models.Segment{
+		{Start: 0, End: 4, Text: "hola a todos", Words: []models.Word{
+			{Start: 0, End: 0.5, Text: "hola"}, {Start: 0.6, End: 0.8, Text: "a"}, {Start: 0.9, End: 1.4, Text: "todos"},
+		}},
+		{Start: 5, End: 9.8, Text: "bienvenidos al canal", Words: []models.Word{}},
+		{Start: 10.4, End: 11, Text: "hoy vamos", Words: []models.Word{
+			{Start: 10.4, End: 10.7, Text: "hoy"}, {Start: 10.7, End: 11, Text: "vamos"},
+		}},
+		{Start: 12, End: 14, Text: "a hablar de Go", Words: []models.Word{
+			{Start: 12, End: 12.2, Text: "a"}, {Start: 12.2, End: 12.8, Text: "hablar"},
+			{Start: 12.8, End: 13.1, Text: "de"}, {Start: 13.1, End: 14, Text: "Go"},
+		}},
+	}
+
+	if got.VideoID != "video" || got.Language != "es" {
+		t.Errorf("video %q, language %q", got.VideoID, got.Language)
+	}
+	if len(got.Segments) != len(want) {
+		t.Fatalf("got %d segments %+v, want %d", len(got.Segments), got.Segments, len(want))
+	}
+	for i := range want {
+		if !segmentsClose(got.Segments[i], want[i]) {
+			t.Errorf("segment %d = %+v, want %+v", i, got.Segments[i], want[i])
+		}
+	}
+	if wantText := "hola a todos bienvenidos al canal hoy vamos a hablar de Go"; got.FullText != wantText {
+		t.Errorf("FullText = %q, want %q", got.FullText, wantText)
+	}
+}
+
+func TestStitchChunkTranscriptsSkipsMissingChunks(t *testing.T) {
+	chunks := []audioChunk{
+		{index: 0, start: 0, end: 12, cutStart: 0, cutEnd: 10},
+		{index: 1, start: 8, end: 20, cutStart: 10, cutEnd: 20},
+	}
+	results := []*models.Transcript{
+		nil,
+		{Language: "en", Segments: []models.Segment{{Start: 3, End: 5, Text: "second"}}},
+	}
+
+	got := stitchChunkTranscripts("video", chunks, results)
+	if got.Language != "en" || len(got.Segments) != 1 || got.Segments[0].Start != 11 || got.Segments[0].End != 13 {
+		t.Errorf("got %+v", got)
+	}
+}
+
+// segmentsClose compares segments with a tolerance for float offsets
+func segmentsClose(a, b models.Segment) bool {
+	const eps = 1e-9
+	if a.Text != b.Text || math.Abs(a.Start-b.Start) > eps || math.Abs(a.End-b.End) > eps || len(a.Words) != len(b.Words) {
+		return false
+	}
+	for i := range a.Words {
+		if a.Words[i].Text != b.Words[i].Text || math.Abs(a.Words[i].Start-b.Words[i].Start) > eps || math.Abs(a.Words[i].End-b.Words[i].End) > eps {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNewChunkedTranscriberChunkSeconds(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  float64
+	}{
+		{"default", "", 600},
+		{"custom", "300", 300},
+		{"too short", "30", 600},
+		{"invalid", "ten", 600},
+		{"over the upload limit", "1800", maxChunkSeconds(chunkOverlapSeconds)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRANSCRIBE_CHUNK_SECONDS", tt.value)
+			transcriber := newChunkedTranscriber(nil, "ffmpeg")
+			if transcriber.chunkSeconds != tt.want {
+				t.Errorf("chunkSeconds = %v, want %v", transcriber.chunkSeconds, tt.want)
+			}
+
+			// The largest chunk sent is the target plus the overlap on both sides
+			size := wavHeaderBytes + (transcriber.chunkSeconds+2*transcriber.overlap)*wavBytesPerSecond
+			if size > whisperMaxUploadBytes {
+				t.Errorf("largest chunk is %.0f bytes, over the %d byte limit", size, whisperMaxUploadBytes)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"shortgenerator/models"
+)
+
+// analysisPrompt resolves the clip analysis template selected for the video and the
+// variables shared by every call made while analyzing it
+func (s *ProcessingService) analysisPrompt(video *models.Video) (*PromptTemplate, PromptVars, error) {
+	name := video.PromptTemplate
+	if name == "" {
+		name = getEnv("ANALYSIS_PROMPT_TEMPLATE", PromptClipAnalysis)
+	}
+
+	tmpl, err := s.prompts.Get(name)
+	if err != nil {
+		return nil, PromptVars{}, err
+	}
+
+	return tmpl, s.basePromptVars(video), nil
+}
+
+// basePromptVars fills language, platform and duration rules from the video's
+// analysis options, falling back to the server defaults
+func (s *ProcessingService) basePromptVars(video *models.Video) PromptVars {
+	language := video.OutputLanguage
+	if language == "" {
+		language = getEnv("DEFAULT_OUTPUT_LANGUAGE", "es")
+	}
+	platform := video.Platform
+	if platform == "" {
+		platform = getEnv("DEFAULT_PLATFORM", "")
+	}
+
+	bounds := loadClipBounds()
+	minDuration := int(bounds.minDuration)
+	maxDuration := int(bounds.maxDuration)
+
+	return PromptVars{
+		Language:      languageName(language),
+		LanguageCode:  language,
+		Platform:      platformName(platform),
+		ClipCount:     "5-8",
+		MinDuration:   minDuration,
+		IdealDuration: fmt.Sprintf("%d-%d", maxInt(minDuration, maxDuration/2), maxInt(minDuration, maxDuration*3/4)),
+		MaxDuration:   maxDuration,
+	}
+}
+
+// parseSuggestedClips extracts the clip array from a model answer. An empty array is
+// an error: asked for the clips of a whole video, the model should find some.
+func parseSuggestedClips(provider, content string) ([]models.SuggestedClip, error) {
+	clips, err := decodeSuggestedClips(provider, content)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(clips) == 0 {
+		return nil, fmt.Errorf("%s returned empty clips array", provider)
+	}
+
+	log.Printf("✅ Successfully parsed %d clips from %s", len(clips), provider)
+	return clips, nil
+}
+
+// decodeSuggestedClips parses the clip array of a model answer, which may be empty
+func decodeSuggestedClips(provider, content string) ([]models.SuggestedClip, error) {
+	cleaned := extractJSON(content)
+	log.Printf("🔍 Cleaned %s content for parsing (first 300 chars): %s", provider, cleaned[:min(300, len(cleaned))])
+
+	var clips []models.SuggestedClip
+	if err := json.Unmarshal([]byte(cleaned), &clips); err != nil {
+		log.Printf("❌ Content that failed to parse: %s", cleaned)
+		return nil, fmt.Errorf("failed to parse %s clips response: %v", provider, err)
+	}
+
+	for i := range clips {
+		normalizeClipSegments(&clips[i])
+	}
+
+	return clips, nil
+}
+
+// normalizeClipSegments turns a multi-part answer with a single part into a plain
+// clip and sets the span of the parts as the clip start and end
+func normalizeClipSegments(clip *models.SuggestedClip) {
+	switch len(clip.Segments) {
+	case 0:
+		return
+	case 1:
+		clip.StartTime, clip.EndTime = clip.Segments[0].StartTime, clip.Segments[0].EndTime
+		clip.Segments = nil
+		return
+	}
+
+	clip.StartTime, clip.EndTime = clip.Segments[0].StartTime, clip.Segments[0].EndTime
+	for _, seg := range clip.Segments[1:] {
+		clip.StartTime = minFloat(clip.StartTime, seg.StartTime)
+		clip.EndTime = maxFloat(clip.EndTime, seg.EndTime)
+	}
+}
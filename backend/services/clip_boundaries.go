@@ -0,0 +1,322 @@
+package services
+
+import (
+	"log"
+	"math"
+	"shortgenerator/models"
+	"strconv"
+	"strings"
+)
+
+// clipBounds are the duration rules every suggested clip must satisfy
+type clipBounds struct {
+	minDuration float64
+	maxDuration float64
+	snapWindow  float64 // how far a boundary may move to reach a segment or word edge
+	sceneWindow float64 // how far a boundary may move outwards to reach a scene cut
+}
+
+func loadClipBounds() clipBounds {
+	bounds := clipBounds{minDuration: 15, maxDuration: 60, snapWindow: 4, sceneWindow: 1.5}
+
+	if v, err := strconv.ParseFloat(getEnv("CLIP_MIN_DURATION", "15"), 64); err == nil && v > 0 {
+		bounds.minDuration = v
+	}
+	if v, err := strconv.ParseFloat(getEnv("CLIP_MAX_DURATION", "60"), 64); err == nil && v > bounds.minDuration {
+		bounds.maxDuration = v
+	}
+
+	return bounds
+}
+
+// endsSentence reports whether text closes a sentence
+func endsSentence(text string) bool {
+	text = strings.TrimRight(strings.TrimSpace(text), "\"'»”)")
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "?") || strings.HasSuffix(text, "!") || strings.HasSuffix(text, "…")
+}
+
+// clipBoundaryIndex holds the candidate cut points of a transcript
+type clipBoundaryIndex struct {
+	segmentStarts  []float64
+	segmentEnds    []float64
+	sentenceStarts []float64
+	sentenceEnds   []float64
+	wordStarts     []float64
+	wordEnds       []float64
+	sceneCuts      []float64
+}
+
+func newClipBoundaryIndex(segments []models.Segment) clipBoundaryIndex {
+	index := clipBoundaryIndex{}
+	for i, seg := range segments {
+		index.segmentStarts = append(index.segmentStarts, seg.Start)
+		index.segmentEnds = append(index.segmentEnds, seg.End)
+		if i == 0 || endsSentence(segments[i-1].Text) {
+			index.sentenceStarts = append(index.sentenceStarts, seg.Start)
+		}
+		if endsSentence(seg.Text) {
+			index.sentenceEnds = append(index.sentenceEnds, seg.End)
+		}
+		for _, w := range seg.Words {
+			index.wordStarts = append(index.wordStarts, w.Start)
+			index.wordEnds = append(index.wordEnds, w.End)
+		}
+	}
+	return index
+}
+
+// nearest returns the point closest to t within window, if any
+func nearest(points []float64, t, window float64) (float64, bool) {
+	best, found := 0.0, false
+	for _, p := range points {
+		if math.Abs(p-t) <= window && (!found || math.Abs(p-t) < math.Abs(best-t)) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// snap moves t to the closest sentence edge, then segment edge, then word edge
+func snap(t, window float64, candidates ...[]float64) float64 {
+	for _, points := range candidates {
+		if p, ok := nearest(points, t, window); ok {
+			return p
+		}
+	}
+	return t
+}
+
+// lastAtOrBefore returns the latest point in [from, to]
+func lastAtOrBefore(points []float64, from, to float64) (float64, bool) {
+	best, found := 0.0, false
+	for _, p := range points {
+		if p >= from && p <= to && (!found || p > best) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// firstAtOrAfter returns the earliest point in [from, to]
+func firstAtOrAfter(points []float64, from, to float64) (float64, bool) {
+	best, found := 0.0, false
+	for _, p := range points {
+		if p >= from && p <= to && (!found || p < best) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// speechBetween reports whether a word (or, without word timings, a segment) is spoken
+// between from and to
+func (index clipBoundaryIndex) speechBetween(from, to float64) bool {
+	starts, ends := index.wordStarts, index.wordEnds
+	if len(starts) == 0 {
+		starts, ends = index.segmentStarts, index.segmentEnds
+	}
+	for i := range starts {
+		if starts[i] < to && ends[i] > from {
+			return true
+		}
+	}
+	return false
+}
+
+// nudgeToSceneCuts moves the start back to the cut that opens its shot and the end
+// forward to the cut that closes it, when the cut is within the scene window and no
+// speech lies in between, so clips neither start mid-shot nor gain or lose words
+func nudgeToSceneCuts(start, end float64, bounds clipBounds, index clipBoundaryIndex) (float64, float64) {
+	const margin = 0.05 // cut detection is frame accurate, speech timings are not
+	if cut, ok := lastAtOrBefore(index.sceneCuts, start-bounds.sceneWindow, start); ok && !index.speechBetween(cut, start-margin) {
+		start = cut
+	}
+	if cut, ok := firstAtOrAfter(index.sceneCuts, end, end+bounds.sceneWindow); ok && !index.speechBetween(end+margin, cut) {
+		end = cut
+	}
+	return start, end
+}
+
+// nudgeSuggestionsToSceneCuts moves stored single-range suggestions onto scene cuts
+// detected after the analysis ran, and returns the clips it changed. A nudge that
+// would make a clip longer than the maximum is skipped.
+func nudgeSuggestionsToSceneCuts(clips []models.SuggestedClip, transcript *models.Transcript, sceneCuts []float64) []models.SuggestedClip {
+	bounds := loadClipBounds()
+	index := newClipBoundaryIndex(transcript.Segments)
+	index.sceneCuts = sceneCuts
+
+	changed := []models.SuggestedClip{}
+	for _, clip := range clips {
+		if len(clip.Segments) > 1 {
+			continue
+		}
+		start, end := nudgeToSceneCuts(clip.StartTime, clip.EndTime, bounds, index)
+		if (start == clip.StartTime && end == clip.EndTime) || end-start > bounds.maxDuration {
+			continue
+		}
+
+		log.Printf("🎞️  Moved suggested clip %q onto scene cuts: %.1f-%.1f → %.1f-%.1f", clip.Title, clip.StartTime, clip.EndTime, start, end)
+		clip.StartTime, clip.EndTime = start, end
+		changed = append(changed, clip)
+	}
+
+	return changed
+}
+
+// refineSuggestedClips validates LLM suggestions against the video: boundaries snap to
+// sentence, segment or word edges and then to nearby scene cuts, durations are repaired
+// into [min, max] and clips that cannot be repaired or repeat another clip are dropped
+// instead of being persisted.
+func refineSuggestedClips(clips []models.SuggestedClip, transcript *models.Transcript, videoDuration float64, sceneCuts []float64) []models.SuggestedClip {
+	bounds := loadClipBounds()
+	index := newClipBoundaryIndex(transcript.Segments)
+	index.sceneCuts = sceneCuts
+	if videoDuration <= 0 {
+		videoDuration = transcriptDuration(transcript)
+	}
+
+	refined := []models.SuggestedClip{}
+	for _, clip := range clips {
+		if len(clip.Segments) > 1 {
+			if multi, ok := refineMultiPartClip(clip, videoDuration, bounds, index); ok {
+				refined = append(refined, multi)
+			}
+			continue
+		}
+
+		start, end := clip.StartTime, clip.EndTime
+		if math.IsNaN(start) || math.IsNaN(end) || end <= start || start >= videoDuration || end <= 0 {
+			log.Printf("⚠️  Dropping invalid suggested clip %q (%.1f - %.1f, video %.1fs)", clip.Title, start, end, videoDuration)
+			continue
+		}
+
+		start = math.Max(0, start)
+		end = math.Min(videoDuration, end)
+
+		start = snap(start, bounds.snapWindow, index.sentenceStarts, index.segmentStarts, index.wordStarts)
+		end = snap(end, bounds.snapWindow, index.sentenceEnds, index.segmentEnds, index.wordEnds)
+		start, end = nudgeToSceneCuts(start, end, bounds, index)
+		end = math.Min(videoDuration, end)
+
+		start, end, ok := repairClipDuration(start, end, videoDuration, bounds, index)
+		if !ok {
+			log.Printf("⚠️  Dropping suggested clip %q: cannot fit %.0f-%.0fs (%.1f - %.1f)", clip.Title, bounds.minDuration, bounds.maxDuration, clip.StartTime, clip.EndTime)
+			continue
+		}
+
+		if start != clip.StartTime || end != clip.EndTime {
+			log.Printf("📐 Adjusted suggested clip %q: %.1f-%.1f → %.1f-%.1f", clip.Title, clip.StartTime, clip.EndTime, start, end)
+		}
+
+		clip.StartTime = start
+		clip.EndTime = end
+		refined = append(refined, clip)
+	}
+
+	return mergeCandidateClips(refined)
+}
+
+// minClipSegmentLength drops parts of a multi-part clip too short to follow
+const minClipSegmentLength = 2.0
+
+// refineMultiPartClip snaps every part of a multi-part clip to sentence, segment or
+// word edges and drops parts that are invalid, too short or overlap an earlier one.
+// The total length must fit the clip bounds; parts are not stretched to reach them.
+// A clip left with one part continues as a plain clip.
+func refineMultiPartClip(clip models.SuggestedClip, videoDuration float64, bounds clipBounds, index clipBoundaryIndex) (models.SuggestedClip, bool) {
+	segments := []models.ClipSegment{}
+	for _, seg := range clip.Segments {
+		start, end := seg.StartTime, seg.EndTime
+		if math.IsNaN(start) || math.IsNaN(end) || end <= start || start >= videoDuration || end <= 0 {
+			continue
+		}
+		start = snap(math.Max(0, start), bounds.snapWindow, index.sentenceStarts, index.segmentStarts, index.wordStarts)
+		end = math.Min(videoDuration, snap(math.Min(videoDuration, end), bounds.snapWindow, index.sentenceEnds, index.segmentEnds, index.wordEnds))
+		if end-start < minClipSegmentLength {
+			continue
+		}
+
+		overlaps := false
+		for _, kept := range segments {
+			if start < kept.EndTime && end > kept.StartTime {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			segments = append(segments, models.ClipSegment{StartTime: start, EndTime: end})
+		}
+	}
+
+	if len(segments) == 0 {
+		log.Printf("⚠️  Dropping suggested clip %q: no valid parts", clip.Title)
+		return clip, false
+	}
+	if len(segments) == 1 {
+		clip.Segments = nil
+		clip.StartTime, clip.EndTime = segments[0].StartTime, segments[0].EndTime
+		start, end, ok := repairClipDuration(clip.StartTime, clip.EndTime, videoDuration, bounds, index)
+		if !ok {
+			log.Printf("⚠️  Dropping suggested clip %q: cannot fit %.0f-%.0fs", clip.Title, bounds.minDuration, bounds.maxDuration)
+			return clip, false
+		}
+		clip.StartTime, clip.EndTime = start, end
+		return clip, true
+	}
+
+	if total := rangesDuration(segments); total < bounds.minDuration || total > bounds.maxDuration {
+		log.Printf("⚠️  Dropping suggested clip %q: %d parts last %.1fs, outside %.0f-%.0fs", clip.Title, len(segments), total, bounds.minDuration, bounds.maxDuration)
+		return clip, false
+	}
+
+	clip.Segments = segments
+	normalizeClipSegments(&clip)
+	log.Printf("🧩 Multi-part suggested clip %q: %d parts, %.1fs", clip.Title, len(segments), rangesDuration(segments))
+	return clip, true
+}
+
+// repairClipDuration stretches short clips to the next sentence end (or back to an
+// earlier sentence start) and trims long ones to the last sentence end that still fits
+func repairClipDuration(start, end, videoDuration float64, bounds clipBounds, index clipBoundaryIndex) (float64, float64, bool) {
+	if end-start > bounds.maxDuration {
+		limit := start + bounds.maxDuration
+		from := start + bounds.minDuration
+		if p, ok := lastAtOrBefore(index.sentenceEnds, from, limit); ok {
+			end = p
+		} else if p, ok := lastAtOrBefore(index.segmentEnds, from, limit); ok {
+			end = p
+		} else if p, ok := lastAtOrBefore(index.wordEnds, from, limit); ok {
+			end = p
+		} else {
+			end = limit
+		}
+	}
+
+	if end-start < bounds.minDuration {
+		from := start + bounds.minDuration
+		limit := math.Min(videoDuration, start+bounds.maxDuration)
+		if p, ok := firstAtOrAfter(index.sentenceEnds, from, limit); ok {
+			end = p
+		} else if p, ok := firstAtOrAfter(index.segmentEnds, from, limit); ok {
+			end = p
+		} else {
+			end = math.Min(limit, from)
+		}
+	}
+
+	if end-start < bounds.minDuration {
+		// Near the end of the video: grow backwards instead
+		from := math.Max(0, end-bounds.maxDuration)
+		if p, ok := lastAtOrBefore(index.sentenceStarts, from, end-bounds.minDuration); ok {
+			start = p
+		} else if p, ok := lastAtOrBefore(index.segmentStarts, from, end-bounds.minDuration); ok {
+			start = p
+		} else {
+			start = math.Max(0, end-bounds.minDuration)
+		}
+	}
+
+	duration := end - start
+	const epsilon = 0.01
+	return start, end, duration >= bounds.minDuration-epsilon && duration <= bounds.maxDuration+epsilon
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"shortgenerator/models"
+	"testing"
+)
+
+// boundaryTranscript is 120s of speech in 5s segments separated by half a second of
+// silence. Sentences span two segments: they start at multiples of 10s and end at
+// 9.5, 19.5, 29.5...
+func boundaryTranscript() *models.Transcript {
+	transcript := &models.Transcript{}
+	for i := 0; i < 24; i++ {
+		text := fmt.Sprintf("frase %d", i)
+		if i%2 == 1 {
+			text += "."
+		}
+		start := float64(i * 5)
+		transcript.Segments = append(transcript.Segments, models.Segment{Start: start, End: start + 4.5, Text: text})
+	}
+	return transcript
+}
+
+func testClipBounds(t *testing.T) clipBounds {
+	t.Setenv("CLIP_MIN_DURATION", "15")
+	t.Setenv("CLIP_MAX_DURATION", "60")
+	return loadClipBounds()
+}
+
+func TestSnap(t *testing.T) {
+	sentences := []float64{0, 10, 20}
+	segments := []float64{0, 5, 10, 15, 20}
+	words := []float64{0, 1.2, 2.4, 13.1, 16.9}
+
+	tests := []struct {
+		name   string
+		t      float64
+		window float64
+		want   float64
+	}{
+		{name: "sentence edge first", t: 11, window: 4, want: 10},
+		{name: "segment edge when no sentence is near", t: 13, window: 2, want: 15},
+		{name: "word edge when no segment is near", t: 13.4, window: 1, want: 13.1},
+		{name: "closest sentence edge", t: 16, window: 5, want: 20},
+		{name: "tie keeps the earlier edge", t: 15, window: 5, want: 10},
+		{name: "nothing within the window", t: 50, window: 4, want: 50},
+		{name: "on an edge", t: 20, window: 4, want: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snap(tt.t, tt.window, sentences, segments, words); got != tt.want {
+				t.Errorf("snap(%v, %v) = %v, want %v", tt.t, tt.window, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNudgeToSceneCuts(t *testing.T) {
+	bounds := testClipBounds(t)
+	index := newClipBoundaryIndex(boundaryTranscript().Segments)
+
+	tests := []struct {
+		name      string
+		cuts      []float64
+		start     float64
+		end       float64
+		wantStart float64
+		wantEnd   float64
+	}{
+		{name: "no cuts", start: 10, end: 29.5, wantStart: 10, wantEnd: 29.5},
+		{name: "cuts in the silence", cuts: []float64{9.7, 29.8}, start: 10, end: 29.5, wantStart: 9.7, wantEnd: 29.8},
+		{name: "cut across speech keeps the start", cuts: []float64{9}, start: 10, end: 29.5, wantStart: 10, wantEnd: 29.5},
+		{name: "cut across speech keeps the end", cuts: []float64{30.5}, start: 10, end: 29.5, wantStart: 10, wantEnd: 29.5},
+		{name: "cuts outside the scene window", cuts: []float64{8.4, 31.1}, start: 10, end: 29.5, wantStart: 10, wantEnd: 29.5},
+		{name: "latest cut before the start", cuts: []float64{9.6, 9.8}, start: 10, end: 29.5, wantStart: 9.8, wantEnd: 29.5},
+		{name: "cuts never move inwards", cuts: []float64{10.2, 29.3}, start: 10, end: 29.5, wantStart: 10, wantEnd: 29.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index.sceneCuts = tt.cuts
+			start, end := nudgeToSceneCuts(tt.start, tt.end, bounds, index)
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("nudgeToSceneCuts() = %v, %v; want %v, %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestNudgeSuggestionsToSceneCuts(t *testing.T) {
+	testClipBounds(t)
+	clips := []models.SuggestedClip{
+		{ID: "cuts in the silence", StartTime: 10, EndTime: 29.5},
+		{ID: "no cut nearby", StartTime: 40, EndTime: 49.5},
+		{ID: "multi-part", StartTime: 10, EndTime: 49.5, Segments: []models.ClipSegment{{StartTime: 10, EndTime: 19.5}, {StartTime: 40, EndTime: 49.5}}},
+		{ID: "would exceed the maximum", StartTime: 60, EndTime: 119.5},
+	}
+
+	got := nudgeSuggestionsToSceneCuts(clips, boundaryTranscript(), []float64{9.7, 29.8, 59.7, 119.8})
+	want := []models.SuggestedClip{{ID: "cuts in the silence", StartTime: 9.7, EndTime: 29.8}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nudgeSuggestionsToSceneCuts() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRepairClipDuration(t *testing.T) {
+	bounds := testClipBounds(t)
+	index := newClipBoundaryIndex(boundaryTranscript().Segments)
+
+	tests := []struct {
+		name          string
+		start, end    float64
+		videoDuration float64
+		wantStart     float64
+		wantEnd       float64
+		wantOK        bool
+	}{
+		{name: "within bounds", start: 10, end: 39.5, videoDuration: 120, wantStart: 10, wantEnd: 39.5, wantOK: true},
+		{name: "too long trims to the last sentence end", start: 0, end: 80, videoDuration: 120, wantStart: 0, wantEnd: 59.5, wantOK: true},
+		{name: "too short grows to the next sentence end", start: 10, end: 19.5, videoDuration: 120, wantStart: 10, wantEnd: 29.5, wantOK: true},
+		{name: "near the end grows backwards", start: 110, end: 119.5, videoDuration: 120, wantStart: 100, wantEnd: 120, wantOK: true},
+		{name: "video shorter than the minimum", start: 0, end: 9.5, videoDuration: 10, wantStart: 0, wantEnd: 10, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := repairClipDuration(tt.start, tt.end, tt.videoDuration, bounds, index)
+			if start != tt.wantStart || end != tt.wantEnd || ok != tt.wantOK {
+				t.Errorf("repairClipDuration() = %v, %v, %v; want %v, %v, %v", start, end, ok, tt.wantStart, tt.wantEnd, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRefineSuggestedClips(t *testing.T) {
+	testClipBounds(t)
+	transcript := boundaryTranscript()
+
+	tests := []struct {
+		name      string
+		clips     []models.SuggestedClip
+		sceneCuts []float64
+		want      []models.SuggestedClip
+	}{
+		{
+			name:  "boundaries snap to sentences",
+			clips: []models.SuggestedClip{{Title: "a", StartTime: 11, EndTime: 38}},
+			want:  []models.SuggestedClip{{Title: "a", StartTime: 10, EndTime: 39.5}},
+		},
+		{
+			name:      "then move out to scene cuts",
+			clips:     []models.SuggestedClip{{Title: "a", StartTime: 11, EndTime: 38}},
+			sceneCuts: []float64{9.7, 39.9},
+			want:      []models.SuggestedClip{{Title: "a", StartTime: 9.7, EndTime: 39.9}},
+		},
+		{
+			name:  "long clips are trimmed",
+			clips: []models.SuggestedClip{{Title: "a", StartTime: 40, EndTime: 115}},
+			want:  []models.SuggestedClip{{Title: "a", StartTime: 40, EndTime: 99.5}},
+		},
+		{
+			name:  "clips past the video are clamped",
+			clips: []models.SuggestedClip{{Title: "a", StartTime: 83, EndTime: 300}},
+			want:  []models.SuggestedClip{{Title: "a", StartTime: 80, EndTime: 119.5}},
+		},
+		{
+			name: "invalid clips are dropped",
+			clips: []models.SuggestedClip{
+				{Title: "reversed", StartTime: 40, EndTime: 30},
+				{Title: "after the end", StartTime: 130, EndTime: 150},
+				{Title: "before the start", StartTime: -20, EndTime: 0},
+			},
+			want: []models.SuggestedClip{},
+		},
+		{
+			name: "repeated moments keep the best score",
+			clips: []models.SuggestedClip{
+				{Title: "low", StartTime: 12, EndTime: 40, Score: 60},
+				{Title: "high", StartTime: 11, EndTime: 38, Score: 90},
+				{Title: "other", StartTime: 60, EndTime: 90, Score: 70},
+			},
+			want: []models.SuggestedClip{
+				{Title: "high", StartTime: 10, EndTime: 39.5, Score: 90},
+				{Title: "other", StartTime: 60, EndTime: 89.5, Score: 70},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := refineSuggestedClips(tt.clips, transcript, 120, tt.sceneCuts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("refineSuggestedClips() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefineMultiPartClip(t *testing.T) {
+	bounds := testClipBounds(t)
+	index := newClipBoundaryIndex(boundaryTranscript().Segments)
+
+	tests := []struct {
+		name   string
+		parts  []models.ClipSegment
+		want   models.SuggestedClip
+		wantOK bool
+	}{
+		{
+			name:  "parts snap and keep their order",
+			parts: []models.ClipSegment{{StartTime: 50.5, EndTime: 59}, {StartTime: 20.2, EndTime: 29}},
+			want: models.SuggestedClip{Title: "m", StartTime: 20, EndTime: 59.5, Segments: []models.ClipSegment{
+				{StartTime: 50, EndTime: 59.5}, {StartTime: 20, EndTime: 29.5},
+			}},
+			wantOK: true,
+		},
+		{
+			name: "short, overlapping and invalid parts are dropped",
+			parts: []models.ClipSegment{
+				{StartTime: 50.5, EndTime: 59},
+				{StartTime: 70, EndTime: 71},
+				{StartTime: 55, EndTime: 65},
+				{StartTime: 200, EndTime: 210},
+				{StartTime: 20.2, EndTime: 29},
+			},
+			want: models.SuggestedClip{Title: "m", StartTime: 20, EndTime: 59.5, Segments: []models.ClipSegment{
+				{StartTime: 50, EndTime: 59.5}, {StartTime: 20, EndTime: 29.5},
+			}},
+			wantOK: true,
+		},
+		{
+			name:   "one part left is a plain clip, repaired",
+			parts:  []models.ClipSegment{{StartTime: 50.5, EndTime: 59}, {StartTime: 200, EndTime: 210}},
+			want:   models.SuggestedClip{Title: "m", StartTime: 50, EndTime: 69.5},
+			wantOK: true,
+		},
+		{
+			name:  "parts too short together",
+			parts: []models.ClipSegment{{StartTime: 50, EndTime: 54.5}, {StartTime: 20, EndTime: 24.5}},
+		},
+		{
+			name:  "parts too long together",
+			parts: []models.ClipSegment{{StartTime: 0, EndTime: 39.5}, {StartTime: 60, EndTime: 99.5}},
+		},
+		{
+			name:  "no valid part",
+			parts: []models.ClipSegment{{StartTime: 30, EndTime: 20}, {StartTime: 200, EndTime: 210}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clip := models.SuggestedClip{Title: "m", Segments: tt.parts}
+			got, ok := refineMultiPartClip(clip, 120, bounds, index)
+			if ok != tt.wantOK {
+				t.Fatalf("refineMultiPartClip() ok = %v, want %v (%+v)", ok, tt.wantOK, got)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("refineMultiPartClip() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
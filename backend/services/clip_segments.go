@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"shortgenerator/models"
+	"strings"
+)
+
+// maxClipSegments limits how many source ranges one clip can stitch together
+const maxClipSegments = 10
+
+// ValidateClipSegments checks the source ranges of a clip. With segments, start and
+// end are set to their span; without them the clip is the single range start-end.
+func ValidateClipSegments(start, end *float64, segments []models.ClipSegment) error {
+	if len(segments) == 0 {
+		if *start < 0 || *end <= *start {
+			return fmt.Errorf("end_time must be greater than start_time")
+		}
+		return nil
+	}
+	if len(segments) > maxClipSegments {
+		return fmt.Errorf("too many segments (%d), at most %d", len(segments), maxClipSegments)
+	}
+
+	*start, *end = segments[0].StartTime, segments[0].EndTime
+	for i, seg := range segments {
+		if seg.StartTime < 0 || seg.EndTime <= seg.StartTime {
+			return fmt.Errorf("segment %d: end_time must be greater than start_time", i)
+		}
+		*start = math.Min(*start, seg.StartTime)
+		*end = math.Max(*end, seg.EndTime)
+	}
+	return nil
+}
+
+// SourceRanges returns the source ranges a clip plays, in order
+func SourceRanges(start, end float64, segments []models.ClipSegment) []models.ClipSegment {
+	if len(segments) == 0 {
+		return []models.ClipSegment{{StartTime: start, EndTime: end}}
+	}
+	return segments
+}
+
+// rangesDuration is the length of the ranges played back to back
+func rangesDuration(ranges []models.ClipSegment) float64 {
+	total := 0.0
+	for _, r := range ranges {
+		total += r.EndTime - r.StartTime
+	}
+	return total
+}
+
+// clipPart is one source range of a clip as it is rendered
+type clipPart struct {
+	start      float64      // source seconds
+	timeline   clipTimeline // what is kept of the range, relative to start
+	cropCenter string       // reframing crop center, an expression of t relative to start
+}
+
+// clipParts are the parts of a clip in playback order. Clip time runs over the
+// source ranges played back to back; export time is what is left of it once each
+// part's timeline has removed silence or filler words.
+type clipParts []clipPart
+
+// outputDuration is the length of the export
+func (parts clipParts) outputDuration() float64 {
+	total := 0.0
+	for _, part := range parts {
+		total += part.timeline.outputDuration()
+	}
+	return total
+}
+
+// removedSeconds is how much the part timelines cut
+func (parts clipParts) removedSeconds() float64 {
+	total := 0.0
+	for _, part := range parts {
+		total += part.timeline.removedSeconds()
+	}
+	return total
+}
+
+// remap converts a clip time to the export time
+func (parts clipParts) remap(t float64) float64 {
+	offset, output := 0.0, 0.0
+	for i, part := range parts {
+		if t < offset+part.timeline.duration || i == len(parts)-1 {
+			return output + part.timeline.remap(t-offset)
+		}
+		offset += part.timeline.duration
+		output += part.timeline.outputDuration()
+	}
+	return output
+}
+
+// remapSubtitles moves subtitles from clip time to export time
+func (parts clipParts) remapSubtitles(subtitles []models.SubtitleConfig) []models.SubtitleConfig {
+	if parts.isPlain() {
+		return subtitles
+	}
+	return remapSubtitles(subtitles, parts.remap)
+}
+
+// isPlain reports whether the export is the clip time unchanged
+func (parts clipParts) isPlain() bool {
+	return len(parts) == 1 && parts[0].timeline.isFull()
+}
+
+// inputArgs opens the source once per part, seeking to its range, so ffmpeg only
+// decodes what the clip plays
+func (parts clipParts) inputArgs(inputPath string) []string {
+	var args []string
+	for _, part := range parts {
+		args = append(args,
+			"-ss", fmt.Sprintf("%.3f", part.start),
+			"-t", fmt.Sprintf("%.3f", part.timeline.duration),
+			"-i", inputPath,
+		)
+	}
+	return args
+}
+
+// videoGraph selects and retimes the frames of every part and joins them into [vc].
+// Crop framing is applied per part because the reframing crop follows each part's
+// own time; fitted framings do not move, so they are applied once after the join.
+func (parts clipParts) videoGraph(profile outputProfile) string {
+	graph := []string{}
+	joined := ""
+	for i, part := range parts {
+		filters := []string{part.timeline.videoSelect()}
+		if !profile.fitsWholeFrame() {
+			partProfile := profile
+			partProfile.cropCenter = part.cropCenter
+			filters = append(filters, partProfile.videoFilter())
+		}
+		filters = append(filters, part.timeline.videoSetPTS())
+
+		chain := joinFilters(filters...)
+		if chain == "" {
+			chain = "null"
+		}
+		graph = append(graph, fmt.Sprintf("[%d:v]%s[v%d]", i, chain, i))
+		joined += fmt.Sprintf("[v%d]", i)
+	}
+
+	concat := fmt.Sprintf("%sconcat=n=%d:v=1:a=0", joined, len(parts))
+	if profile.fitsWholeFrame() {
+		concat += "," + profile.videoFilter()
+	}
+	return strings.Join(append(graph, concat+"[vc]"), ";")
+}
+
+// audioGraph selects the samples of every part and joins them into [ac]
+func (parts clipParts) audioGraph() string {
+	graph := []string{}
+	joined := ""
+	for i, part := range parts {
+		chain := part.timeline.audioSelect()
+		if chain == "" {
+			chain = "anull"
+		}
+		graph = append(graph, fmt.Sprintf("[%d:a]%s[a%d]", i, chain, i))
+		joined += fmt.Sprintf("[a%d]", i)
+	}
+	return strings.Join(append(graph, fmt.Sprintf("%sconcat=n=%d:v=0:a=1[ac]", joined, len(parts))), ";")
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"shortgenerator/models"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,48 +18,74 @@ func NewClipService(db *sql.DB) *ClipService {
 	return &ClipService{db: db}
 }
 
+// clipColumns is the SELECT list matching scanClip
+const clipColumns = `id, video_id, title, start_time, end_time, COALESCE(file_path, ''), status, COALESCE(error_message, ''),
+			  subtitles, COALESCE(render_options, '{}'), COALESCE(segments, '[]'), COALESCE(preview_path, ''), created_at, completed_at`
+
+func scanClip(row interface{ Scan(...interface{}) error }, clip *models.Clip) error {
+	var subtitlesJSON, optionsJSON, segmentsJSON string
+	var completedAt sql.NullTime
+
+	err := row.Scan(
+		&clip.ID, &clip.VideoID, &clip.Title, &clip.StartTime, &clip.EndTime,
+		&clip.FilePath, &clip.Status, &clip.Error, &subtitlesJSON, &optionsJSON,
+		&segmentsJSON, &clip.PreviewPath, &clip.CreatedAt, &completedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if completedAt.Valid {
+		clip.CompletedAt = &completedAt.Time
+	}
+
+	if err := json.Unmarshal([]byte(subtitlesJSON), &clip.Subtitles); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(segmentsJSON), &clip.Segments); err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(optionsJSON), &clip.RenderOptions)
+}
+
 func (s *ClipService) CreateClip(clip *models.Clip) error {
 	if clip.ID == "" {
 		clip.ID = uuid.New().String()
 	}
 	clip.CreatedAt = time.Now()
-	clip.Status = "processing"
+	if clip.Status == "" {
+		clip.Status = "processing"
+	}
 
 	subtitlesJSON, err := json.Marshal(clip.Subtitles)
 	if err != nil {
 		return err
 	}
+	optionsJSON, err := json.Marshal(clip.RenderOptions)
+	if err != nil {
+		return err
+	}
+	segmentsJSON, err := json.Marshal(clip.Segments)
+	if err != nil {
+		return err
+	}
 
-	query := `INSERT INTO clips (id, video_id, title, start_time, end_time, status, subtitles, created_at)
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO clips (id, video_id, title, start_time, end_time, status, subtitles, render_options, segments, created_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	
 	_, err = s.db.Exec(query, clip.ID, clip.VideoID, clip.Title, clip.StartTime,
-		clip.EndTime, clip.Status, string(subtitlesJSON), clip.CreatedAt)
+		clip.EndTime, clip.Status, string(subtitlesJSON), string(optionsJSON), string(segmentsJSON), clip.CreatedAt)
 	
 	return err
 }
 
 func (s *ClipService) GetClip(id string) (*models.Clip, error) {
 	clip := &models.Clip{}
-	var subtitlesJSON string
-	var completedAt sql.NullTime
-
-	query := `SELECT id, video_id, title, start_time, end_time, file_path, status, subtitles, created_at, completed_at
-			  FROM clips WHERE id = ?`
-	
-	err := s.db.QueryRow(query, id).Scan(
-		&clip.ID, &clip.VideoID, &clip.Title, &clip.StartTime, &clip.EndTime,
-		&clip.FilePath, &clip.Status, &subtitlesJSON, &clip.CreatedAt, &completedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
 
-	if completedAt.Valid {
-		clip.CompletedAt = &completedAt.Time
-	}
-
-	if err := json.Unmarshal([]byte(subtitlesJSON), &clip.Subtitles); err != nil {
+	query := `SELECT ` + clipColumns + ` FROM clips WHERE id = ?`
+	if err := scanClip(s.db.QueryRow(query, id), clip); err != nil {
 		return nil, err
 	}
 
@@ -72,10 +99,10 @@ func (s *ClipService) UpdateClip(clip *models.Clip) error {
 	}
 
 	query := `UPDATE clips 
-			  SET file_path = ?, status = ?, subtitles = ?, completed_at = ?
+			  SET file_path = ?, preview_path = ?, status = ?, error_message = ?, subtitles = ?, completed_at = ?
 			  WHERE id = ?`
 	
-	_, err = s.db.Exec(query, clip.FilePath, clip.Status, string(subtitlesJSON),
+	_, err = s.db.Exec(query, clip.FilePath, clip.PreviewPath, clip.Status, clip.Error, string(subtitlesJSON),
 		clip.CompletedAt, clip.ID)
 	
 	return err
@@ -88,10 +115,30 @@ func (s *ClipService) DeleteClip(id string) error {
 }
 
 func (s *ClipService) GetClipsByVideo(videoID string) ([]models.Clip, error) {
-	query := `SELECT id, video_id, title, start_time, end_time, file_path, status, subtitles, created_at, completed_at
-			  FROM clips WHERE video_id = ? ORDER BY created_at DESC`
+	query := `SELECT ` + clipColumns + ` FROM clips WHERE video_id = ? ORDER BY created_at DESC`
 	
-	rows, err := s.db.Query(query, videoID)
+	return s.queryClips(query, videoID)
+}
+
+// GetClipsByStatus returns the clips whose status is one of the given values, oldest first
+func (s *ClipService) GetClipsByStatus(statuses ...string) ([]models.Clip, error) {
+	if len(statuses) == 0 {
+		return []models.Clip{}, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+
+	query := `SELECT ` + clipColumns + ` FROM clips WHERE status IN (` + placeholders + `) ORDER BY created_at ASC`
+
+	return s.queryClips(query, args...)
+}
+
+func (s *ClipService) queryClips(query string, args ...interface{}) ([]models.Clip, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -100,20 +147,7 @@ func (s *ClipService) GetClipsByVideo(videoID string) ([]models.Clip, error) {
 	clips := []models.Clip{}
 	for rows.Next() {
 		var clip models.Clip
-		var subtitlesJSON string
-		var completedAt sql.NullTime
-
-		err := rows.Scan(&clip.ID, &clip.VideoID, &clip.Title, &clip.StartTime, &clip.EndTime,
-			&clip.FilePath, &clip.Status, &subtitlesJSON, &clip.CreatedAt, &completedAt)
-		if err != nil {
-			return nil, err
-		}
-
-		if completedAt.Valid {
-			clip.CompletedAt = &completedAt.Time
-		}
-
-		if err := json.Unmarshal([]byte(subtitlesJSON), &clip.Subtitles); err != nil {
+		if err := scanClip(rows, &clip); err != nil {
 			return nil, err
 		}
 
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"shortgenerator/models"
+	"strings"
+)
+
+// EditorTemplate mirrors one entry of subtitleTemplates in
+// frontend/src/lib/data/templates.ts
+type EditorTemplate struct {
+	Name     string `json:"name"`
+	Settings struct {
+		FontFamily      string   `json:"fontFamily"`
+		FontWeight      int      `json:"fontWeight"`
+		Transition      string   `json:"transition"`
+		BgHexColor      string   `json:"bgHexColor"`
+		BgOpacity       *float64 `json:"bgOpacity"`
+		TextColor       string   `json:"textColor"`
+		ActiveTextColor string   `json:"activeTextColor"`
+		BorderRadius    int      `json:"borderRadius"`
+		ShadowBlur      int      `json:"shadowBlur"`
+	} `json:"settings"`
+}
+
+var (
+	tsLineCommentRe   = regexp.MustCompile(`(?m)^\s*//.*$`)
+	tsObjectKeyRe     = regexp.MustCompile(`(?m)^(\s*)(\w+):`)
+	tsTrailingCommaRe = regexp.MustCompile(`,(\s*[}\]])`)
+)
+
+// LoadEditorTemplates reads the subtitleTemplates array literal. The file is plain
+// object literals, so it becomes JSON once comments are dropped, keys are quoted and
+// trailing commas are removed.
+func LoadEditorTemplates(path string) ([]EditorTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	source := string(data)
+	start := strings.Index(source, "subtitleTemplates")
+	if start < 0 {
+		return nil, fmt.Errorf("subtitleTemplates not found in %s", path)
+	}
+	source = source[start:]
+	open, end := strings.Index(source, "["), strings.LastIndex(source, "]")
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("subtitleTemplates array not found in %s", path)
+	}
+	source = source[open : end+1]
+
+	source = tsLineCommentRe.ReplaceAllString(source, "")
+	source = tsObjectKeyRe.ReplaceAllString(source, `$1"$2":`)
+	source = tsTrailingCommaRe.ReplaceAllString(source, "$1")
+
+	var templates []EditorTemplate
+	if err := json.Unmarshal([]byte(source), &templates); err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %v", err)
+	}
+	return templates, nil
+}
+
+// Subtitle builds the subtitle ClipEditor.svelte exports for the template, shown
+// from start to end
+func (t EditorTemplate) Subtitle(text string, italic bool, start, end float64) models.SubtitleConfig {
+	bgOpacity := 0.8
+	if t.Settings.BgOpacity != nil {
+		bgOpacity = *t.Settings.BgOpacity
+	}
+
+	return models.SubtitleConfig{
+		Text:            text,
+		StartTime:       start,
+		EndTime:         end,
+		FontFamily:      t.Settings.FontFamily,
+		FontSize:        20,
+		FontWeight:      t.Settings.FontWeight,
+		Color:           t.Settings.TextColor,
+		BgColor:         t.Settings.BgHexColor,
+		BgOpacity:       bgOpacity,
+		Position:        "bottom",
+		Bold:            t.Settings.FontWeight >= 600,
+		Italic:          italic,
+		BorderRadius:    t.Settings.BorderRadius,
+		ShadowBlur:      t.Settings.ShadowBlur,
+		Transition:      t.Settings.Transition,
+		ActiveTextColor: t.Settings.ActiveTextColor,
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// faceCascade is a pixel intensity comparison (PICO) cascade of binary decision
+// trees, the CPU-only detector used by pigo. It reads the same cascade files
+// (e.g. "facefinder" from the pico/pigo repositories) and works on grayscale frames.
+type faceCascade struct {
+	treeDepth int
+	treeNum   int
+	codes     []int8    // 4 offsets per node: row/col of the two compared pixels
+	preds     []float32 // leaf predictions
+	threshold []float32 // cumulative score threshold after each tree
+}
+
+// faceDetection is a face found in a frame, in frame pixels
+type faceDetection struct {
+	row, col int // center
+	size     int // side of the square window
+	score    float32
+}
+
+// loadFaceCascade reads a binary cascade file
+func loadFaceCascade(path string) (*faceCascade, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first 8 bytes are unused by the detector
+	if len(data) < 16 {
+		return nil, fmt.Errorf("cascade file too short")
+	}
+	pos := 8
+	cascade := &faceCascade{
+		treeDepth: int(binary.LittleEndian.Uint32(data[pos:])),
+		treeNum:   int(binary.LittleEndian.Uint32(data[pos+4:])),
+	}
+	pos += 8
+	if cascade.treeDepth <= 0 || cascade.treeDepth > 16 || cascade.treeNum <= 0 {
+		return nil, fmt.Errorf("invalid cascade header (depth %d, trees %d)", cascade.treeDepth, cascade.treeNum)
+	}
+
+	leaves := 1 << cascade.treeDepth
+	treeSize := 4*(leaves-1) + 4*leaves + 4
+	if len(data)-pos < cascade.treeNum*treeSize {
+		return nil, fmt.Errorf("cascade file truncated")
+	}
+
+	for t := 0; t < cascade.treeNum; t++ {
+		// Node codes are 1-indexed, pad the unused root slot
+		cascade.codes = append(cascade.codes, 0, 0, 0, 0)
+		for _, b := range data[pos : pos+4*(leaves-1)] {
+			cascade.codes = append(cascade.codes, int8(b))
+		}
+		pos += 4 * (leaves - 1)
+
+		for i := 0; i < leaves; i++ {
+			cascade.preds = append(cascade.preds, math.Float32frombits(binary.LittleEndian.Uint32(data[pos:])))
+			pos += 4
+		}
+
+		cascade.threshold = append(cascade.threshold, math.Float32frombits(binary.LittleEndian.Uint32(data[pos:])))
+		pos += 4
+	}
+
+	return cascade, nil
+}
+
+// classify scores the square window of the given size centered at (row, col). A
+// negative score means the window was rejected.
+func (c *faceCascade) classify(row, col, size int, pixels []uint8, rows, cols int) float32 {
+	leaves := 1 << c.treeDepth
+	r, cl := row*256, col*256
+	root := 0
+	var score float32
+
+	for t := 0; t < c.treeNum; t++ {
+		idx := 1
+		for d := 0; d < c.treeDepth; d++ {
+			node := root + 4*idx
+			r1 := (r + int(c.codes[node+0])*size) >> 8
+			c1 := (cl + int(c.codes[node+1])*size) >> 8
+			r2 := (r + int(c.codes[node+2])*size) >> 8
+			c2 := (cl + int(c.codes[node+3])*size) >> 8
+
+			bit := 0
+			if pixels[clampInt(r1, 0, rows-1)*cols+clampInt(c1, 0, cols-1)] <= pixels[clampInt(r2, 0, rows-1)*cols+clampInt(c2, 0, cols-1)] {
+				bit = 1
+			}
+			idx = 2*idx + bit
+		}
+
+		score += c.preds[leaves*t+idx-leaves]
+		if score <= c.threshold[t] {
+			return -1
+		}
+		root += 4 * leaves
+	}
+
+	return score - c.threshold[c.treeNum-1]
+}
+
+// detect scans a grayscale frame at every window size from minSize up and returns
+// the faces scoring at least minScore, with overlapping windows merged
+func (c *faceCascade) detect(pixels []uint8, rows, cols, minSize int, minScore float32) []faceDetection {
+	const (
+		shiftFactor = 0.1
+		scaleFactor = 1.1
+	)
+
+	maxSize := rows
+	if cols < maxSize {
+		maxSize = cols
+	}
+
+	var detections []faceDetection
+	for size := minSize; size <= maxSize; size = int(math.Max(float64(size)*scaleFactor, float64(size+1))) {
+		step := maxInt(int(shiftFactor*float64(size)), 1)
+		offset := size/2 + 1
+		for row := offset; row <= rows-offset; row += step {
+			for col := offset; col <= cols-offset; col += step {
+				if score := c.classify(row, col, size, pixels, rows, cols); score > 0 {
+					detections = append(detections, faceDetection{row: row, col: col, size: size, score: score})
+				}
+			}
+		}
+	}
+
+	faces := clusterDetections(detections)
+	kept := faces[:0]
+	for _, face := range faces {
+		if face.score >= minScore {
+			kept = append(kept, face)
+		}
+	}
+	return kept
+}
+
+// clusterDetections merges windows that overlap by more than 20% into their average,
+// summing the scores (a face is found by many neighbouring windows)
+func clusterDetections(detections []faceDetection) []faceDetection {
+	sort.Slice(detections, func(i, j int) bool { return detections[i].score > detections[j].score })
+
+	assigned := make([]bool, len(detections))
+	var clusters []faceDetection
+	for i := range detections {
+		if assigned[i] {
+			continue
+		}
+		var row, col, size, n int
+		var score float32
+		for j := i; j < len(detections); j++ {
+			if assigned[j] || detectionOverlap(detections[i], detections[j]) <= 0.2 {
+				continue
+			}
+			assigned[j] = true
+			row += detections[j].row
+			col += detections[j].col
+			size += detections[j].size
+			score += detections[j].score
+			n++
+		}
+		clusters = append(clusters, faceDetection{row: row / n, col: col / n, size: size / n, score: score})
+	}
+
+	return clusters
+}
+
+// detectionOverlap is the intersection over union of two detection windows
+func detectionOverlap(a, b faceDetection) float64 {
+	overlap := func(c1, c2, s1, s2 int) float64 {
+		lo := math.Max(float64(c1)-float64(s1)/2, float64(c2)-float64(s2)/2)
+		hi := math.Min(float64(c1)+float64(s1)/2, float64(c2)+float64(s2)/2)
+		return math.Max(0, hi-lo)
+	}
+	intersection := overlap(a.row, b.row, a.size, b.size) * overlap(a.col, b.col, a.size, b.size)
+	union := float64(a.size*a.size+b.size*b.size) - intersection
+	if union <= 0 {
+		return 0
+	}
+	return intersection / union
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
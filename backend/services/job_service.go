@@ -0,0 +1,135 @@
+package services
+
+import (
+	"database/sql"
+	"shortgenerator/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JobService struct {
+	db *sql.DB
+}
+
+func NewJobService(db *sql.DB) *JobService {
+	return &JobService{db: db}
+}
+
+// CreateJob inserts a new job record for the given video and job type
+func (s *JobService) CreateJob(videoID string, jobType string, status string) (*models.ProcessingJob, error) {
+	job := &models.ProcessingJob{
+		ID:        uuid.New().String(),
+		VideoID:   videoID,
+		Type:      jobType,
+		Status:    status,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	query := `INSERT INTO processing_jobs (id, video_id, type, status, progress, message, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.db.Exec(query, job.ID, job.VideoID, job.Type, job.Status, job.Progress,
+		job.Message, job.CreatedAt, job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (s *JobService) UpdateJob(job *models.ProcessingJob) error {
+	job.UpdatedAt = time.Now()
+
+	query := `UPDATE processing_jobs
+			  SET status = ?, progress = ?, message = ?, updated_at = ?
+			  WHERE id = ?`
+	_, err := s.db.Exec(query, job.Status, job.Progress, job.Message, job.UpdatedAt, job.ID)
+
+	return err
+}
+
+func (s *JobService) GetJob(id string) (*models.ProcessingJob, error) {
+	job := &models.ProcessingJob{}
+	var videoID, message sql.NullString
+
+	query := `SELECT id, video_id, type, status, progress, message, created_at, updated_at
+			  FROM processing_jobs WHERE id = ?`
+	err := s.db.QueryRow(query, id).Scan(
+		&job.ID, &videoID, &job.Type, &job.Status, &job.Progress,
+		&message, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	job.VideoID = videoID.String
+	job.Message = message.String
+
+	return job, nil
+}
+
+func (s *JobService) GetJobsByVideo(videoID string) ([]models.ProcessingJob, error) {
+	query := `SELECT id, video_id, type, status, progress, message, created_at, updated_at
+			  FROM processing_jobs WHERE video_id = ? ORDER BY created_at ASC`
+
+	rows, err := s.db.Query(query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []models.ProcessingJob{}
+	for rows.Next() {
+		var job models.ProcessingJob
+		var vid, message sql.NullString
+
+		err := rows.Scan(&job.ID, &vid, &job.Type, &job.Status, &job.Progress,
+			&message, &job.CreatedAt, &job.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		job.VideoID = vid.String
+		job.Message = message.String
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// CompletedStages returns the set of job types that finished successfully for a video
+func (s *JobService) CompletedStages(videoID string) (map[string]bool, error) {
+	query := `SELECT DISTINCT type FROM processing_jobs WHERE video_id = ? AND status = 'completed'`
+
+	rows, err := s.db.Query(query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stages := map[string]bool{}
+	for rows.Next() {
+		var jobType string
+		if err := rows.Scan(&jobType); err != nil {
+			return nil, err
+		}
+		stages[jobType] = true
+	}
+
+	return stages, nil
+}
+
+// MarkInterruptedJobs flags jobs left pending or running by a previous process
+func (s *JobService) MarkInterruptedJobs() (int64, error) {
+	query := `UPDATE processing_jobs
+			  SET status = 'interrupted', message = 'Server restarted before the job finished', updated_at = ?
+			  WHERE status IN ('pending', 'running')`
+
+	result, err := s.db.Exec(query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"log"
+	"shortgenerator/models"
+	"strings"
+)
+
+// jumpCutMaxPause is the longest pause jump cuts keep when no max_pause was given
+const jumpCutMaxPause = 0.5
+
+// defaultFillerWords are removed by jump cuts. Phrases match consecutive words.
+const defaultFillerWords = "um,umm,uh,uhm,hmm,mm,mmm,eh,ehh,ehm,em,ah,ahh,o sea"
+
+// fillerPhrases returns the filler words and phrases from FILLER_WORDS (comma
+// separated), each split into normalized words
+func fillerPhrases() [][]string {
+	var phrases [][]string
+	for _, item := range strings.Split(getEnv("FILLER_WORDS", defaultFillerWords), ",") {
+		var phrase []string
+		for _, word := range strings.Fields(item) {
+			if w := normalizeWord(word); w != "" {
+				phrase = append(phrase, w)
+			}
+		}
+		if len(phrase) > 0 {
+			phrases = append(phrases, phrase)
+		}
+	}
+	return phrases
+}
+
+// matchFiller returns how many words starting at words[i] form a filler phrase, 0 if
+// none does. The longest phrase wins.
+func matchFiller(words []string, i int, phrases [][]string) int {
+	best := 0
+	for _, phrase := range phrases {
+		if len(phrase) <= best || i+len(phrase) > len(words) {
+			continue
+		}
+		matches := true
+		for j, w := range phrase {
+			if words[i+j] != w {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			best = len(phrase)
+		}
+	}
+	return best
+}
+
+// fillerCuts returns the time ranges of the filler words among the clip words
+func fillerCuts(words []models.Word, phrases [][]string) []timeRange {
+	normalized := make([]string, len(words))
+	for i, w := range words {
+		normalized[i] = normalizeWord(w.Text)
+	}
+
+	var cuts []timeRange
+	for i := 0; i < len(words); i++ {
+		if n := matchFiller(normalized, i, phrases); n > 0 {
+			cuts = append(cuts, timeRange{words[i].Start, words[i+n-1].End})
+			i += n - 1
+		}
+	}
+	return cuts
+}
+
+// clipWords returns the transcript words between start and end, relative to start.
+// It falls back to the given words (already relative to start) when the transcript
+// has no word timings.
+func clipWords(transcript *models.Transcript, start, end float64, fallback []models.Word) []models.Word {
+	var words []models.Word
+	if transcript != nil {
+		for _, seg := range transcript.Segments {
+			if seg.End < start || seg.Start > end {
+				continue
+			}
+			for _, w := range seg.Words {
+				if w.Start >= start && w.End <= end {
+					words = append(words, models.Word{Start: w.Start - start, End: w.End - start, Text: w.Text})
+				}
+			}
+		}
+	}
+	if len(words) > 0 {
+		return words
+	}
+	return fallback
+}
+
+// subtitleWords returns the karaoke words of the subtitles between from and from +
+// duration (clip time), relative to from
+func subtitleWords(subtitles []models.SubtitleConfig, from, duration float64) []models.Word {
+	var words []models.Word
+	for _, sub := range subtitles {
+		for _, w := range sub.Words {
+			if w.Start >= from && w.End <= from+duration {
+				words = append(words, models.Word{Start: w.Start - from, End: w.End - from, Text: w.Text})
+			}
+		}
+	}
+	return words
+}
+
+// editTimeline builds the export timeline of a clip from its render options: silence
+// trimming, pause removal and, in jump cut mode, filler words (found in words, clip
+// relative) and pauses longer than jumpCutMaxPause unless max_pause says otherwise
+func (s *ProcessingService) editTimeline(videoID, clipID, inputPath string, start, duration float64, options models.RenderOptions, words []models.Word) clipTimeline {
+	timeline := fullTimeline(duration)
+
+	maxPause := options.MaxPause
+	if options.JumpCut && maxPause <= 0 {
+		maxPause = jumpCutMaxPause
+	}
+
+	cuts := s.silenceCuts(videoID, clipID, inputPath, start, duration, options.TrimSilence, maxPause)
+	if options.JumpCut {
+		fillers := fillerCuts(words, fillerPhrases())
+		log.Printf("✂️  %d filler words found in %d words", len(fillers), len(words))
+		cuts = append(cuts, fillers...)
+	}
+	if len(cuts) == 0 {
+		return timeline
+	}
+
+	timeline = timeline.without(cuts)
+	if len(timeline.ranges) == 0 {
+		return fullTimeline(duration)
+	}
+	log.Printf("✂️  %.2fs removed from the clip (%d parts kept)", timeline.removedSeconds(), len(timeline.ranges))
+	return timeline
+}
+
+// keptWords returns the indexes of the words that are not part of a filler phrase
+func keptWords(texts []string, phrases [][]string) []int {
+	normalized := make([]string, len(texts))
+	for i, text := range texts {
+		normalized[i] = normalizeWord(text)
+	}
+
+	kept := []int{}
+	for i := 0; i < len(texts); i++ {
+		if n := matchFiller(normalized, i, phrases); n > 0 {
+			i += n - 1
+			continue
+		}
+		kept = append(kept, i)
+	}
+	return kept
+}
+
+// removeFillerText drops filler words from the subtitle text and karaoke words, so
+// the subtitles do not show what jump cuts removed. Subtitles left empty are dropped.
+func removeFillerText(subtitles []models.SubtitleConfig, phrases [][]string) []models.SubtitleConfig {
+	cleaned := []models.SubtitleConfig{}
+	for _, sub := range subtitles {
+		tokens := strings.Fields(sub.Text)
+		kept := []string{}
+		for _, i := range keptWords(tokens, phrases) {
+			kept = append(kept, tokens[i])
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		sub.Text = strings.Join(kept, " ")
+
+		if len(sub.Words) > 0 {
+			texts := make([]string, len(sub.Words))
+			for i, w := range sub.Words {
+				texts[i] = w.Text
+			}
+			words := []models.Word{}
+			for _, i := range keptWords(texts, phrases) {
+				words = append(words, sub.Words[i])
+			}
+			sub.Words = words
+		}
+
+		cleaned = append(cleaned, sub)
+	}
+	return cleaned
+}
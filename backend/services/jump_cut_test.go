@@ -0,0 +1,112 @@
+package services
+
+import (
+	"reflect"
+	"shortgenerator/models"
+	"testing"
+)
+
+func TestFillerPhrases(t *testing.T) {
+	t.Setenv("FILLER_WORDS", " Um ,o  sea,, ¿eh?,")
+
+	want := [][]string{{"um"}, {"o", "sea"}, {"eh"}}
+	if got := fillerPhrases(); !reflect.DeepEqual(got, want) {
+		t.Errorf("fillerPhrases() = %v, want %v", got, want)
+	}
+}
+
+func TestFillerCuts(t *testing.T) {
+	phrases := [][]string{{"um"}, {"eh"}, {"o", "sea"}, {"o", "sea", "que"}}
+
+	tests := []struct {
+		name  string
+		words []models.Word
+		want  []timeRange
+	}{
+		{
+			name:  "no fillers",
+			words: []models.Word{{Start: 0, End: 0.4, Text: "hola"}, {Start: 0.5, End: 0.9, Text: "mundo"}},
+		},
+		{
+			name: "single words, any case and punctuation",
+			words: []models.Word{
+				{Start: 0, End: 0.3, Text: "Um,"}, {Start: 0.4, End: 0.8, Text: "hola"},
+				{Start: 0.9, End: 1.1, Text: "eh..."}, {Start: 1.2, End: 1.6, Text: "mundo"},
+			},
+			want: []timeRange{{0, 0.3}, {0.9, 1.1}},
+		},
+		{
+			name: "phrases span their words",
+			words: []models.Word{
+				{Start: 0, End: 0.4, Text: "bueno"}, {Start: 0.5, End: 0.6, Text: "o"},
+				{Start: 0.6, End: 0.9, Text: "sea,"}, {Start: 1, End: 1.4, Text: "vale"},
+			},
+			want: []timeRange{{0.5, 0.9}},
+		},
+		{
+			name: "longest phrase wins",
+			words: []models.Word{
+				{Start: 0, End: 0.1, Text: "o"}, {Start: 0.1, End: 0.3, Text: "sea"},
+				{Start: 0.3, End: 0.5, Text: "que"}, {Start: 0.6, End: 1, Text: "sí"},
+			},
+			want: []timeRange{{0, 0.5}},
+		},
+		{
+			name:  "partial phrase at the end",
+			words: []models.Word{{Start: 0, End: 0.4, Text: "pues"}, {Start: 0.5, End: 0.6, Text: "o"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fillerCuts(tt.words, phrases); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fillerCuts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveFillerText(t *testing.T) {
+	phrases := [][]string{{"um"}, {"eh"}, {"o", "sea"}}
+	subtitles := []models.SubtitleConfig{
+		{Text: "Um, hola a todos", StartTime: 0, EndTime: 2, Words: []models.Word{
+			{Start: 0, End: 0.3, Text: "Um,"}, {Start: 0.4, End: 0.8, Text: "hola"},
+			{Start: 0.9, End: 1, Text: "a"}, {Start: 1.1, End: 1.6, Text: "todos"},
+		}},
+		{Text: "eh... um", StartTime: 2, EndTime: 3},
+		{Text: "esto, o sea, funciona", StartTime: 3, EndTime: 5},
+		{Text: "sin muletillas", StartTime: 5, EndTime: 6},
+	}
+
+	want := []models.SubtitleConfig{
+		{Text: "hola a todos", StartTime: 0, EndTime: 2, Words: []models.Word{
+			{Start: 0.4, End: 0.8, Text: "hola"}, {Start: 0.9, End: 1, Text: "a"}, {Start: 1.1, End: 1.6, Text: "todos"},
+		}},
+		{Text: "esto, funciona", StartTime: 3, EndTime: 5},
+		{Text: "sin muletillas", StartTime: 5, EndTime: 6},
+	}
+
+	if got := removeFillerText(subtitles, phrases); !reflect.DeepEqual(got, want) {
+		t.Errorf("removeFillerText() = %+v, want %+v", got, want)
+	}
+}
+
+func TestClipWords(t *testing.T) {
+	transcript := &models.Transcript{Segments: []models.Segment{
+		{Start: 0, End: 3, Words: []models.Word{{Start: 0, End: 1, Text: "a"}, {Start: 2, End: 3, Text: "b"}}},
+		{Start: 10, End: 14, Words: []models.Word{{Start: 10, End: 11, Text: "c"}, {Start: 12.5, End: 14, Text: "d"}}},
+		{Start: 20, End: 21, Words: []models.Word{{Start: 20, End: 21, Text: "e"}}},
+	}}
+	fallback := []models.Word{{Start: 0, End: 1, Text: "sub"}}
+
+	want := []models.Word{{Start: 0, End: 1, Text: "b"}, {Start: 8, End: 9, Text: "c"}}
+	if got := clipWords(transcript, 2, 13, fallback); !reflect.DeepEqual(got, want) {
+		t.Errorf("clipWords() = %+v, want %+v", got, want)
+	}
+	if got := clipWords(nil, 2, 13, fallback); !reflect.DeepEqual(got, fallback) {
+		t.Errorf("clipWords() without transcript = %+v, want the fallback", got)
+	}
+	if got := clipWords(transcript, 30, 40, fallback); !reflect.DeepEqual(got, fallback) {
+		t.Errorf("clipWords() without words in range = %+v, want the fallback", got)
+	}
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LLMRequest is a single-turn completion request shared by every provider
+type LLMRequest struct {
+	System      string
+	Prompt      string
+	Temperature float64
+	MaxTokens   int
+}
+
+// LLMProvider sends a prompt to a language model and returns its raw text answer
+type LLMProvider interface {
+	Name() string
+	Complete(request LLMRequest) (string, error)
+}
+
+// newLLMProvider picks the provider from LLM_PROVIDER (deepseek, ollama, openai, fake).
+// When unset it honours the legacy USE_OLLAMA flag and defaults to DeepSeek.
+func newLLMProvider() LLMProvider {
+	name := strings.ToLower(getEnv("LLM_PROVIDER", ""))
+	if name == "" {
+		name = "deepseek"
+		if getEnv("USE_OLLAMA", "false") == "true" {
+			name = "ollama"
+		}
+	}
+
+	switch name {
+	case "ollama":
+		return &ollamaProvider{
+			baseURL: getEnv("OLLAMA_URL", "http://localhost:11434"),
+			model:   getEnv("OLLAMA_MODEL", "deepseek-r1:latest"),
+			timeout: llmTimeout("OLLAMA_TIMEOUT_SECONDS", 300),
+		}
+	case "openai":
+		return &openAICompatibleProvider{
+			name:    "openai",
+			baseURL: getEnv("LLM_API_URL", getEnv("OPENAI_API_URL", "https://api.openai.com/v1")),
+			apiKey:  getEnv("LLM_API_KEY", os.Getenv("OPENAI_API_KEY")),
+			model:   getEnv("LLM_MODEL", "gpt-4o-mini"),
+			timeout: llmTimeout("LLM_TIMEOUT_SECONDS", 120),
+		}
+	case "fake":
+		return &fakeLLMProvider{}
+	default:
+		if name != "deepseek" {
+			log.Printf("⚠️  Unknown LLM_PROVIDER %q, using deepseek", name)
+		}
+		return &openAICompatibleProvider{
+			name:    "deepseek",
+			baseURL: getEnv("DEEPSEEK_API_URL", "https://api.deepseek.com"),
+			apiKey:  os.Getenv("DEEPSEEK_API_KEY"),
+			model:   getEnv("DEEPSEEK_MODEL", "deepseek-chat"),
+			timeout: llmTimeout("DEEPSEEK_TIMEOUT_SECONDS", 120),
+		}
+	}
+}
+
+func llmTimeout(key string, fallback int) time.Duration {
+	seconds, err := strconv.Atoi(getEnv(key, strconv.Itoa(fallback)))
+	if err != nil || seconds <= 0 {
+		seconds = fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// llmStatusError is a non-200 answer from a provider; only rate limits and server
+// errors are worth retrying
+type llmStatusError struct {
+	provider   string
+	statusCode int
+	body       string
+}
+
+func (e *llmStatusError) Error() string {
+	return fmt.Sprintf("%s API returned status %d: %s", e.provider, e.statusCode, e.body)
+}
+
+func (e *llmStatusError) retryable() bool {
+	return e.statusCode == http.StatusTooManyRequests || e.statusCode >= 500
+}
+
+// errLLMNotConfigured marks errors no retry can fix, like a missing API key
+var errLLMNotConfigured = errors.New("LLM provider not configured")
+
+// llmRetryBackoff is the wait before the first retry, doubled on every attempt
+var llmRetryBackoff = 2 * time.Second
+
+// completeWithRetry calls the provider up to LLM_MAX_ATTEMPTS times with exponential
+// backoff, giving up early on errors that a retry cannot fix
+func completeWithRetry(provider LLMProvider, request LLMRequest) (string, error) {
+	attempts, err := strconv.Atoi(getEnv("LLM_MAX_ATTEMPTS", "3"))
+	if err != nil || attempts < 1 {
+		attempts = 3
+	}
+
+	backoff := llmRetryBackoff
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		log.Printf("🤖 Calling %s (attempt %d/%d, prompt length: %d chars)", provider.Name(), attempt, attempts, len(request.Prompt))
+
+		content, err := provider.Complete(request)
+		if err == nil {
+			if strings.TrimSpace(content) == "" {
+				err = fmt.Errorf("empty content from %s", provider.Name())
+			} else {
+				log.Printf("✅ %s answered with %d characters", provider.Name(), len(content))
+				return content, nil
+			}
+		}
+		lastErr = err
+
+		var statusErr *llmStatusError
+		if errors.Is(err, errLLMNotConfigured) || (errors.As(err, &statusErr) && !statusErr.retryable()) {
+			break
+		}
+
+		if attempt < attempts {
+			log.Printf("⚠️  %s failed: %v, retrying in %s", provider.Name(), err, backoff)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	return "", lastErr
+}
+
+var thinkBlockRe = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+// extractJSON pulls the JSON payload out of a model answer: it drops reasoning
+// blocks and markdown fences, then trims any prose around the outermost array or object
+func extractJSON(content string) string {
+	content = thinkBlockRe.ReplaceAllString(content, "")
+	content = strings.TrimSpace(content)
+
+	if start := strings.Index(content, "```"); start >= 0 {
+		inner := content[start+3:]
+		inner = strings.TrimPrefix(inner, "json")
+		if end := strings.LastIndex(inner, "```"); end >= 0 {
+			inner = inner[:end]
+		}
+		content = strings.TrimSpace(inner)
+	}
+
+	start := strings.IndexAny(content, "[{")
+	if start < 0 {
+		return content
+	}
+	closing := "]"
+	if content[start] == '{' {
+		closing = "}"
+	}
+	if end := strings.LastIndex(content, closing); end > start {
+		return content[start : end+1]
+	}
+
+	return content[start:]
+}
+
+// postJSON sends body to url and returns the raw response, wrapping non-200 answers
+func postJSON(provider, url, apiKey string, timeout time.Duration, body interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &llmStatusError{provider: provider, statusCode: resp.StatusCode, body: string(respBody)}
+	}
+
+	if len(respBody) == 0 {
+		return nil, fmt.Errorf("%s API returned empty response body", provider)
+	}
+
+	return respBody, nil
+}
+
+// openAICompatibleProvider talks to any /chat/completions endpoint (DeepSeek, OpenAI,
+// vLLM, LM Studio, OpenRouter...)
+type openAICompatibleProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	model   string
+	timeout time.Duration
+}
+
+func (p *openAICompatibleProvider) Name() string { return p.name }
+
+func (p *openAICompatibleProvider) Complete(request LLMRequest) (string, error) {
+	if p.apiKey == "" && p.name != "openai" {
+		return "", fmt.Errorf("%w: API key for %s not set", errLLMNotConfigured, p.name)
+	}
+
+	messages := []map[string]string{}
+	if request.System != "" {
+		messages = append(messages, map[string]string{"role": "system", "content": request.System})
+	}
+	messages = append(messages, map[string]string{"role": "user", "content": request.Prompt})
+
+	body := map[string]interface{}{
+		"model":       p.model,
+		"messages":    messages,
+		"temperature": request.Temperature,
+		"stream":      false,
+	}
+	if request.MaxTokens > 0 {
+		body["max_tokens"] = request.MaxTokens
+	}
+
+	respBody, err := postJSON(p.name, strings.TrimSuffix(p.baseURL, "/")+"/chat/completions", p.apiKey, p.timeout, body)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Error *struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return "", fmt.Errorf("failed to parse %s response: %v, body: %s", p.name, err, string(respBody))
+	}
+
+	if response.Error != nil {
+		return "", fmt.Errorf("%s API error: %s (%s)", p.name, response.Error.Message, response.Error.Type)
+	}
+
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("no response from %s, body: %s", p.name, string(respBody))
+	}
+
+	return response.Choices[0].Message.Content, nil
+}
+
+// ollamaProvider uses a local Ollama server's /api/generate endpoint
+type ollamaProvider struct {
+	baseURL string
+	model   string
+	timeout time.Duration
+}
+
+func (p *ollamaProvider) Name() string { return "ollama" }
+
+func (p *ollamaProvider) Complete(request LLMRequest) (string, error) {
+	body := map[string]interface{}{
+		"model":  p.model,
+		"prompt": request.Prompt,
+		"system": request.System,
+		"stream": false,
+		"options": map[string]interface{}{
+			"temperature": request.Temperature,
+		},
+	}
+
+	respBody, err := postJSON("Ollama", strings.TrimSuffix(p.baseURL, "/")+"/api/generate", "", p.timeout, body)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Response string `json:"response"`
+		Error    string `json:"error"`
+	}
+
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return "", fmt.Errorf("failed to parse Ollama response body: %v", err)
+	}
+
+	if response.Error != "" {
+		return "", fmt.Errorf("Ollama error: %s", response.Error)
+	}
+
+	return response.Response, nil
+}
+
+// fakeLLMProvider answers deterministically without any network access, for local
+// development and tests. Response overrides the canned clip list when set; Errors
+// are returned by the first calls, one per call, before answering. It is safe for
+// the concurrent calls of windowed analysis.
+type fakeLLMProvider struct {
+	Response string
+	Errors   []error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *fakeLLMProvider) Name() string { return "fake" }
+
+func (p *fakeLLMProvider) Complete(request LLMRequest) (string, error) {
+	p.mu.Lock()
+	p.calls++
+	call := p.calls
+	p.mu.Unlock()
+
+	if call <= len(p.Errors) {
+		return "", p.Errors[call-1]
+	}
+	if p.Response != "" {
+		return p.Response, nil
+	}
+
+	return `[
+  {"start_time": 0, "end_time": 30, "title": "Clip de prueba 1", "description": "Respuesta fija del proveedor fake", "score": 80, "reason": "Proveedor LLM de prueba"},
+  {"start_time": 30, "end_time": 60, "title": "Clip de prueba 2", "description": "Respuesta fija del proveedor fake", "score": 70, "reason": "Proveedor LLM de prueba"}
+]`, nil
+}
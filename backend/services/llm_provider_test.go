@@ -0,0 +1,210 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"shortgenerator/models"
+	"strings"
+	"testing"
+)
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "plain array",
+			content: `[{"start_time": 1}]`,
+			want:    `[{"start_time": 1}]`,
+		},
+		{
+			name:    "json fence",
+			content: "```json\n[{\"start_time\": 1}]\n```",
+			want:    `[{"start_time": 1}]`,
+		},
+		{
+			name:    "bare fence",
+			content: "```\n{\"title\": \"a\"}\n```",
+			want:    `{"title": "a"}`,
+		},
+		{
+			name:    "prose around the array",
+			content: "Here are the clips:\n[{\"start_time\": 1}, {\"start_time\": 2}]\nHope this helps!",
+			want:    `[{"start_time": 1}, {"start_time": 2}]`,
+		},
+		{
+			name:    "prose and fence",
+			content: "Sure.\n```json\n[{\"start_time\": 1}]\n```\nLet me know.",
+			want:    `[{"start_time": 1}]`,
+		},
+		{
+			name:    "reasoning block before the answer",
+			content: "<think>maybe [this] or {that}</think>\n[{\"start_time\": 1}]",
+			want:    `[{"start_time": 1}]`,
+		},
+		{
+			name:    "object with nested array",
+			content: `The result: {"clips": [1, 2]} done`,
+			want:    `{"clips": [1, 2]}`,
+		},
+		{
+			name:    "truncated answer keeps what is there",
+			content: `[{"start_time": 1`,
+			want:    `[{"start_time": 1`,
+		},
+		{
+			name:    "no JSON at all",
+			content: "  I cannot help with that.  ",
+			want:    "I cannot help with that.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractJSON(tt.content); got != tt.want {
+				t.Errorf("extractJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteWithRetry(t *testing.T) {
+	backoff := llmRetryBackoff
+	llmRetryBackoff = 0
+	t.Cleanup(func() { llmRetryBackoff = backoff })
+	t.Setenv("LLM_MAX_ATTEMPTS", "3")
+
+	serverError := &llmStatusError{provider: "fake", statusCode: http.StatusInternalServerError}
+	rateLimit := &llmStatusError{provider: "fake", statusCode: http.StatusTooManyRequests}
+	badRequest := &llmStatusError{provider: "fake", statusCode: http.StatusBadRequest}
+	notConfigured := fmt.Errorf("%w: API key for fake not set", errLLMNotConfigured)
+
+	tests := []struct {
+		name      string
+		errors    []error
+		response  string
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "first attempt", response: "[]", wantCalls: 1},
+		{name: "server error is retried", errors: []error{serverError}, response: "[]", wantCalls: 2},
+		{name: "rate limit is retried", errors: []error{rateLimit, rateLimit}, response: "[]", wantCalls: 3},
+		{name: "network error is retried", errors: []error{errors.New("connection reset")}, response: "[]", wantCalls: 2},
+		{name: "gives up after the last attempt", errors: []error{serverError, serverError, serverError}, wantErr: serverError, wantCalls: 3},
+		{name: "client error is not retried", errors: []error{badRequest}, wantErr: badRequest, wantCalls: 1},
+		{name: "missing configuration is not retried", errors: []error{notConfigured}, wantErr: notConfigured, wantCalls: 1},
+		{name: "blank answer is retried", response: " \n ", wantCalls: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &fakeLLMProvider{Response: tt.response, Errors: tt.errors}
+			content, err := completeWithRetry(provider, LLMRequest{Prompt: "prompt"})
+
+			if provider.calls != tt.wantCalls {
+				t.Errorf("%d calls, want %d", provider.calls, tt.wantCalls)
+			}
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+			case strings.TrimSpace(tt.response) == "":
+				if err == nil || !strings.Contains(err.Error(), "empty content") {
+					t.Errorf("error = %v, want empty content", err)
+				}
+			default:
+				if err != nil || content != tt.response {
+					t.Errorf("got %q, %v; want %q", content, err, tt.response)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSuggestedClips(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []models.SuggestedClip
+		wantErr bool
+	}{
+		{
+			name:    "fenced answer",
+			content: "```json\n[{\"start_time\": 10, \"end_time\": 40, \"title\": \"A\", \"score\": 90}]\n```",
+			want:    []models.SuggestedClip{{StartTime: 10, EndTime: 40, Title: "A", Score: 90}},
+		},
+		{
+			name: "multi-part clip spans its parts",
+			content: `[{"title": "B", "segments": [
+				{"start_time": 100, "end_time": 110},
+				{"start_time": 20, "end_time": 35}
+			]}]`,
+			want: []models.SuggestedClip{{StartTime: 20, EndTime: 110, Title: "B", Segments: []models.ClipSegment{
+				{StartTime: 100, EndTime: 110}, {StartTime: 20, EndTime: 35},
+			}}},
+		},
+		{
+			name:    "single part becomes a plain clip",
+			content: `[{"title": "C", "start_time": 0, "end_time": 0, "segments": [{"start_time": 5, "end_time": 50}]}]`,
+			want:    []models.SuggestedClip{{StartTime: 5, EndTime: 50, Title: "C"}},
+		},
+		{
+			name:    "empty array",
+			content: "[]",
+			wantErr: true,
+		},
+		{
+			name:    "malformed JSON",
+			content: `[{"start_time": 10, "end_time": }]`,
+			wantErr: true,
+		},
+		{
+			name:    "object instead of array",
+			content: `{"start_time": 10}`,
+			wantErr: true,
+		},
+		{
+			name:    "prose only",
+			content: "No good clips in this video.",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSuggestedClips("fake", tt.content)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseSuggestedClips() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSuggestedClips() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSuggestedClips() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFakeProviderCannedClips(t *testing.T) {
+	content, err := completeWithRetry(&fakeLLMProvider{}, LLMRequest{Prompt: "prompt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clips, err := parseSuggestedClips("fake", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clips) != 2 || clips[0].StartTime != 0 || clips[0].EndTime != 30 || clips[1].StartTime != 30 {
+		t.Errorf("canned clips = %+v", clips)
+	}
+}
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"shortgenerator/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Supported local whisper CLIs
+const (
+	whisperFlavorCpp    = "whisper.cpp"    // whisper-cli / main from ggerganov/whisper.cpp
+	whisperFlavorFaster = "faster-whisper" // whisper-ctranslate2, faster-whisper-xxl or openai-whisper
+)
+
+// localWhisperTranscriber shells out to a whisper binary so transcription works air-gapped
+type localWhisperTranscriber struct {
+	binaryPath string
+	flavor     string
+	model      string
+	language   string
+	threads    string
+}
+
+func newLocalWhisperTranscriber(binaryPath string) *localWhisperTranscriber {
+	flavor := strings.ToLower(getEnv("WHISPER_FLAVOR", ""))
+	if flavor == "" {
+		flavor = detectWhisperFlavor(binaryPath)
+	}
+
+	return &localWhisperTranscriber{
+		binaryPath: binaryPath,
+		flavor:     flavor,
+		model:      getEnv("WHISPER_MODEL", "base"),
+		language:   getEnv("WHISPER_LANGUAGE", "auto"),
+		threads:    getEnv("WHISPER_THREADS", ""),
+	}
+}
+
+// detectWhisperFlavor guesses the CLI flavour from the binary name
+func detectWhisperFlavor(binaryPath string) string {
+	name := strings.ToLower(filepath.Base(binaryPath))
+	if strings.Contains(name, "faster") || strings.Contains(name, "ctranslate2") || strings.Contains(name, "xxl") {
+		return whisperFlavorFaster
+	}
+	return whisperFlavorCpp
+}
+
+func (t *localWhisperTranscriber) Name() string { return "local" }
+
+func (t *localWhisperTranscriber) Transcribe(audioPath string, videoID string) (*models.Transcript, error) {
+	outputDir, err := os.MkdirTemp(filepath.Dir(audioPath), videoID+"_whisper_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create whisper output directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	var args []string
+	switch t.flavor {
+	case whisperFlavorFaster:
+		args = t.fasterWhisperArgs(audioPath, outputDir)
+	default:
+		args = t.whisperCppArgs(audioPath, outputDir)
+	}
+
+	log.Printf("🎤 Running local whisper (%s): %s %v", t.flavor, t.binaryPath, args)
+	startTime := time.Now()
+
+	cmd := exec.Command(t.binaryPath, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("whisper failed: %v, output: %s", err, lastLines(string(output), 20))
+	}
+
+	log.Printf("✅ Local whisper finished in %.1f seconds", time.Since(startTime).Seconds())
+
+	language, segments, err := readWhisperOutput(outputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if language == "" || language == "auto" {
+		language = t.language
+	}
+
+	texts := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		texts = append(texts, seg.Text)
+	}
+
+	return &models.Transcript{
+		ID:        uuid.New().String(),
+		VideoID:   videoID,
+		Language:  language,
+		Segments:  segments,
+		FullText:  strings.Join(texts, " "),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (t *localWhisperTranscriber) whisperCppArgs(audioPath, outputDir string) []string {
+	args := []string{
+		"-m", t.whisperCppModelPath(),
+		"-f", audioPath,
+		"-l", t.language,
+		"-ojf", "-osrt",
+		"-of", filepath.Join(outputDir, "transcript"),
+	}
+	if t.threads != "" {
+		args = append(args, "-t", t.threads)
+	}
+	return args
+}
+
+func (t *localWhisperTranscriber) fasterWhisperArgs(audioPath, outputDir string) []string {
+	args := []string{
+		audioPath,
+		"--model", t.model,
+		"--output_dir", outputDir,
+		"--output_format", "json",
+		"--word_timestamps", "True",
+	}
+	if t.language != "" && t.language != "auto" {
+		args = append(args, "--language", t.language)
+	}
+	if t.threads != "" {
+		args = append(args, "--threads", t.threads)
+	}
+	return args
+}
+
+// whisperCppModelPath resolves WHISPER_MODEL to a ggml file. A bare model name
+// like "base" maps to models/ggml-base.bin next to the binary.
+func (t *localWhisperTranscriber) whisperCppModelPath() string {
+	if path := os.Getenv("WHISPER_MODEL_PATH"); path != "" {
+		return path
+	}
+	if strings.HasSuffix(t.model, ".bin") {
+		return t.model
+	}
+	return filepath.Join(filepath.Dir(t.binaryPath), "models", "ggml-"+t.model+".bin")
+}
+
+// readWhisperOutput parses the first JSON transcript in dir, falling back to SRT
+func readWhisperOutput(dir string) (string, []models.Segment, error) {
+	jsonFiles, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for _, path := range jsonFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		language, segments, err := parseWhisperJSON(data)
+		if err != nil {
+			log.Printf("⚠️  Failed to parse whisper JSON %s: %v", path, err)
+			continue
+		}
+		return language, segments, nil
+	}
+
+	srtFiles, _ := filepath.Glob(filepath.Join(dir, "*.srt"))
+	for _, path := range srtFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		segments, err := parseSRT(string(data))
+		if err != nil {
+			log.Printf("⚠️  Failed to parse whisper SRT %s: %v", path, err)
+			continue
+		}
+		return "", segments, nil
+	}
+
+	return "", nil, fmt.Errorf("whisper produced no JSON or SRT output in %s", dir)
+}
+
+type whisperCppOffsets struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// parseWhisperJSON understands both the whisper.cpp (-oj/-ojf) layout and the
+// openai-whisper layout that faster-whisper CLIs also emit
+func parseWhisperJSON(data []byte) (string, []models.Segment, error) {
+	var output struct {
+		// openai-whisper / faster-whisper
+		Language string `json:"language"`
+		Segments []struct {
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+			Text  string  `json:"text"`
+			Words []struct {
+				Start float64 `json:"start"`
+				End   float64 `json:"end"`
+				Word  string  `json:"word"`
+			} `json:"words"`
+		} `json:"segments"`
+
+		// whisper.cpp
+		Result struct {
+			Language string `json:"language"`
+		} `json:"result"`
+		Transcription []struct {
+			Offsets whisperCppOffsets `json:"offsets"`
+			Text    string            `json:"text"`
+			Tokens  []struct {
+				Text    string            `json:"text"`
+				Offsets whisperCppOffsets `json:"offsets"`
+			} `json:"tokens"`
+		} `json:"transcription"`
+	}
+
+	if err := json.Unmarshal(data, &output); err != nil {
+		return "", nil, err
+	}
+
+	segments := []models.Segment{}
+	language := output.Language
+
+	if len(output.Transcription) > 0 {
+		language = output.Result.Language
+		for _, item := range output.Transcription {
+			text := strings.TrimSpace(item.Text)
+			if text == "" {
+				continue
+			}
+			segment := models.Segment{
+				Start: float64(item.Offsets.From) / 1000,
+				End:   float64(item.Offsets.To) / 1000,
+				Text:  text,
+			}
+
+			// Tokens are sub-word pieces; a leading space starts a new word
+			for _, token := range item.Tokens {
+				if strings.HasPrefix(token.Text, "[_") || strings.TrimSpace(token.Text) == "" {
+					continue
+				}
+				start := float64(token.Offsets.From) / 1000
+				end := float64(token.Offsets.To) / 1000
+				n := len(segment.Words)
+				if n == 0 || strings.HasPrefix(token.Text, " ") {
+					segment.Words = append(segment.Words, models.Word{Start: start, End: end, Text: strings.TrimSpace(token.Text)})
+				} else {
+					segment.Words[n-1].Text += token.Text
+					segment.Words[n-1].End = end
+				}
+			}
+
+			segments = append(segments, segment)
+		}
+	} else {
+		for _, item := range output.Segments {
+			text := strings.TrimSpace(item.Text)
+			if text == "" {
+				continue
+			}
+			segment := models.Segment{
+				Start: item.Start,
+				End:   item.End,
+				Text:  text,
+			}
+			for _, w := range item.Words {
+				if word := strings.TrimSpace(w.Word); word != "" {
+					segment.Words = append(segment.Words, models.Word{Start: w.Start, End: w.End, Text: word})
+				}
+			}
+			segments = append(segments, segment)
+		}
+	}
+
+	if len(segments) == 0 {
+		return "", nil, fmt.Errorf("no segments in whisper output")
+	}
+
+	return language, segments, nil
+}
+
+// parseSRT reads SubRip cues into segments
+func parseSRT(content string) ([]models.Segment, error) {
+	segments := []models.Segment{}
+	scanner := bufio.NewScanner(strings.NewReader(strings.ReplaceAll(content, "\r\n", "\n")))
+
+	var current *models.Segment
+	var lines []string
+	flush := func() {
+		if current != nil && len(lines) > 0 {
+			current.Text = strings.TrimSpace(strings.Join(lines, " "))
+			segments = append(segments, *current)
+		}
+		current = nil
+		lines = nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			flush()
+		case strings.Contains(line, "-->"):
+			parts := strings.SplitN(line, "-->", 2)
+			start, err := parseSRTTimestamp(parts[0])
+			if err != nil {
+				return nil, err
+			}
+			end, err := parseSRTTimestamp(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			current = &models.Segment{Start: start, End: end}
+		case current != nil:
+			lines = append(lines, line)
+		}
+	}
+	flush()
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no cues in SRT output")
+	}
+
+	return segments, nil
+}
+
+// parseSRTTimestamp parses "HH:MM:SS,mmm" into seconds
+func parseSRTTimestamp(value string) (float64, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ",", ".")
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid SRT timestamp: %q", value)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid SRT timestamp: %q", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid SRT timestamp: %q", value)
+	}
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid SRT timestamp: %q", value)
+	}
+
+	return float64(hours*3600+minutes*60) + seconds, nil
+}
+
+// lastLines trims noisy CLI output to its tail for error messages
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
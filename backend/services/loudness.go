@@ -0,0 +1,114 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"shortgenerator/models"
+	"strings"
+)
+
+// loudnessTarget is an EBU R128 target: integrated loudness (LUFS), true peak (dBTP)
+// and loudness range (LU)
+type loudnessTarget struct {
+	integrated float64
+	truePeak   float64
+	lra        float64
+}
+
+// Loudness targets by platform. Short-form and streaming platforms normalize playback
+// around -14 LUFS; podcasts sit at -16 and broadcast follows EBU R128 at -23.
+var loudnessTargets = map[string]loudnessTarget{
+	"tiktok":          {-14, -1.5, 11},
+	"youtube_shorts":  {-14, -1.5, 11},
+	"instagram_reels": {-14, -1.5, 11},
+	"youtube":         {-14, -1.5, 11},
+	"podcast":         {-16, -1.5, 11},
+	"broadcast":       {-23, -1, 11},
+}
+
+// loudnormMeasurement is the JSON printed by the first loudnorm pass
+type loudnormMeasurement struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// ValidateAudioOptions normalizes the loudness and silence options of the render
+// options and rejects unsupported values
+func ValidateAudioOptions(options *models.RenderOptions) error {
+	options.Loudness = strings.ToLower(strings.TrimSpace(options.Loudness))
+
+	if _, ok := loudnessTargets[options.Loudness]; options.Loudness != "" && !ok {
+		return fmt.Errorf("invalid loudness %q, expected tiktok, youtube_shorts, instagram_reels, youtube, podcast or broadcast", options.Loudness)
+	}
+	if options.MaxPause < 0 || options.MaxPause > maxPauseLimit {
+		return fmt.Errorf("invalid max_pause %.2f, expected 0 to %.0f seconds", options.MaxPause, maxPauseLimit)
+	}
+	if options.MaxPause > 0 && options.MaxPause < silenceMinDuration+2*silencePadding {
+		return fmt.Errorf("invalid max_pause %.2f, expected at least %.2f seconds", options.MaxPause, silenceMinDuration+2*silencePadding)
+	}
+
+	return nil
+}
+
+// loudnormFilter measures the clip audio (the label output of audioGraph, read from
+// inputArgs) with a first loudnorm pass and returns the second pass filter, which
+// applies a linear gain to reach the target. If the measurement fails it falls back
+// to single-pass (dynamic) loudnorm.
+func (s *ProcessingService) loudnormFilter(videoID, clipID string, inputArgs []string, audioGraph, label string, duration float64, target loudnessTarget) string {
+	base := fmt.Sprintf("loudnorm=I=%.1f:TP=%.1f:LRA=%.1f", target.integrated, target.truePeak, target.lra)
+
+	measurement, err := s.measureLoudness(videoID, clipID, inputArgs, audioGraph, label, duration, base)
+	if err != nil {
+		log.Printf("⚠️  Loudness measurement failed, using single-pass loudnorm: %v", err)
+		return base
+	}
+	log.Printf("🔊 Measured %s LUFS, %s dBTP, %s LU (target %.0f LUFS)", measurement.InputI, measurement.InputTP, measurement.InputLRA, target.integrated)
+
+	return fmt.Sprintf("%s:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+		base, measurement.InputI, measurement.InputTP, measurement.InputLRA, measurement.InputThresh, measurement.TargetOffset)
+}
+
+// measureLoudness runs the first loudnorm pass over the clip audio
+func (s *ProcessingService) measureLoudness(videoID, clipID string, inputArgs []string, audioGraph, label string, duration float64, loudnorm string) (loudnormMeasurement, error) {
+	args := append(append([]string{}, inputArgs...),
+		"-filter_complex", audioGraph+";"+label+loudnorm+":print_format=json[aout]",
+		"-map", "[aout]",
+		"-f", "null", "-",
+	)
+
+	output, err := s.runFFmpegWithProgress(args, duration, func(update ProgressUpdate) {
+		update.VideoID = videoID
+		update.ClipID = clipID
+		update.Stage = StageAnalyzeAudio
+		s.progress.report(update)
+	})
+	if err != nil {
+		return loudnormMeasurement{}, fmt.Errorf("%v, output: %s", err, lastLines(string(output), 20))
+	}
+
+	return parseLoudnorm(string(output))
+}
+
+// parseLoudnorm extracts the JSON block loudnorm prints at the end of its output
+func parseLoudnorm(output string) (loudnormMeasurement, error) {
+	var measurement loudnormMeasurement
+
+	start := strings.LastIndex(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return measurement, fmt.Errorf("no loudnorm measurement in ffmpeg output")
+	}
+	if err := json.Unmarshal([]byte(output[start:end+1]), &measurement); err != nil {
+		return measurement, fmt.Errorf("failed to parse loudnorm measurement: %v", err)
+	}
+	// Silent audio measures as -inf, which loudnorm does not accept back
+	if measurement.InputI == "" || strings.Contains(measurement.InputI, "inf") {
+		return measurement, fmt.Errorf("audio is silent")
+	}
+
+	return measurement, nil
+}
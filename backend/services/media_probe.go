@@ -0,0 +1,121 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MediaInfo is the subset of ffprobe output we care about
+type MediaInfo struct {
+	Title    string
+	Duration float64
+	Width    int
+	Height   int
+	HasVideo bool
+	HasAudio bool
+}
+
+// ProbeMedia reads container and stream metadata from a local file using ffprobe
+func (s *ProcessingService) ProbeMedia(path string) (*MediaInfo, error) {
+	cmd := exec.Command(s.ffprobePath,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %v", err)
+	}
+
+	var probe struct {
+		Format struct {
+			Duration string            `json:"duration"`
+			Tags     map[string]string `json:"tags"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
+		} `json:"streams"`
+	}
+
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %v", err)
+	}
+
+	info := &MediaInfo{}
+	info.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+
+	for key, value := range probe.Format.Tags {
+		if strings.EqualFold(key, "title") {
+			info.Title = strings.TrimSpace(value)
+		}
+	}
+
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			if !info.HasVideo {
+				info.Width = stream.Width
+				info.Height = stream.Height
+			}
+			info.HasVideo = true
+		case "audio":
+			info.HasAudio = true
+		}
+	}
+
+	return info, nil
+}
+
+// ExtractThumbnail grabs a single frame at the given time and stores it as a JPEG
+func (s *ProcessingService) ExtractThumbnail(videoPath string, videoID string, at float64) (string, error) {
+	outputDir := filepath.Join(s.storagePath, "thumbnails")
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create thumbnails directory: %v", err)
+	}
+
+	outputPath := filepath.Join(outputDir, videoID+".jpg")
+
+	cmd := exec.Command(s.ffmpegPath,
+		"-y",
+		"-ss", fmt.Sprintf("%.2f", at),
+		"-i", videoPath,
+		"-frames:v", "1",
+		"-vf", "scale=640:-2",
+		"-q:v", "3",
+		outputPath,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to extract thumbnail: %v, output: %s", err, output)
+	}
+
+	log.Printf("🖼️  Thumbnail extracted: %s", outputPath)
+	return outputPath, nil
+}
+
+// ThumbnailPath returns where ExtractThumbnail stores the poster frame for a video
+func (s *ProcessingService) ThumbnailPath(videoID string) string {
+	return filepath.Join(s.storagePath, "thumbnails", videoID+".jpg")
+}
+
+// VideoStoragePath returns the path for a stored source video with the given extension
+func (s *ProcessingService) VideoStoragePath(videoID string, ext string) string {
+	return filepath.Join(s.storagePath, "videos", videoID+strings.ToLower(ext))
+}
+
+// StoragePath returns the root storage directory
+func (s *ProcessingService) StoragePath() string {
+	return s.storagePath
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"shortgenerator/models"
+	"strings"
+)
+
+const (
+	defaultMusicVolume = 0.25
+	musicFadeDuration  = 1.5 // fade in and out at the clip boundaries, at most a quarter of the clip
+)
+
+// Ducking: the music drops about 12 dB a few milliseconds after speech starts and
+// comes back within half a second of a pause
+const (
+	duckThreshold = 0.03
+	duckRatio     = 8
+	duckAttackMs  = 20
+	duckReleaseMs = 400
+)
+
+// ValidateMusicOptions normalizes the music options of the render options and
+// rejects unsupported values. Without a track the other options are cleared.
+func ValidateMusicOptions(options *models.RenderOptions) error {
+	options.MusicID = strings.TrimSpace(options.MusicID)
+	if options.MusicID == "" {
+		options.MusicStart, options.MusicVolume = 0, 0
+		return nil
+	}
+
+	if options.MusicVolume == 0 {
+		options.MusicVolume = defaultMusicVolume
+	}
+	if options.MusicVolume < 0 || options.MusicVolume > 1 {
+		return fmt.Errorf("invalid music_volume %.2f, expected 0 to 1", options.MusicVolume)
+	}
+	if options.MusicStart < 0 {
+		return fmt.Errorf("invalid music_start %.2f, expected 0 or more seconds", options.MusicStart)
+	}
+
+	return nil
+}
+
+// musicInputArgs opens the track at the start offset, looping it so short tracks
+// cover the whole clip
+func musicInputArgs(track *models.MusicTrack, start float64) []string {
+	return []string{"-stream_loop", "-1", "-ss", fmt.Sprintf("%.3f", start), "-i", track.FilePath}
+}
+
+// musicBedGraph mixes the music (read from input) under the speech label and
+// outputs [amusic]. The music is cut to the clip duration and fades in and out; a
+// sidechain compressor keyed on the speech ducks it while someone talks. Without
+// speech the music alone is the audio.
+func musicBedGraph(input int, speech string, volume, duration float64) string {
+	fade := math.Min(musicFadeDuration, duration/4)
+	music := fmt.Sprintf("[%d:a]%s,volume=%.2f,atrim=duration=%.3f,afade=t=in:st=0:d=%.3f,afade=t=out:st=%.3f:d=%.3f",
+		input, stereoAudioFormat, volume, duration, fade, duration-fade, fade)
+	if speech == "" {
+		return music + "[amusic]"
+	}
+
+	return fmt.Sprintf("%s%s,asplit=2[speech][key];%s[music];"+
+		"[music][key]sidechaincompress=threshold=%.2f:ratio=%d:attack=%d:release=%d[ducked];"+
+		"[speech][ducked]amix=inputs=2:duration=first:dropout_transition=0:normalize=0[amusic]",
+		speech, stereoAudioFormat, music, duckThreshold, duckRatio, duckAttackMs, duckReleaseMs)
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"shortgenerator/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var supportedMusicExtensions = map[string]bool{
+	".mp3":  true,
+	".m4a":  true,
+	".aac":  true,
+	".wav":  true,
+	".ogg":  true,
+	".opus": true,
+	".flac": true,
+}
+
+// IsSupportedMusic reports whether a filename has an audio extension we can mix
+func IsSupportedMusic(filename string) bool {
+	return supportedMusicExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// MusicService stores the music tracks exports can use as a background bed
+type MusicService struct {
+	db          *sql.DB
+	storagePath string
+}
+
+func NewMusicService(db *sql.DB, storagePath string) *MusicService {
+	return &MusicService{db: db, storagePath: storagePath}
+}
+
+// NewTrack assigns an ID and a storage path to a track uploaded as filename
+func (s *MusicService) NewTrack(name, filename string) (*models.MusicTrack, error) {
+	dir := filepath.Join(s.storagePath, "music")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+
+	id := uuid.New().String()
+	return &models.MusicTrack{
+		ID:       id,
+		Name:     name,
+		FilePath: filepath.Join(dir, id+strings.ToLower(filepath.Ext(filename))),
+	}, nil
+}
+
+func (s *MusicService) CreateTrack(track *models.MusicTrack) error {
+	track.CreatedAt = time.Now()
+
+	query := `INSERT INTO music_tracks (id, name, file_path, duration, created_at) VALUES (?, ?, ?, ?, ?)`
+	_, err := s.db.Exec(query, track.ID, track.Name, track.FilePath, track.Duration, track.CreatedAt)
+
+	return err
+}
+
+func (s *MusicService) GetTrack(id string) (*models.MusicTrack, error) {
+	track := &models.MusicTrack{}
+
+	query := `SELECT id, name, file_path, COALESCE(duration, 0), created_at FROM music_tracks WHERE id = ?`
+	err := s.db.QueryRow(query, id).Scan(&track.ID, &track.Name, &track.FilePath, &track.Duration, &track.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return track, nil
+}
+
+func (s *MusicService) GetTracks() ([]models.MusicTrack, error) {
+	rows, err := s.db.Query(`SELECT id, name, file_path, COALESCE(duration, 0), created_at FROM music_tracks ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracks := []models.MusicTrack{}
+	for rows.Next() {
+		var track models.MusicTrack
+		if err := rows.Scan(&track.ID, &track.Name, &track.FilePath, &track.Duration, &track.CreatedAt); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+
+	return tracks, rows.Err()
+}
+
+// DeleteTrack removes the track and its file
+func (s *MusicService) DeleteTrack(track *models.MusicTrack) error {
+	if _, err := s.db.Exec(`DELETE FROM music_tracks WHERE id = ?`, track.ID); err != nil {
+		return err
+	}
+	if err := os.Remove(track.FilePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
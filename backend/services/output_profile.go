@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"shortgenerator/models"
+	"strings"
+)
+
+// Output aspect ratios, as width and height
+var outputAspectRatios = map[string][2]int{
+	"9:16": {9, 16},
+	"4:5":  {4, 5},
+	"1:1":  {1, 1},
+	"16:9": {16, 9},
+}
+
+const (
+	defaultAspectRatio = "9:16"
+	defaultResolution  = 1080
+	minResolution      = 240
+	maxResolution      = 2160
+)
+
+// outputProfile is the frame a clip is encoded to
+type outputProfile struct {
+	width   int
+	height  int
+	framing string
+
+	// cropCenter is an expression of t for the horizontal center of the crop window
+	// (fraction of the frame width), empty for a center crop
+	cropCenter string
+}
+
+// ValidateOutputProfile normalizes the aspect ratio, resolution and framing of the
+// render options and rejects unsupported values. Empty values keep the defaults.
+func ValidateOutputProfile(options *models.RenderOptions) error {
+	options.AspectRatio = strings.TrimSpace(options.AspectRatio)
+	options.Framing = strings.ToLower(strings.TrimSpace(options.Framing))
+
+	if _, ok := outputAspectRatios[options.AspectRatio]; options.AspectRatio != "" && !ok {
+		return fmt.Errorf("invalid aspect_ratio %q, expected 9:16, 4:5, 1:1 or 16:9", options.AspectRatio)
+	}
+	if options.Resolution != 0 && (options.Resolution < minResolution || options.Resolution > maxResolution) {
+		return fmt.Errorf("invalid resolution %d, expected %d to %d", options.Resolution, minResolution, maxResolution)
+	}
+
+	switch options.Framing {
+	case "", models.FramingCrop, models.FramingLetterbox, models.FramingBlur:
+	default:
+		return fmt.Errorf("invalid framing %q, expected %s, %s or %s", options.Framing, models.FramingCrop, models.FramingLetterbox, models.FramingBlur)
+	}
+
+	return nil
+}
+
+// newOutputProfile resolves the render options to output dimensions. The resolution
+// is the short side; both sides are rounded to even numbers for yuv420p.
+func newOutputProfile(options models.RenderOptions) outputProfile {
+	ratio, ok := outputAspectRatios[options.AspectRatio]
+	if !ok {
+		ratio = outputAspectRatios[defaultAspectRatio]
+	}
+	resolution := options.Resolution
+	if resolution == 0 {
+		resolution = defaultResolution
+	}
+	framing := options.Framing
+	if framing == "" {
+		framing = models.FramingCrop
+	}
+
+	short, long := ratio[0], ratio[1]
+	if short > long {
+		short, long = long, short
+	}
+	longSide := evenInt(float64(resolution) * float64(long) / float64(short))
+	shortSide := evenInt(float64(resolution))
+
+	profile := outputProfile{width: shortSide, height: longSide, framing: framing}
+	if ratio[0] > ratio[1] {
+		profile.width, profile.height = longSide, shortSide
+	}
+	return profile
+}
+
+// String is the profile as ffmpeg's WxH
+func (p outputProfile) String() string {
+	return fmt.Sprintf("%dx%d", p.width, p.height)
+}
+
+// blurBackgroundSigma is the gaussian blur of the background copy in blur framing
+const blurBackgroundSigma = 40
+
+// videoFilter fits the source into the output frame
+func (p outputProfile) videoFilter() string {
+	switch p.framing {
+	case models.FramingLetterbox:
+		return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:black,setsar=1",
+			p.width, p.height, p.width, p.height)
+	case models.FramingBlur:
+		// The background is a cropped, blurred and slightly darkened copy that fills
+		// the frame; the whole source is scaled to fit and centered on top
+		return fmt.Sprintf("split[bg][fg];"+
+			"[bg]scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d,gblur=sigma=%d,eq=brightness=-0.08[bgb];"+
+			"[fg]scale=%d:%d:force_original_aspect_ratio=decrease[fgs];"+
+			"[bgb][fgs]overlay=(W-w)/2:(H-h)/2,setsar=1",
+			p.width, p.height, p.width, p.height, blurBackgroundSigma, p.width, p.height)
+	}
+	if p.cropCenter != "" {
+		x := filterExpr(fmt.Sprintf("clip((%s)*iw-ow/2,0,iw-ow)", p.cropCenter))
+		return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d:%s:(ih-oh)/2,setsar=1",
+			p.width, p.height, p.width, p.height, x)
+	}
+	return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d,setsar=1",
+		p.width, p.height, p.width, p.height)
+}
+
+// cropsWidth reports whether a source of the given size loses its sides when
+// cropped to this profile, the case where reframing can follow a face
+func (p outputProfile) cropsWidth(sourceWidth, sourceHeight int) bool {
+	return p.framing == models.FramingCrop && sourceHeight > 0 &&
+		float64(sourceWidth)/float64(sourceHeight) > float64(p.width)/float64(p.height)
+}
+
+// fitsWholeFrame reports whether the source is scaled to fit instead of cropped,
+// leaving bars or background around it
+func (p outputProfile) fitsWholeFrame() bool {
+	return p.framing == models.FramingLetterbox || p.framing == models.FramingBlur
+}
+
+// subtitleLayout is where subtitles are laid out in this profile. When the whole
+// source frame is fitted, subtitles are positioned within the visible picture; the
+// source size is needed for that and zero means unknown (the full frame is used).
+func (p outputProfile) subtitleLayout(sourceWidth, sourceHeight int) subtitleLayout {
+	layout := newSubtitleLayout(p.width, p.height)
+	if !p.fitsWholeFrame() || sourceWidth <= 0 || sourceHeight <= 0 {
+		return layout
+	}
+
+	fit := math.Min(float64(p.width)/float64(sourceWidth), float64(p.height)/float64(sourceHeight))
+	layout.area = subtitleArea{
+		width:  float64(sourceWidth) * fit,
+		height: float64(sourceHeight) * fit,
+	}
+	layout.area.x = (float64(p.width) - layout.area.width) / 2
+	layout.area.y = (float64(p.height) - layout.area.height) / 2
+	return layout
+}
+
+func evenInt(v float64) int {
+	return int(math.Round(v/2)) * 2
+}
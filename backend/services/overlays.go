@@ -0,0 +1,169 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"shortgenerator/models"
+	"strings"
+)
+
+// Overlay defaults and limits, in editor canvas pixels
+const (
+	defaultHeadlineFontSize   = 28
+	defaultHeadlineFontWeight = 800
+	maxHeadlineFontSize       = 96
+	maxHeadlineLines          = 3
+	defaultBarThickness       = 4
+	maxBarThickness           = 40
+	defaultBarBgOpacity       = 0.35
+	progressBarFPS            = 30
+)
+
+// ValidateOverlays normalizes the headline and progress bar of the render options,
+// filling defaults, and rejects unsupported values. A headline without text is
+// dropped.
+func ValidateOverlays(options *models.RenderOptions) error {
+	if h := options.Headline; h != nil {
+		h.Text = strings.TrimSpace(h.Text)
+		h.Position = strings.ToLower(strings.TrimSpace(h.Position))
+		if h.Text == "" {
+			options.Headline = nil
+		} else {
+			if h.FontSize == 0 {
+				h.FontSize = defaultHeadlineFontSize
+			}
+			if h.FontSize < 0 || h.FontSize > maxHeadlineFontSize {
+				return fmt.Errorf("invalid headline font_size %d, expected 1 to %d", h.FontSize, maxHeadlineFontSize)
+			}
+			if h.FontWeight == 0 {
+				h.FontWeight = defaultHeadlineFontWeight
+			}
+			if h.BgOpacity < 0 || h.BgOpacity > 1 {
+				return fmt.Errorf("invalid headline bg_opacity %.2f, expected 0 to 1", h.BgOpacity)
+			}
+			switch h.Position {
+			case "":
+				h.Position = "top"
+			case "top", "center", "bottom":
+			default:
+				return fmt.Errorf("invalid headline position %q, expected top, center or bottom", h.Position)
+			}
+		}
+	}
+
+	if bar := options.ProgressBar; bar != nil {
+		bar.Position = strings.ToLower(strings.TrimSpace(bar.Position))
+		if bar.Thickness == 0 {
+			bar.Thickness = defaultBarThickness
+		}
+		if bar.Thickness < 0 || bar.Thickness > maxBarThickness {
+			return fmt.Errorf("invalid progress_bar thickness %d, expected 1 to %d", bar.Thickness, maxBarThickness)
+		}
+		if bar.BgOpacity < 0 || bar.BgOpacity > 1 {
+			return fmt.Errorf("invalid progress_bar bg_opacity %.2f, expected 0 to 1", bar.BgOpacity)
+		}
+		switch bar.Position {
+		case "":
+			bar.Position = "bottom"
+		case "top", "bottom":
+		default:
+			return fmt.Errorf("invalid progress_bar position %q, expected top or bottom", bar.Position)
+		}
+	}
+
+	return nil
+}
+
+// overlayGraph draws the headline and progress bar of the render options over the
+// video label in and outputs out, or returns "" when there are none. Both span the
+// export timeline, which lasts duration seconds.
+func (s *ProcessingService) overlayGraph(options models.RenderOptions, layout subtitleLayout, duration float64, in, out string) string {
+	filters := []string{}
+	if options.Headline != nil {
+		filters = append(filters, s.headlineFilters(*options.Headline, layout)...)
+	}
+
+	bar := options.ProgressBar
+	if bar == nil || duration <= 0 {
+		if len(filters) == 0 {
+			return ""
+		}
+		return in + joinFilters(filters...) + out
+	}
+
+	thickness := int(math.Max(1, math.Round(float64(bar.Thickness)*layout.scale)))
+	y := layout.height - thickness
+	if bar.Position == "top" {
+		y = 0
+	}
+
+	// The track is a static drawbox. drawbox cannot change its size over time, so the
+	// bar is a solid color strip slid in from the left with an overlay x expression.
+	if bar.BgColor != "" {
+		opacity := bar.BgOpacity
+		if opacity == 0 {
+			opacity = defaultBarBgOpacity
+		}
+		filters = append(filters, fmt.Sprintf("drawbox=x=0:y=%d:w=iw:h=%d:color=%s:t=fill",
+			y, thickness, s.parseColorWithAlpha(bar.BgColor, opacity)))
+	}
+	base := joinFilters(filters...)
+	if base == "" {
+		base = "null"
+	}
+
+	return fmt.Sprintf("%s%s[vbarbase];color=c=%s:s=%dx%d:r=%d[vbar];[vbarbase][vbar]overlay=x=%s:y=%d:shortest=1%s",
+		in, base, s.parseColorToFFmpeg(bar.Color, "#FFFFFF"), layout.width, thickness, progressBarFPS,
+		filterExpr(fmt.Sprintf("-w+W*min(t/%.3f,1)", duration)), y, out)
+}
+
+// headlineFilters draws the headline as one drawtext per line, wrapped to the
+// visible picture (long headlines shrink to fit maxHeadlineLines) and anchored at
+// its position like a subtitle: the first line at the top position, the last at
+// the bottom one, the block centered otherwise
+func (s *ProcessingService) headlineFilters(h models.HeadlineOverlay, layout subtitleLayout) []string {
+	fontPath := resolveFontPath(h.FontFamily, h.FontWeight, h.FontWeight >= 600, h.Italic)
+	fontSize := int(math.Round(math.Max(float64(h.FontSize), minFontSize) * layout.scale))
+
+	words := strings.Fields(h.Text)
+	starts := wrapWords(fontPath, fontSize, words, int(layout.area.width*0.9))
+	for len(starts) > maxHeadlineLines && fontSize > 8 {
+		fontSize = int(float64(fontSize) * 0.9)
+		starts = wrapWords(fontPath, fontSize, words, int(layout.area.width*0.9))
+	}
+	lines := make([]string, len(starts))
+	for i, start := range starts {
+		end := len(words)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		lines[i] = strings.Join(words[start:end], " ")
+	}
+
+	lineHeight := float64(fontSize) * 1.25
+	firstY := layout.targetY(h.Position)
+	switch h.Position {
+	case "center":
+		firstY -= lineHeight * float64(len(lines)-1) / 2
+	case "bottom":
+		firstY -= lineHeight * float64(len(lines)-1)
+	}
+
+	box := "box=0"
+	if h.BgColor != "" {
+		opacity := h.BgOpacity
+		if opacity == 0 {
+			opacity = 0.8
+		}
+		box = fmt.Sprintf("box=1:boxcolor=%s:boxborderw=%d", s.parseColorWithAlpha(h.BgColor, opacity), int(math.Round(paddingPx*layout.scale)))
+	}
+
+	filters := []string{}
+	for i, line := range lines {
+		// Lines share a baseline offset so they are evenly spaced whatever their glyphs
+		y := fmt.Sprintf("(%.2f)+%d-max_glyph_a", firstY+lineHeight*float64(i), int(math.Round(float64(fontSize)*0.35)))
+		filters = append(filters, fmt.Sprintf("drawtext=text='%s':fontfile=%s:fontsize=%d:fontcolor=%s:%s:x=(w-text_w)/2:y=%s",
+			escapeDrawtext(line), fontPath, fontSize, s.parseColorToFFmpeg(h.Color, "#FFFFFF"), box, filterExpr(y)))
+	}
+	return filters
+}
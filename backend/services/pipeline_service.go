@@ -0,0 +1,387 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"shortgenerator/models"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Pipeline stage names, stored as processing_jobs.type
+const (
+	StageDownload   = "download"
+	StageProbe      = "probe"
+	StageScenes     = "scenes"
+	StageTranscribe = "transcribe"
+	StageAnalyze    = "analyze"
+)
+
+// StatusNotifier is called whenever a video changes status (e.g. to push it over WebSocket)
+type StatusNotifier func(videoID string, status string)
+
+type pipelineStage struct {
+	name   string
+	status string // video status while the stage runs
+	run    func(p *PipelineService, video *models.Video) error
+	// optional stages that fail leave their job in error, so a retry runs them
+	// again, but do not stop the stages after them
+	optional bool
+}
+
+var (
+	downloadStage   = pipelineStage{StageDownload, "downloading", (*PipelineService).runDownload, false}
+	probeStage      = pipelineStage{StageProbe, "probing", (*PipelineService).runProbe, false}
+	scenesStage     = pipelineStage{StageScenes, "detecting_scenes", (*PipelineService).runScenes, true}
+	transcribeStage = pipelineStage{StageTranscribe, "transcribing", (*PipelineService).runTranscribe, false}
+	analyzeStage    = pipelineStage{StageAnalyze, "analyzing", (*PipelineService).runAnalyze, false}
+)
+
+// stagesFor returns the ordered stages for a video. Uploaded files are probed
+// locally instead of being fetched with yt-dlp; everything after is shared.
+func stagesFor(video *models.Video) []pipelineStage {
+	if video.SourceType == models.SourceTypeUpload {
+		return []pipelineStage{probeStage, scenesStage, transcribeStage, analyzeStage}
+	}
+	return []pipelineStage{downloadStage, scenesStage, transcribeStage, analyzeStage}
+}
+
+// unfinishedVideoStatuses are the video statuses that mean the pipeline has not ended
+var unfinishedVideoStatuses = []string{"pending", "downloading", "probing", "detecting_scenes", "transcribing", "analyzing"}
+
+// PipelineService runs ingest → transcribe → analyze for each video on a bounded
+// worker pool, recording every stage in processing_jobs so work survives restarts.
+type PipelineService struct {
+	videoService      *VideoService
+	jobService        *JobService
+	processingService *ProcessingService
+	pool              *WorkerPool
+	notify            StatusNotifier
+
+	mu     sync.Mutex
+	active map[string]bool // videos queued or running
+}
+
+func NewPipelineService(videoService *VideoService, jobService *JobService, processingService *ProcessingService) *PipelineService {
+	workers, err := strconv.Atoi(getEnv("MAX_CONCURRENT_JOBS", "2"))
+	if err != nil || workers < 1 {
+		workers = 2
+	}
+
+	return &PipelineService{
+		videoService:      videoService,
+		jobService:        jobService,
+		processingService: processingService,
+		pool:              NewWorkerPool("pipeline", workers),
+		notify:            func(string, string) {},
+		active:            make(map[string]bool),
+	}
+}
+
+// SetNotifier registers the callback used to broadcast video status changes
+func (p *PipelineService) SetNotifier(notify StatusNotifier) {
+	if notify == nil {
+		notify = func(string, string) {}
+	}
+	p.notify = notify
+}
+
+// Start launches the worker pool
+func (p *PipelineService) Start() {
+	p.pool.Start()
+}
+
+// Submit queues a video for processing. Stages that already completed are skipped.
+// Returns false if the video is already queued or running.
+func (p *PipelineService) Submit(videoID string) bool {
+	p.mu.Lock()
+	if p.active[videoID] {
+		p.mu.Unlock()
+		return false
+	}
+	p.active[videoID] = true
+	p.mu.Unlock()
+
+	queued, running := p.pool.Stats()
+	log.Printf("📋 [%s] Queued for processing (%d queued, %d running)", videoID, queued, running)
+
+	p.pool.Enqueue(func() {
+		defer func() {
+			p.mu.Lock()
+			delete(p.active, videoID)
+			p.mu.Unlock()
+		}()
+		p.process(videoID)
+	})
+
+	return true
+}
+
+// Recover re-queues videos left unfinished by a previous run of the server
+func (p *PipelineService) Recover() error {
+	interrupted, err := p.jobService.MarkInterruptedJobs()
+	if err != nil {
+		return fmt.Errorf("failed to mark interrupted jobs: %v", err)
+	}
+
+	videos, err := p.videoService.GetVideosByStatus(unfinishedVideoStatuses...)
+	if err != nil {
+		return fmt.Errorf("failed to load unfinished videos: %v", err)
+	}
+
+	if interrupted > 0 || len(videos) > 0 {
+		log.Printf("♻️  Recovering %d unfinished videos (%d interrupted jobs)", len(videos), interrupted)
+	}
+
+	for _, video := range videos {
+		p.Submit(video.ID)
+	}
+
+	return nil
+}
+
+func (p *PipelineService) process(videoID string) {
+	video, err := p.videoService.GetVideo(videoID)
+	if err != nil {
+		log.Printf("❌ [%s] Failed to load video for processing: %v", videoID, err)
+		return
+	}
+
+	completed, err := p.jobService.CompletedStages(videoID)
+	if err != nil {
+		log.Printf("❌ [%s] Failed to load completed stages: %v", videoID, err)
+		p.setStatus(video, "error")
+		return
+	}
+
+	for _, stage := range stagesFor(video) {
+		if completed[stage.name] {
+			log.Printf("⏭️  [%s] Skipping %s stage (already completed)", videoID, stage.name)
+			continue
+		}
+
+		if err := p.runStage(video, stage); err != nil {
+			if stage.optional {
+				log.Printf("⚠️  [%s] %s stage failed, continuing without it: %v", videoID, stage.name, err)
+				continue
+			}
+			log.Printf("❌ [%s] %s stage failed: %v", videoID, stage.name, err)
+			p.setStatus(video, "error")
+			return
+		}
+
+		// Scenes retried after the analysis: its suggestions never saw these cuts
+		if stage.name == StageScenes && completed[StageAnalyze] {
+			if err := p.nudgeSuggestionsToScenes(video); err != nil {
+				log.Printf("⚠️  [%s] Failed to move suggested clips onto scene cuts: %v", videoID, err)
+			}
+		}
+	}
+
+	log.Printf("🎉 [%s] All processing completed successfully!", videoID)
+	p.setStatus(video, "completed")
+	log.Printf("✅ [%s] Video ready: %s (Duration: %ds)", videoID, video.Title, video.Duration)
+}
+
+// IncompleteStages returns the stages of a video that have not completed, such as
+// an optional stage that failed in a video that otherwise finished
+func (p *PipelineService) IncompleteStages(video *models.Video) ([]string, error) {
+	completed, err := p.jobService.CompletedStages(video.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	incomplete := []string{}
+	for _, stage := range stagesFor(video) {
+		if !completed[stage.name] {
+			incomplete = append(incomplete, stage.name)
+		}
+	}
+	return incomplete, nil
+}
+
+func (p *PipelineService) runStage(video *models.Video, stage pipelineStage) error {
+	job, err := p.jobService.CreateJob(video.ID, stage.name, "running")
+	if err != nil {
+		return fmt.Errorf("failed to create job record: %v", err)
+	}
+
+	log.Printf("▶️  [%s] Starting %s stage (job %s)", video.ID, stage.name, job.ID)
+	p.setStatus(video, stage.status)
+
+	if err := stage.run(p, video); err != nil {
+		job.Status = "error"
+		job.Message = err.Error()
+		if updateErr := p.jobService.UpdateJob(job); updateErr != nil {
+			log.Printf("⚠️  [%s] Failed to update job %s: %v", video.ID, job.ID, updateErr)
+		}
+		return err
+	}
+
+	job.Status = "completed"
+	job.Progress = 100
+	job.Message = ""
+	if err := p.jobService.UpdateJob(job); err != nil {
+		return fmt.Errorf("failed to update job record: %v", err)
+	}
+
+	return nil
+}
+
+func (p *PipelineService) setStatus(video *models.Video, status string) {
+	video.Status = status
+	if err := p.videoService.UpdateVideo(video); err != nil {
+		log.Printf("⚠️  [%s] Failed to update video status: %v", video.ID, err)
+	}
+	p.notify(video.ID, status)
+}
+
+func (p *PipelineService) runDownload(video *models.Video) error {
+	log.Printf("⬇️  [%s] Downloading video from URL: %s", video.ID, video.URL)
+	downloadedVideo, err := p.processingService.DownloadVideo(video.URL, video.ID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ [%s] Video downloaded successfully: %s", video.ID, downloadedVideo.Title)
+
+	video.Title = downloadedVideo.Title
+	video.Duration = downloadedVideo.Duration
+	video.FilePath = downloadedVideo.FilePath
+	video.ThumbnailURL = downloadedVideo.ThumbnailURL
+
+	return p.videoService.UpdateVideo(video)
+}
+
+func (p *PipelineService) runProbe(video *models.Video) error {
+	if video.FilePath == "" {
+		return fmt.Errorf("video file path is empty")
+	}
+
+	log.Printf("🔎 [%s] Probing uploaded file: %s", video.ID, video.FilePath)
+	info, err := p.processingService.ProbeMedia(video.FilePath)
+	if err != nil {
+		return err
+	}
+	if !info.HasVideo {
+		return fmt.Errorf("uploaded file has no video stream")
+	}
+	if !info.HasAudio {
+		return fmt.Errorf("uploaded file has no audio stream")
+	}
+
+	// An explicit title wins, then the container's title tag, then the original file name
+	if video.Title == "" {
+		video.Title = info.Title
+	}
+	if video.Title == "" {
+		base := filepath.Base(video.URL)
+		video.Title = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	video.Duration = int(math.Round(info.Duration))
+
+	// A missing poster frame should not block transcription
+	if _, err := p.processingService.ExtractThumbnail(video.FilePath, video.ID, info.Duration*0.1); err != nil {
+		log.Printf("⚠️  [%s] Failed to extract thumbnail: %v", video.ID, err)
+	} else {
+		video.ThumbnailURL = "/api/videos/" + video.ID + "/thumbnail"
+	}
+
+	log.Printf("✅ [%s] Probed %dx%d, %ds", video.ID, info.Width, info.Height, video.Duration)
+
+	return p.videoService.UpdateVideo(video)
+}
+
+// runScenes detects shot changes so suggested clips can start and end on cuts. The
+// stage is optional: when detection fails the video is analyzed without cuts.
+func (p *PipelineService) runScenes(video *models.Video) error {
+	if video.FilePath == "" {
+		return fmt.Errorf("video file path is empty")
+	}
+
+	log.Printf("🎞️  [%s] Detecting scene changes...", video.ID)
+	scenes, err := p.processingService.DetectScenes(video.FilePath, video.ID, float64(video.Duration))
+	if err != nil {
+		return fmt.Errorf("scene detection failed: %v", err)
+	}
+
+	log.Printf("✅ [%s] Scene detection completed: %d shots", video.ID, len(scenes))
+
+	if err := p.videoService.SaveScenes(video.ID, scenes); err != nil {
+		return fmt.Errorf("failed to save scenes: %v", err)
+	}
+
+	return nil
+}
+
+// nudgeSuggestionsToScenes moves the stored suggestions of an analyzed video onto
+// the cuts of a scene detection that ran after the analysis
+func (p *PipelineService) nudgeSuggestionsToScenes(video *models.Video) error {
+	scenes, err := p.videoService.GetScenes(video.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load scenes: %v", err)
+	}
+	transcript, err := p.videoService.GetTranscript(video.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load transcript: %v", err)
+	}
+	clips, err := p.videoService.GetSuggestedClips(video.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load suggested clips: %v", err)
+	}
+
+	changed := nudgeSuggestionsToSceneCuts(clips, transcript, sceneCutTimes(scenes))
+	log.Printf("✅ [%s] %d of %d suggested clips moved onto scene cuts", video.ID, len(changed), len(clips))
+
+	return p.videoService.UpdateSuggestedClipTimes(changed)
+}
+
+func (p *PipelineService) runTranscribe(video *models.Video) error {
+	if video.FilePath == "" {
+		return fmt.Errorf("video file path is empty")
+	}
+
+	log.Printf("🎤 [%s] Transcribing audio...", video.ID)
+	transcript, err := p.processingService.TranscribeVideo(video.FilePath, video.ID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ [%s] Transcription completed: %d segments", video.ID, len(transcript.Segments))
+
+	if err := p.videoService.SaveTranscript(transcript); err != nil {
+		return fmt.Errorf("failed to save transcript: %v", err)
+	}
+
+	return nil
+}
+
+func (p *PipelineService) runAnalyze(video *models.Video) error {
+	transcript, err := p.videoService.GetTranscript(video.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load transcript: %v", err)
+	}
+
+	// Videos processed before scene detection existed simply have no cuts
+	scenes, err := p.videoService.GetScenes(video.ID)
+	if err != nil {
+		log.Printf("⚠️  [%s] Failed to load scenes: %v", video.ID, err)
+	}
+
+	log.Printf("🔍 [%s] Analyzing transcript for viral clips...", video.ID)
+	suggestedClips, err := p.processingService.AnalyzeTranscript(transcript, video, scenes)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ [%s] Analysis completed: %d clips suggested", video.ID, len(suggestedClips))
+
+	if err := p.videoService.SaveSuggestedClips(suggestedClips); err != nil {
+		return fmt.Errorf("failed to save suggested clips: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"shortgenerator/models"
+	"strings"
+)
+
+// Preview defaults and limits
+const (
+	defaultPreviewWidth = 480
+	minPreviewWidth     = 120
+	maxPreviewWidth     = 1080
+	defaultPreviewFPS   = 12
+	maxPreviewFPS       = 30
+	previewWebPQuality  = "75" // libwebp -q:v, 0 to 100
+)
+
+// ValidatePreviewOptions normalizes the preview of the render options, filling
+// defaults, and rejects unsupported values
+func ValidatePreviewOptions(options *models.RenderOptions) error {
+	p := options.Preview
+	if p == nil {
+		return nil
+	}
+
+	p.Format = strings.ToLower(strings.TrimSpace(p.Format))
+	switch p.Format {
+	case "":
+		p.Format = models.PreviewGIF
+	case models.PreviewGIF, models.PreviewWebP:
+	default:
+		return fmt.Errorf("invalid preview format %q, expected %s or %s", p.Format, models.PreviewGIF, models.PreviewWebP)
+	}
+
+	if p.Width == 0 {
+		p.Width = defaultPreviewWidth
+	}
+	if p.Width < minPreviewWidth || p.Width > maxPreviewWidth {
+		return fmt.Errorf("invalid preview width %d, expected %d to %d", p.Width, minPreviewWidth, maxPreviewWidth)
+	}
+
+	if p.FPS == 0 {
+		p.FPS = defaultPreviewFPS
+	}
+	if p.FPS < 1 || p.FPS > maxPreviewFPS {
+		return fmt.Errorf("invalid preview fps %d, expected 1 to %d", p.FPS, maxPreviewFPS)
+	}
+
+	return nil
+}
+
+// previewProfile is the export profile scaled down to the preview width, keeping
+// the framing so the preview shows the same picture as the MP4
+func previewProfile(profile outputProfile, width int) outputProfile {
+	preview := profile
+	preview.width = evenInt(float64(width))
+	preview.height = evenInt(float64(width) * float64(profile.height) / float64(profile.width))
+	return preview
+}
+
+// renderPreview encodes the animated preview of a clip from the same parts as its
+// MP4: silent, at the preview size and frame rate, with the subtitles (already on
+// the export timeline) only when the preview asks for them. Brand cards, the logo,
+// the music and the overlays are left out, the preview loops the clip itself.
+// GIFs take two passes, the first builds a palette for the whole clip.
+func (s *ProcessingService) renderPreview(videoID string, clip *models.Clip, inputPath string, parts clipParts, profile outputProfile, layout subtitleLayout, subtitles []models.SubtitleConfig) (string, error) {
+	options := clip.Preview
+	outputPath := filepath.Join(s.storagePath, "clips", clip.ID+"."+options.Format)
+	log.Printf("🎞️  Preview: %s %s at %d fps (subtitles: %t)", options.Format, profile, options.FPS, options.Subtitles)
+
+	graph := parts.videoGraph(profile) + fmt.Sprintf(";[vc]fps=%d[vp]", options.FPS)
+	videoOut := "[vp]"
+	if options.Subtitles && len(subtitles) > 0 {
+		assPath := filepath.Join(s.storagePath, "clips", clip.ID+".preview.ass")
+		subtitlesFilter, err := s.subtitlesFilter(clip, subtitles, layout, assPath)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(assPath)
+		graph += ";" + videoOut + subtitlesFilter + "[vsub]"
+		videoOut = "[vsub]"
+	}
+
+	run := func(args []string) error {
+		output, err := s.runFFmpegWithProgress(args, parts.outputDuration(), func(update ProgressUpdate) {
+			update.VideoID = videoID
+			update.ClipID = clip.ID
+			update.Stage = StagePreview
+			s.progress.report(update)
+		})
+		if err != nil {
+			os.Remove(outputPath) // no half-written preview next to a completed clip
+			return fmt.Errorf("failed to create preview: %v, output: %s", err, lastLines(string(output), 20))
+		}
+		return nil
+	}
+
+	args := append([]string{"-y"}, parts.inputArgs(inputPath)...)
+	switch options.Format {
+	case models.PreviewWebP:
+		args = append(args,
+			"-filter_complex", graph,
+			"-map", videoOut,
+			"-an",
+			"-c:v", "libwebp",
+			"-lossless", "0",
+			"-q:v", previewWebPQuality,
+			"-loop", "0", // Loop forever
+			outputPath,
+		)
+	default:
+		// diff stats favour what moves over the static background, and rectangle
+		// diff mode only redithers the changed area of each frame
+		palettePath := filepath.Join(s.storagePath, "clips", clip.ID+".palette.png")
+		defer os.Remove(palettePath)
+
+		paletteArgs := append(append([]string{}, args...),
+			"-filter_complex", graph+";"+videoOut+"palettegen=stats_mode=diff[vpal]",
+			"-map", "[vpal]",
+			"-update", "1",
+			palettePath,
+		)
+		if err := run(paletteArgs); err != nil {
+			return "", err
+		}
+
+		paletteInput := len(parts)
+		args = append(args,
+			"-i", palettePath,
+			"-filter_complex", graph+fmt.Sprintf(";%s[%d:v]paletteuse=dither=bayer:bayer_scale=5:diff_mode=rectangle[vgif]", videoOut, paletteInput),
+			"-map", "[vgif]",
+			"-an",
+			"-loop", "0", // Loop forever
+			outputPath,
+		)
+	}
+
+	if err := run(args); err != nil {
+		return "", err
+	}
+
+	log.Printf("✅ Preview created: %s", outputPath)
+	return outputPath, nil
+}
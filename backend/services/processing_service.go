@@ -1,14 +1,9 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"math"
-	"mime/multipart"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -21,71 +16,116 @@ import (
 
 type ProcessingService struct {
 	ffmpegPath  string
+	ffprobePath string
 	ytdlpPath   string
 	whisperPath string
 	storagePath string
+	transcriber Transcriber
+	llm         LLMProvider
+	prompts     *PromptLibrary
+	progress    *progressReporter
 }
 
 type fontVariant struct {
 	weight int
 	path   string
+	italic bool
 }
 
 var fontLibrary = map[string][]fontVariant{
 	"default": {
-		{700, "/usr/share/fonts/dejavu/DejaVuSans-Bold.ttf"},
-		{600, "/usr/share/fonts/dejavu/DejaVuSans-Bold.ttf"},
-		{500, "/usr/share/fonts/dejavu/DejaVuSans.ttf"},
-		{400, "/usr/share/fonts/dejavu/DejaVuSans.ttf"},
-		{300, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf"},
+		{700, "/usr/share/fonts/dejavu/DejaVuSans-Bold.ttf", false},
+		{600, "/usr/share/fonts/dejavu/DejaVuSans-Bold.ttf", false},
+		{500, "/usr/share/fonts/dejavu/DejaVuSans.ttf", false},
+		{400, "/usr/share/fonts/dejavu/DejaVuSans.ttf", false},
+		{300, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf", false},
+		{700, "/usr/share/fonts/dejavu/DejaVuSans-BoldOblique.ttf", true},
+		{400, "/usr/share/fonts/dejavu/DejaVuSans-Oblique.ttf", true},
 	},
 	"inter": {
-		{700, "/usr/share/fonts/dejavu/DejaVuSans-Bold.ttf"},
-		{600, "/usr/share/fonts/dejavu/DejaVuSans-Bold.ttf"},
-		{500, "/usr/share/fonts/dejavu/DejaVuSans.ttf"},
-		{400, "/usr/share/fonts/dejavu/DejaVuSans.ttf"},
+		{700, "/usr/share/fonts/dejavu/DejaVuSans-Bold.ttf", false},
+		{600, "/usr/share/fonts/dejavu/DejaVuSans-Bold.ttf", false},
+		{500, "/usr/share/fonts/dejavu/DejaVuSans.ttf", false},
+		{400, "/usr/share/fonts/dejavu/DejaVuSans.ttf", false},
+		{700, "/usr/share/fonts/dejavu/DejaVuSans-BoldOblique.ttf", true},
+		{400, "/usr/share/fonts/dejavu/DejaVuSans-Oblique.ttf", true},
 	},
 	"poppins": {
-		{700, "/usr/share/fonts/liberation/LiberationSans-Bold.ttf"},
-		{600, "/usr/share/fonts/liberation/LiberationSans-Bold.ttf"},
-		{500, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf"},
-		{400, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf"},
+		{700, "/usr/share/fonts/liberation/LiberationSans-Bold.ttf", false},
+		{600, "/usr/share/fonts/liberation/LiberationSans-Bold.ttf", false},
+		{500, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf", false},
+		{400, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf", false},
+		{700, "/usr/share/fonts/liberation/LiberationSans-BoldItalic.ttf", true},
+		{400, "/usr/share/fonts/liberation/LiberationSans-Italic.ttf", true},
 	},
 	"space grotesk": {
-		{700, "/usr/share/fonts/liberation/LiberationSans-Bold.ttf"},
-		{600, "/usr/share/fonts/liberation/LiberationSans-Bold.ttf"},
-		{500, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf"},
-		{400, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf"},
+		{700, "/usr/share/fonts/liberation/LiberationSans-Bold.ttf", false},
+		{600, "/usr/share/fonts/liberation/LiberationSans-Bold.ttf", false},
+		{500, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf", false},
+		{400, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf", false},
+		{700, "/usr/share/fonts/liberation/LiberationSans-BoldItalic.ttf", true},
+		{400, "/usr/share/fonts/liberation/LiberationSans-Italic.ttf", true},
 	},
 	"playfair display": {
-		{700, "/usr/share/fonts/freefont/FreeSerifBold.ttf"},
-		{600, "/usr/share/fonts/freefont/FreeSerifBold.ttf"},
-		{500, "/usr/share/fonts/freefont/FreeSerif.ttf"},
-		{400, "/usr/share/fonts/freefont/FreeSerif.ttf"},
+		{700, "/usr/share/fonts/freefont/FreeSerifBold.ttf", false},
+		{600, "/usr/share/fonts/freefont/FreeSerifBold.ttf", false},
+		{500, "/usr/share/fonts/freefont/FreeSerif.ttf", false},
+		{400, "/usr/share/fonts/freefont/FreeSerif.ttf", false},
+		{700, "/usr/share/fonts/freefont/FreeSerifBoldItalic.ttf", true},
+		{400, "/usr/share/fonts/freefont/FreeSerifItalic.ttf", true},
 	},
 	"open sans": {
-		{700, "/usr/share/fonts/liberation/LiberationSans-Bold.ttf"},
-		{600, "/usr/share/fonts/liberation/LiberationSans-Bold.ttf"},
-		{500, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf"},
-		{400, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf"},
+		{700, "/usr/share/fonts/liberation/LiberationSans-Bold.ttf", false},
+		{600, "/usr/share/fonts/liberation/LiberationSans-Bold.ttf", false},
+		{500, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf", false},
+		{400, "/usr/share/fonts/liberation/LiberationSans-Regular.ttf", false},
+		{700, "/usr/share/fonts/liberation/LiberationSans-BoldItalic.ttf", true},
+		{400, "/usr/share/fonts/liberation/LiberationSans-Italic.ttf", true},
 	},
 	"courier new": {
-		{700, "/usr/share/fonts/liberation/LiberationMono-Bold.ttf"},
-		{400, "/usr/share/fonts/liberation/LiberationMono-Regular.ttf"},
+		{700, "/usr/share/fonts/liberation/LiberationMono-Bold.ttf", false},
+		{400, "/usr/share/fonts/liberation/LiberationMono-Regular.ttf", false},
+		{700, "/usr/share/fonts/liberation/LiberationMono-BoldItalic.ttf", true},
+		{400, "/usr/share/fonts/liberation/LiberationMono-Italic.ttf", true},
 	},
 	"monospace": {
-		{700, "/usr/share/fonts/liberation/LiberationMono-Bold.ttf"},
-		{400, "/usr/share/fonts/liberation/LiberationMono-Regular.ttf"},
+		{700, "/usr/share/fonts/liberation/LiberationMono-Bold.ttf", false},
+		{400, "/usr/share/fonts/liberation/LiberationMono-Regular.ttf", false},
+		{700, "/usr/share/fonts/liberation/LiberationMono-BoldItalic.ttf", true},
+		{400, "/usr/share/fonts/liberation/LiberationMono-Italic.ttf", true},
 	},
 }
 
 func NewProcessingService() *ProcessingService {
-	return &ProcessingService{
+	s := &ProcessingService{
 		ffmpegPath:  getEnv("FFMPEG_PATH", "ffmpeg"),
+		ffprobePath: getEnv("FFPROBE_PATH", "ffprobe"),
 		ytdlpPath:   getEnv("YTDLP_PATH", "./binaries/yt-dlp.exe"),
 		whisperPath: getEnv("WHISPER_PATH", "./binaries/whisper"),
 		storagePath: getEnv("STORAGE_PATH", "../storage"),
+		progress:    newProgressReporter(),
 	}
+	s.transcriber = newTranscriber(s.whisperPath, s.ffmpegPath)
+	if chunked, ok := s.transcriber.(*chunkedTranscriber); ok {
+		chunked.progress = s.progress.report
+	}
+	s.llm = newLLMProvider()
+	s.prompts = NewPromptLibrary(getEnv("PROMPTS_DIR", "./prompts"))
+
+	log.Printf("🎤 Transcription backend: %s", s.transcriber.Name())
+	log.Printf("🤖 LLM provider: %s", s.llm.Name())
+	return s
+}
+
+// Prompts returns the prompt template library used for analysis and SEO
+func (s *ProcessingService) Prompts() *PromptLibrary {
+	return s.prompts
+}
+
+// SetProgressNotifier registers the callback used to broadcast download, audio
+// extraction, transcription and render progress
+func (s *ProcessingService) SetProgressNotifier(notify ProgressNotifier) {
+	s.progress.setNotifier(notify)
 }
 
 // DownloadVideo downloads video from YouTube using yt-dlp
@@ -107,19 +147,25 @@ func (s *ProcessingService) DownloadVideo(url string, videoID string) (*models.V
 		return nil, fmt.Errorf("failed to get video metadata: %v, output: %s", err, metaOutput)
 	}
 
-	// Now download the video
+	// Now download the video. --newline prints one progress line per update; with
+	// separate video and audio streams the percentage restarts for the audio file.
 	downloadCmd := exec.Command(s.ytdlpPath,
 		"-f", "bestvideo[ext=mp4]+bestaudio[ext=m4a]/best[ext=mp4]/best",
 		"--merge-output-format", "mp4",
 		"--no-warnings",
-		"--no-progress",
+		"--newline",
 		"-o", outputPath,
 		url,
 	)
 
-	downloadOutput, err := downloadCmd.CombinedOutput()
+	downloadOutput, err := runStreamingLines(downloadCmd, func(line string) {
+		if update, ok := parseYtdlpProgress(line); ok {
+			update.VideoID = videoID
+			s.progress.report(update)
+		}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to download video: %v, output: %s", err, downloadOutput)
+		return nil, fmt.Errorf("failed to download video: %v, output: %s", err, lastLines(string(downloadOutput), 20))
 	}
 
 	// Parse metadata
@@ -145,669 +191,529 @@ func (s *ProcessingService) TranscribeVideo(videoPath string, videoID string) (*
 	// Extract audio first
 	audioPath := filepath.Join(s.storagePath, "transcripts", videoID+".wav")
 
-	cmd := exec.Command(s.ffmpegPath,
+	// The source duration turns ffmpeg's output time into a percentage
+	duration := 0.0
+	if info, err := s.ProbeMedia(videoPath); err == nil {
+		duration = info.Duration
+	}
+
+	output, err := s.runFFmpegWithProgress([]string{
 		"-y", // Overwrite output files
 		"-i", videoPath,
 		"-vn", "-acodec", "pcm_s16le", "-ar", "16000", "-ac", "1",
 		audioPath,
-	)
-
-	output, err := cmd.CombinedOutput()
+	}, duration, func(update ProgressUpdate) {
+		update.VideoID = videoID
+		update.Stage = StageExtractAudio
+		s.progress.report(update)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract audio: %v, output: %s", err, string(output))
+		return nil, fmt.Errorf("failed to extract audio: %v, output: %s", err, lastLines(string(output), 20))
 	}
 
 	log.Printf("🎤 Audio extracted: %s", audioPath)
 
-	// Use OpenAI Whisper API
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Printf("⚠️  OPENAI_API_KEY not set, using mock transcript")
-		return s.createMockTranscript(videoID), nil
-	}
-
-	transcript, err := s.transcribeWithWhisperAPI(audioPath, videoID)
+	// Chunked transcription reports every finished chunk in between
+	s.progress.report(ProgressUpdate{VideoID: videoID, Stage: StageTranscribe})
+	transcript, err := s.transcriber.Transcribe(audioPath, videoID)
 	if err != nil {
-		log.Printf("❌ Whisper API failed: %v, using mock transcript", err)
-		return s.createMockTranscript(videoID), nil
+		return nil, fmt.Errorf("%s transcription failed: %v", s.transcriber.Name(), err)
 	}
+	s.progress.report(ProgressUpdate{VideoID: videoID, Stage: StageTranscribe, Percent: 100})
 
-	log.Printf("✅ Transcription completed: %d segments", len(transcript.Segments))
+	log.Printf("✅ Transcription completed with %s: %d segments", s.transcriber.Name(), len(transcript.Segments))
 	return transcript, nil
 }
 
-func (s *ProcessingService) createMockTranscript(videoID string) *models.Transcript {
-	return &models.Transcript{
-		ID:       uuid.New().String(),
-		VideoID:  videoID,
-		Language: "en",
-		Segments: []models.Segment{
-			{Start: 0, End: 5, Text: "This is a sample transcript."},
-			{Start: 5, End: 10, Text: "Whisper integration needed."},
-		},
-		FullText:  "This is a sample transcript. Whisper integration needed.",
-		CreatedAt: time.Now(),
-	}
-}
-
-func (s *ProcessingService) transcribeWithWhisperAPI(audioPath string, videoID string) (*models.Transcript, error) {
-	apiURL := getEnv("OPENAI_API_URL", "https://api.openai.com/v1") + "/audio/transcriptions"
-	apiKey := os.Getenv("OPENAI_API_KEY")
+// AnalyzeTranscript asks the configured LLM provider for the most interesting moments.
+// Transcripts longer than one analysis window go through analyzeWindowed. Suggestions
+// are snapped to transcript boundaries and nearby scene cuts and validated before
+// being returned, and each one records the prompt version that produced it.
+func (s *ProcessingService) AnalyzeTranscript(transcript *models.Transcript, video *models.Video, scenes []models.Scene) ([]models.SuggestedClip, error) {
+	log.Printf("📝 Transcript length: %d characters, %d segments", len(transcript.FullText), len(transcript.Segments))
 
-	// Open audio file
-	file, err := os.Open(audioPath)
+	tmpl, vars, err := s.analysisPrompt(video)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open audio file: %v", err)
+		return nil, err
 	}
-	defer file.Close()
+	log.Printf("🧾 Using prompt %s (language: %s)", tmpl.VersionID(), vars.LanguageCode)
 
-	// Create multipart form
-	var requestBody bytes.Buffer
-	writer := multipart.NewWriter(&requestBody)
+	config := loadAnalysisConfig()
+	videoDuration := float64(video.Duration)
+	if videoDuration <= 0 {
+		videoDuration = transcriptDuration(transcript)
+	}
 
-	// Add file
-	part, err := writer.CreateFormFile("file", filepath.Base(audioPath))
-	if err != nil {
-		return nil, err
+	var clips []models.SuggestedClip
+	if transcriptDuration(transcript) > config.windowSeconds {
+		clips, err = s.analyzeWindowed(transcript, videoDuration, sceneCutTimes(scenes), config, tmpl, vars)
+	} else {
+		clips, err = s.analyzeSinglePass(transcript, tmpl, vars)
+		if err == nil {
+			clips = refineSuggestedClips(clips, transcript, videoDuration, sceneCutTimes(scenes))
+		}
 	}
-	if _, err := io.Copy(part, file); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
-	// Add model
-	if err := writer.WriteField("model", "whisper-1"); err != nil {
-		return nil, err
+	if len(clips) == 0 {
+		return nil, fmt.Errorf("%s suggested no valid clips", s.llm.Name())
 	}
 
-	// Add response format with timestamps
-	if err := writer.WriteField("response_format", "verbose_json"); err != nil {
-		return nil, err
+	// Set video ID for all clips
+	for i := range clips {
+		clips[i].VideoID = video.ID
+		clips[i].ID = uuid.New().String()
+		clips[i].PromptVersion = tmpl.VersionID()
 	}
 
-	// Close the writer
-	contentType := writer.FormDataContentType()
-	if err := writer.Close(); err != nil {
-		return nil, err
-	}
+	return clips, nil
+}
 
-	// Make request
-	req, err := http.NewRequest("POST", apiURL, &requestBody)
+func (s *ProcessingService) analyzeSinglePass(transcript *models.Transcript, tmpl *PromptTemplate, vars PromptVars) ([]models.SuggestedClip, error) {
+	vars.Transcript = formatTimestampedSegments(transcript.Segments)
+	request, err := tmpl.Render(vars)
 	if err != nil {
 		return nil, err
 	}
+	request.Temperature = 0.7
+	request.MaxTokens = 4000
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", contentType)
-
-	log.Printf("🎤 Calling Whisper API...")
-	client := &http.Client{Timeout: 120 * time.Second}
-	resp, err := client.Do(req)
+	content, err := completeWithRetry(s.llm, request)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %v", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("Whisper API returned status %d: %s", resp.StatusCode, string(respBody))
+		return nil, err
 	}
 
-	log.Printf("✅ Whisper API response received")
-
-	// Parse response
-	var whisperResp struct {
-		Text     string `json:"text"`
-		Language string `json:"language"`
-		Segments []struct {
-			Start float64 `json:"start"`
-			End   float64 `json:"end"`
-			Text  string  `json:"text"`
-		} `json:"segments"`
-	}
+	return parseSuggestedClips(s.llm.Name(), content)
+}
 
-	if err := json.Unmarshal(respBody, &whisperResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
-	}
+// CreateClip creates a video clip with subtitles using FFmpeg. The transcript (may be
+// nil) locates filler words for jump cuts.
+func (s *ProcessingService) CreateClip(video *models.Video, clip *models.Clip, transcript *models.Transcript, brand *models.BrandKit, music *models.MusicTrack) error {
+	outputPath := filepath.Join(s.storagePath, "clips", clip.ID+".mp4")
 
-	// Convert to our format
-	segments := make([]models.Segment, len(whisperResp.Segments))
-	for i, seg := range whisperResp.Segments {
-		segments[i] = models.Segment{
-			Start: seg.Start,
-			End:   seg.End,
-			Text:  strings.TrimSpace(seg.Text),
+	// Ensure we have absolute paths
+	inputPath := video.FilePath
+	if !filepath.IsAbs(inputPath) {
+		var err error
+		inputPath, err = filepath.Abs(inputPath)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %v", err)
 		}
 	}
 
-	transcript := &models.Transcript{
-		ID:        uuid.New().String(),
-		VideoID:   videoID,
-		Language:  whisperResp.Language,
-		Segments:  segments,
-		FullText:  whisperResp.Text,
-		CreatedAt: time.Now(),
+	// Verify input file exists
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		return fmt.Errorf("input video file does not exist: %s", inputPath)
 	}
 
-	return transcript, nil
-}
-
-// AnalyzeTranscript uses DeepSeek to find interesting moments
-func (s *ProcessingService) AnalyzeTranscript(transcript *models.Transcript, videoID string) ([]models.SuggestedClip, error) {
-	useOllama := getEnv("USE_OLLAMA", "false") == "true"
+	log.Printf("📹 Creating clip from: %s", inputPath)
+	log.Printf("💾 Output path: %s", outputPath)
+	ranges := SourceRanges(clip.StartTime, clip.EndTime, clip.Segments)
+	for i, r := range ranges {
+		log.Printf("⏱️  Part %d: %.2f - %.2f (duration: %.2f)", i+1, r.StartTime, r.EndTime, r.EndTime-r.StartTime)
+	}
+	log.Printf("📝 Subtitles: %d (%s renderer)", len(clip.Subtitles), s.subtitleRenderer(clip))
 
-	var clips []models.SuggestedClip
-	var err error
+	profile := newOutputProfile(clip.RenderOptions)
+	log.Printf("📐 Output: %s (%s)", profile, profile.framing)
 
-	if useOllama {
-		clips, err = s.analyzeWithOllama(transcript)
+	// Fitted framings place subtitles over the visible picture and reframing crops
+	// around faces, both depend on the source size. Sources without audio get no
+	// audio graph.
+	sourceWidth, sourceHeight, hasAudio := 0, 0, true
+	if info, err := s.ProbeMedia(inputPath); err == nil {
+		sourceWidth, sourceHeight, hasAudio = info.Width, info.Height, info.HasAudio
 	} else {
-		clips, err = s.analyzeWithDeepSeekAPI(transcript)
-	}
+		log.Printf("⚠️  Could not probe source, using the full frame: %v", err)
+	}
+	layout := profile.subtitleLayout(sourceWidth, sourceHeight)
+	if brand != nil {
+		// Brand kits keep subtitles clear of the platform interface
+		log.Printf("🏷️  Brand kit: %s", brand.Name)
+		layout = layout.withinSafeZone(brand.SafeZone)
+	}
+
+	// Each source range becomes a part with its own reframing and edit timeline.
+	// Removed silence and filler words are closed up, so subtitles move from clip
+	// time (the ranges back to back) to the export timeline.
+	subtitles := clip.Subtitles
+	if clip.JumpCut {
+		subtitles = removeFillerText(subtitles, fillerPhrases())
+	}
+	parts := clipParts{}
+	offset := 0.0
+	for _, r := range ranges {
+		duration := r.EndTime - r.StartTime
+		part := clipPart{start: r.StartTime}
+
+		if clip.Reframe && profile.cropsWidth(sourceWidth, sourceHeight) {
+			center, err := s.reframeCenter(video.ID, clip.ID, inputPath, r.StartTime, duration, sourceWidth, sourceHeight)
+			if err != nil {
+				log.Printf("⚠️  Reframing failed, using a center crop: %v", err)
+			}
+			part.cropCenter = center
+		}
 
-	if err != nil {
-		return nil, err
+		words := []models.Word{}
+		if clip.JumpCut {
+			words = clipWords(transcript, r.StartTime, r.EndTime, subtitleWords(clip.Subtitles, offset, duration))
+		}
+		part.timeline = s.editTimeline(video.ID, clip.ID, inputPath, r.StartTime, duration, clip.RenderOptions, words)
+
+		parts = append(parts, part)
+		offset += duration
+	}
+	subtitles = parts.remapSubtitles(subtitles)
+
+	// Build FFmpeg command with subtitles. Every part is its own input, seeked to
+	// its range; the filter graph trims, frames and joins them.
+	args := append([]string{"-y"}, parts.inputArgs(inputPath)...) // Overwrite output files
+
+	// Fit the source into the output frame, then burn the subtitles on top. Frames
+	// are selected on part time (the reframing crop follows it too) and retimed
+	// before the subtitles, which are on the export timeline.
+	graph := parts.videoGraph(profile)
+	videoOut := "[vc]"
+	nextInput := len(parts)
+	if brand != nil && brand.LogoPath != "" {
+		// The logo goes under the subtitles, which are laid out clear of it
+		args = append(args, "-i", brand.LogoPath)
+		graph += ";" + brandLogoGraph(brand, profile, nextInput, videoOut, "[vlogo]")
+		videoOut = "[vlogo]"
+		nextInput++
+	}
+	if len(subtitles) > 0 {
+		assPath := filepath.Join(s.storagePath, "clips", clip.ID+".ass")
+		subtitlesFilter, err := s.subtitlesFilter(clip, subtitles, layout, assPath)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(assPath)
+		graph += ";" + videoOut + subtitlesFilter + "[vout]"
+		videoOut = "[vout]"
 	}
 
-	// Set video ID for all clips
-	for i := range clips {
-		clips[i].VideoID = videoID
-		clips[i].ID = uuid.New().String()
+	// The headline and progress bar go on top and span the export timeline
+	if overlays := s.overlayGraph(clip.RenderOptions, layout, parts.outputDuration(), videoOut, "[voverlay]"); overlays != "" {
+		graph += ";" + overlays
+		videoOut = "[voverlay]"
 	}
 
-	return clips, nil
-}
-
-func (s *ProcessingService) analyzeWithDeepSeekAPI(transcript *models.Transcript) ([]models.SuggestedClip, error) {
-	apiKey := os.Getenv("DEEPSEEK_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("DEEPSEEK_API_KEY not set")
-	}
-
-	apiURL := getEnv("DEEPSEEK_API_URL", "https://api.deepseek.com") + "/chat/completions"
-
-	log.Printf("🤖 Calling DeepSeek API at %s", apiURL)
-	log.Printf("📝 Transcript length: %d characters", len(transcript.FullText))
-
-	prompt := fmt.Sprintf(`Eres un editor profesional de video que crea clips virales para TikTok, YouTube Shorts e Instagram Reels.
-
-Analiza esta transcripción completa del video e identifica los 5-8 mejores momentos para crear clips cortos que tengan SENTIDO COMPLETO.
-
-TRANSCRIPCIÓN DEL VIDEO:
-%s
-
-CRITERIOS FUNDAMENTALES PARA CADA CLIP:
-
-1. COHERENCIA NARRATIVA (Prioridad máxima):
-   - El clip DEBE tener inicio, desarrollo y cierre completo
-   - NO cortar ideas a la mitad
-   - NO terminar con frases incompletas
-   - La historia o concepto debe ser autocontenido y comprensible
-
-2. DURACIÓN INTELIGENTE:
-   - Mínimo: 15 segundos (solo si la idea es muy concisa y potente)
-   - Ideal: 30-45 segundos (suficiente para desarrollar la idea)
-   - Máximo: 60 segundos (cuando sea necesario para completar el concepto)
-   - PRIORIZAR la coherencia sobre la brevedad
-
-3. PUNTOS DE INICIO Y FIN ESTRATÉGICOS:
-   - Inicio: Buscar el comienzo natural de una idea, historia o concepto
-   - Fin: Terminar cuando la idea esté completamente expresada
-   - Evitar inicios abruptos o finales cortados
-   - Respetar pausas naturales del discurso
-
-4. CONTENIDO DE ALTO VALOR:
-   - Momentos que enseñan algo específico y útil
-   - Historias completas con setup y punchline
-   - Revelaciones o insights completos
-   - Momentos emotivos con contexto suficiente
-   - Tips o consejos con explicación completa
-
-5. POTENCIAL VIRAL:
-   - Contenido que genera curiosidad desde el primer segundo
-   - Información sorprendente o contra-intuitiva
-   - Historias con giro o revelación
-   - Contenido que invita a compartir
-   - Temas trending o de interés actual
-
-FORMATO DE RESPUESTA (JSON):
-Devuelve un array de 5-8 clips con esta estructura exacta:
-
-[
-  {
-    "start_time": 10.5,
-    "end_time": 45.2,
-    "title": "Título atractivo que refleja el contenido completo",
-    "description": "Descripción detallada de lo que cubre el clip de inicio a fin",
-    "score": 85,
-    "reason": "Explicación específica de por qué este clip funciona: qué lo hace interesante, por qué tiene sentido completo, y su potencial viral"
-  }
-]
-
-IMPORTANTE:
-- Todos los textos en ESPAÑOL
-- Los timestamps deben ser precisos (decimales permitidos)
-- Verifica que cada clip tenga una narrativa completa
-- Si un concepto necesita 50-60 segundos para completarse, úsalos
-- Mejor un clip de 60 segundos coherente que uno de 30 segundos cortado
-- Ordena los clips del más viral (score más alto) al menos viral
-
-Responde ÚNICAMENTE con el array JSON, sin texto adicional.`, transcript.FullText)
-
-	requestBody := map[string]interface{}{
-		"model": "deepseek-chat",
-		"messages": []map[string]string{
-			{"role": "system", "content": "Eres un editor profesional de video con 10+ años de experiencia creando contenido viral. Tu especialidad es identificar momentos completos y coherentes que funcionan como clips independientes. SIEMPRE priorizas que el contenido tenga sentido completo sobre la brevedad. Respondes únicamente en ESPAÑOL con JSON válido."},
-			{"role": "user", "content": prompt},
-		},
-		"temperature": 0.7,
-		"max_tokens":  4000,
-		"stream":      false,
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	// The music bed goes under the speech and ends with the clip
+	musicInput := nextInput
+	if music != nil {
+		log.Printf("🎵 Music: %s from %.2fs at %.0f%%", music.Name, clip.MusicStart, clip.MusicVolume*100)
+		args = append(args, musicInputArgs(music, clip.MusicStart)...)
+		nextInput++
 	}
-
-	log.Printf("🚀 Sending request to DeepSeek (prompt length: %d chars)", len(prompt))
-
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+	clipAudio := func(musicInput int) (audioGraph, audioOut string) {
+		if hasAudio {
+			audioGraph, audioOut = parts.audioGraph(), "[ac]"
+		}
+		if music != nil {
+			if audioGraph != "" {
+				audioGraph += ";"
+			}
+			audioGraph += musicBedGraph(musicInput, audioOut, clip.MusicVolume, parts.outputDuration())
+			audioOut = "[amusic]"
+		}
+		return audioGraph, audioOut
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	audioGraph, audioOut := clipAudio(musicInput)
+	if target, ok := loudnessTargets[clip.Loudness]; ok && hasAudio {
+		// The target applies to what is heard, speech and music together. The
+		// measuring pass only opens the parts and the music, so the music is the
+		// input after the parts there.
+		measureArgs := parts.inputArgs(inputPath)
+		if music != nil {
+			measureArgs = append(measureArgs, musicInputArgs(music, clip.MusicStart)...)
+		}
+		measureGraph, measureOut := clipAudio(len(parts))
 
-	client := &http.Client{Timeout: 120 * time.Second} // Increased timeout for long transcripts
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %v", err)
+		// loudnorm works at 192 kHz, resample back for AAC
+		loudnorm := s.loudnormFilter(video.ID, clip.ID, measureArgs, measureGraph, measureOut, parts.outputDuration(), target)
+		audioGraph += ";" + audioOut + loudnorm + ",aresample=48000[aout]"
+		audioOut = "[aout]"
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
+	if audioGraph != "" {
+		graph += ";" + audioGraph
 	}
 
-	log.Printf("✅ DeepSeek API response status: %d", resp.StatusCode)
-
-	// Validate response body
-	if len(body) == 0 {
-		return nil, fmt.Errorf("DeepSeek API returned empty response body")
+	// Intro and outro cards play around the clip; loudness targets the clip audio only
+	duration := parts.outputDuration()
+	if brand != nil {
+		cards, err := s.newBrandCards(brand, profile, nextInput, audioOut != "")
+		if err != nil {
+			return err
+		}
+		if len(cards) > 0 {
+			args = append(args, cards.inputArgs()...)
+			var cardsGraph string
+			cardsGraph, videoOut, audioOut = cards.join(videoOut, audioOut)
+			graph += ";" + cardsGraph
+			duration += cards.duration()
+		}
 	}
 
-	log.Printf("📦 DeepSeek response body length: %d bytes", len(body))
-	log.Printf("📦 Response body preview (first 200 chars): %s", string(body)[:min(200, len(body))])
-
-	// Log the response for debugging
-	if resp.StatusCode != 200 {
-		log.Printf("❌ DeepSeek API error response: %s", string(body))
-		return nil, fmt.Errorf("DeepSeek API returned status %d: %s", resp.StatusCode, string(body))
+	args = append(args, "-filter_complex", graph, "-map", videoOut)
+	if audioOut != "" {
+		args = append(args, "-map", audioOut)
 	}
 
-	var response struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Error *struct {
-			Message string `json:"message"`
-			Type    string `json:"type"`
-		} `json:"error"`
-	}
+	// Output settings - maintain quality at the profile resolution
+	args = append(args,
+		"-c:v", "libx264", // Video codec
+		"-preset", "medium", // Better quality than fast
+		"-crf", "18", // High quality (lower = better, 18 is visually lossless)
+		"-pix_fmt", "yuv420p", // Pixel format for compatibility
+		"-c:a", "aac", // Audio codec
+		"-b:a", "192k", // Higher audio bitrate
+		"-movflags", "+faststart", // Enable streaming
+		outputPath,
+	)
 
-	if err := json.Unmarshal(body, &response); err != nil {
-		log.Printf("❌ JSON Unmarshal Error: %v", err)
-		log.Printf("❌ Full response body: %s", string(body))
-		return nil, fmt.Errorf("failed to parse response: %v, body: %s", err, string(body))
-	}
+	log.Printf("🎬 FFmpeg command: %s %v", s.ffmpegPath, args)
 
-	if response.Error != nil {
-		return nil, fmt.Errorf("DeepSeek API error: %s (%s)", response.Error.Message, response.Error.Type)
+	if removed := parts.removedSeconds(); removed > 0 {
+		log.Printf("✂️  Export is %.2fs, %.2fs removed", parts.outputDuration(), removed)
 	}
 
-	if len(response.Choices) == 0 {
-		return nil, fmt.Errorf("no response from DeepSeek, body: %s", string(body))
+	output, err := s.runFFmpegWithProgress(args, duration, func(update ProgressUpdate) {
+		update.VideoID = video.ID
+		update.ClipID = clip.ID
+		update.Stage = StageRender
+		s.progress.report(update)
+	})
+	if err != nil {
+		log.Printf("❌ FFmpeg error: %s", string(output))
+		return fmt.Errorf("failed to create clip: %v, output: %s", err, lastLines(string(output), 20))
 	}
 
-	// Get the content from DeepSeek response
-	content := response.Choices[0].Message.Content
+	log.Printf("✅ Clip created successfully: %s", outputPath)
 
-	if content == "" {
-		return nil, fmt.Errorf("empty content from DeepSeek, full response: %s", string(body))
+	// The preview is encoded from the same parts, scaled down, once the MP4 is done.
+	// A re-render drops the previous one, which may be in another format.
+	if clip.PreviewPath != "" {
+		if err := os.Remove(clip.PreviewPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️  Failed to remove previous preview %s: %v", clip.PreviewPath, err)
+		}
+		clip.PreviewPath = ""
 	}
-
-	log.Printf("� Raw DeepSeek content length: %d characters", len(content))
-	log.Printf("🎬 Content preview (first 500 chars): %s", content[:min(500, len(content))])
-
-	// Extract JSON from markdown code blocks if present
-	content = strings.TrimSpace(content)
-	if strings.Contains(content, "```json") {
-		start := strings.Index(content, "```json") + 7
-		end := strings.LastIndex(content, "```")
-		if end > start {
-			content = content[start:end]
-			content = strings.TrimSpace(content)
+	if clip.Preview != nil {
+		preview := previewProfile(profile, clip.Preview.Width)
+		previewLayout := preview.subtitleLayout(sourceWidth, sourceHeight)
+		if brand != nil {
+			previewLayout = previewLayout.withinSafeZone(brand.SafeZone)
 		}
-	} else if strings.Contains(content, "```") {
-		// Handle generic code blocks
-		start := strings.Index(content, "```") + 3
-		end := strings.LastIndex(content, "```")
-		if end > start {
-			content = content[start:end]
-			content = strings.TrimSpace(content)
+		// The MP4 is good without it, so a failed preview only leaves it out
+		if previewPath, err := s.renderPreview(video.ID, clip, inputPath, parts, preview, previewLayout, subtitles); err != nil {
+			log.Printf("⚠️  [%s] Preview failed, clip completed without it: %v", clip.ID, err)
+		} else {
+			clip.PreviewPath = previewPath
 		}
 	}
 
-	log.Printf("🔍 Cleaned content for parsing (first 300 chars): %s", content[:min(300, len(content))])
-
-	// Parse the JSON response
-	var clips []models.SuggestedClip
-	if err := json.Unmarshal([]byte(content), &clips); err != nil {
-		log.Printf("❌ JSON Parse Error: %v", err)
-		log.Printf("❌ Content that failed to parse: %s", content)
-		return nil, fmt.Errorf("failed to parse DeepSeek clips response: %v", err)
-	}
+	clip.FilePath = outputPath
+	clip.Status = ClipStatusCompleted
+	now := time.Now()
+	clip.CompletedAt = &now
 
-	log.Printf("✅ Successfully parsed %d clips from DeepSeek", len(clips))
+	return nil
+}
 
-	if len(clips) == 0 {
-		return nil, fmt.Errorf("DeepSeek returned empty clips array")
-	}
+// Layout of SubtitleCanvas.svelte, in editor canvas pixels. Both subtitle renderers
+// scale it to the output frame through a subtitleLayout.
+const (
+	canvasHeight     = 720.0
+	bottomMargin     = 40.0
+	paddingPx        = 12.0
+	textShadowOffset = 1.0
+	bgShadowOffset   = 4.0
+	minFontSize      = 13.5 // 36px on a 1920px tall output
+)
 
-	return clips, nil
+// subtitleLayout maps the editor canvas onto an output frame. The canvas is
+// canvasHeight pixels tall whatever the aspect ratio, so it scales with the height.
+// Positions are relative to area, the visible picture inside the frame.
+type subtitleLayout struct {
+	width  int
+	height int
+	scale  float64 // canvas -> output pixels (2.666... for 1080x1920)
+	area   subtitleArea
 }
 
-func (s *ProcessingService) analyzeWithOllama(transcript *models.Transcript) ([]models.SuggestedClip, error) {
-	ollamaURL := getEnv("OLLAMA_URL", "http://localhost:11434")
-	model := getEnv("OLLAMA_MODEL", "deepseek-r1:latest")
-
-	prompt := fmt.Sprintf(`Eres un editor profesional de video que crea clips virales para TikTok, YouTube Shorts e Instagram Reels.
-
-Analiza esta transcripción completa del video e identifica los 5-8 mejores momentos para crear clips cortos que tengan SENTIDO COMPLETO.
-
-TRANSCRIPCIÓN DEL VIDEO:
-%s
-
-CRITERIOS FUNDAMENTALES PARA CADA CLIP:
-
-1. COHERENCIA NARRATIVA (Prioridad máxima):
-   - El clip DEBE tener inicio, desarrollo y cierre completo
-   - NO cortar ideas a la mitad
-   - NO terminar con frases incompletas
-   - La historia o concepto debe ser autocontenido y comprensible
-
-2. DURACIÓN INTELIGENTE:
-   - Mínimo: 15 segundos (solo si la idea es muy concisa y potente)
-   - Ideal: 30-45 segundos (suficiente para desarrollar la idea)
-   - Máximo: 60 segundos (cuando sea necesario para completar el concepto)
-   - PRIORIZAR la coherencia sobre la brevedad
-
-3. PUNTOS DE INICIO Y FIN ESTRATÉGICOS:
-   - Inicio: Buscar el comienzo natural de una idea, historia o concepto
-   - Fin: Terminar cuando la idea esté completamente expresada
-   - Evitar inicios abruptos o finales cortados
-   - Respetar pausas naturales del discurso
-
-4. CONTENIDO DE ALTO VALOR:
-   - Momentos que enseñan algo específico y útil
-   - Historias completas con setup y punchline
-   - Revelaciones o insights completos
-   - Momentos emotivos con contexto suficiente
-   - Tips o consejos con explicación completa
-
-5. POTENCIAL VIRAL:
-   - Contenido que genera curiosidad desde el primer segundo
-   - Información sorprendente o contra-intuitiva
-   - Historias con giro o revelación
-   - Contenido que invita a compartir
-   - Temas trending o de interés actual
-
-FORMATO DE RESPUESTA (JSON):
-Devuelve un array de 5-8 clips con esta estructura exacta:
-
-[
-  {
-    "start_time": 10.5,
-    "end_time": 45.2,
-    "title": "Título atractivo que refleja el contenido completo",
-    "description": "Descripción detallada de lo que cubre el clip de inicio a fin",
-    "score": 85,
-    "reason": "Explicación específica de por qué este clip funciona: qué lo hace interesante, por qué tiene sentido completo, y su potencial viral"
-  }
-]
-
-IMPORTANTE:
-- Todos los textos en ESPAÑOL
-- Los timestamps deben ser precisos (decimales permitidos)
-- Verifica que cada clip tenga una narrativa completa
-- Si un concepto necesita 50-60 segundos para completarse, úsalos
-- Mejor un clip de 60 segundos coherente que uno de 30 segundos cortado
-- Ordena los clips del más viral (score más alto) al menos viral
-
-Responde ÚNICAMENTE con el array JSON, sin otro texto.`, transcript.FullText)
-
-	requestBody := map[string]interface{}{
-		"model":  model,
-		"prompt": prompt,
-		"stream": false,
-		"system": "Eres un editor profesional de video con 10+ años de experiencia creando contenido viral. Tu especialidad es identificar momentos completos y coherentes que funcionan como clips independientes. SIEMPRE priorizas que el contenido tenga sentido completo sobre la brevedad. Respondes únicamente en ESPAÑOL con JSON válido.",
-	}
-
-	jsonData, _ := json.Marshal(requestBody)
-	resp, err := http.Post(ollamaURL+"/api/generate", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
+// subtitleArea is a rectangle of the output frame, in output pixels
+type subtitleArea struct {
+	x, y, width, height float64
+}
 
-	var response struct {
-		Response string `json:"response"`
+func newSubtitleLayout(width, height int) subtitleLayout {
+	return subtitleLayout{
+		width:  width,
+		height: height,
+		scale:  float64(height) / canvasHeight,
+		area:   subtitleArea{width: float64(width), height: float64(height)},
 	}
+}
 
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse Ollama response body: %v", err)
+// fontSize scales the editor font size to the output, with a readable minimum
+func (l subtitleLayout) fontSize(sub models.SubtitleConfig) int {
+	fontSize := sub.FontSize
+	if fontSize <= 0 {
+		fontSize = 20
 	}
+	return int(math.Round(math.Max(float64(fontSize), minFontSize) * l.scale))
+}
 
-	content := response.Response
-
-	if content == "" {
-		return nil, fmt.Errorf("empty content from Ollama, full response: %s", string(body))
+// subtitleBgOpacity is the background box opacity, 0.8 when no background was configured
+func subtitleBgOpacity(sub models.SubtitleConfig) float64 {
+	if sub.BgOpacity == 0 && sub.BgColor == "" {
+		return 0.8
 	}
+	return clampFloat(sub.BgOpacity, 0, 1)
+}
 
-	log.Printf("📄 Raw Ollama content length: %d characters", len(content))
-	log.Printf("🎬 Content preview (first 500 chars): %s", content[:min(500, len(content))])
+// boxBorder is the padding around the text inside the background box
+func (l subtitleLayout) boxBorder(sub models.SubtitleConfig) int {
+	radiusAdjustment := math.Max(0, float64(sub.BorderRadius)*0.3)
+	return int(math.Round((paddingPx + radiusAdjustment) * l.scale))
+}
 
-	// Extract JSON from markdown code blocks if present
-	content = strings.TrimSpace(content)
-	if strings.Contains(content, "```json") {
-		start := strings.Index(content, "```json") + 7
-		end := strings.LastIndex(content, "```")
-		if end > start {
-			content = content[start:end]
-			content = strings.TrimSpace(content)
-		}
-	} else if strings.Contains(content, "```") {
-		// Handle generic code blocks
-		start := strings.Index(content, "```") + 3
-		end := strings.LastIndex(content, "```")
-		if end > start {
-			content = content[start:end]
-			content = strings.TrimSpace(content)
-		}
+// targetY is the vertical center of a subtitle in output pixels
+func (l subtitleLayout) targetY(position string) float64 {
+	switch strings.ToLower(position) {
+	case "top":
+		return l.area.y + bottomMargin*l.scale
+	case "center":
+		return l.area.y + l.area.height/2
+	default:
+		return l.area.y + l.area.height - bottomMargin*l.scale
 	}
+}
 
-	log.Printf("🔍 Cleaned content for parsing (first 300 chars): %s", content[:min(300, len(content))])
-
-	// Parse the JSON response
-	var clips []models.SuggestedClip
-	if err := json.Unmarshal([]byte(content), &clips); err != nil {
-		log.Printf("❌ JSON Parse Error: %v", err)
-		log.Printf("❌ Content that failed to parse: %s", content)
-		return nil, fmt.Errorf("failed to parse Ollama clips response: %v", err)
+// subtitleRenderer is the renderer chosen for the clip, or SUBTITLE_RENDERER
+func (s *ProcessingService) subtitleRenderer(clip *models.Clip) string {
+	if clip.SubtitleRenderer != "" {
+		return clip.SubtitleRenderer
 	}
+	return getEnv("SUBTITLE_RENDERER", models.SubtitleRendererDrawtext)
+}
 
-	log.Printf("✅ Successfully parsed %d clips from Ollama", len(clips))
-
-	if len(clips) == 0 {
-		return nil, fmt.Errorf("Ollama returned empty clips array")
+// subtitlesFilter burns the subtitles in with the clip's renderer. The ASS renderer
+// writes its script to assPath, which the caller removes once ffmpeg is done.
+func (s *ProcessingService) subtitlesFilter(clip *models.Clip, subtitles []models.SubtitleConfig, layout subtitleLayout, assPath string) (string, error) {
+	if s.subtitleRenderer(clip) == models.SubtitleRendererASS {
+		return s.writeASSSubtitles(subtitles, layout, assPath)
 	}
-
-	return clips, nil
+	return s.buildSubtitlesFilter(subtitles, layout), nil
 }
 
-// CreateClip creates a video clip with subtitles using FFmpeg
-func (s *ProcessingService) CreateClip(video *models.Video, clip *models.Clip) error {
-	outputPath := filepath.Join(s.storagePath, "clips", clip.ID+".mp4")
-
-	// Ensure we have absolute paths
-	inputPath := video.FilePath
-	if !filepath.IsAbs(inputPath) {
-		var err error
-		inputPath, err = filepath.Abs(inputPath)
+// RenderSubtitleFrame burns subtitles with the renderer and output profile of the
+// render options onto a plain background and saves the frame at second `at` as an
+// image, so renderers can be compared against the editor frame by frame
+func (s *ProcessingService) RenderSubtitleFrame(subtitles []models.SubtitleConfig, options models.RenderOptions, at float64, outputPath string) error {
+	profile := newOutputProfile(options)
+
+	subtitlesFilter := ""
+	switch options.SubtitleRenderer {
+	case models.SubtitleRendererASS:
+		assPath := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".ass"
+		filter, err := s.writeASSSubtitles(subtitles, profile.subtitleLayout(0, 0), assPath)
 		if err != nil {
-			return fmt.Errorf("failed to get absolute path: %v", err)
+			return err
 		}
+		defer os.Remove(assPath)
+		subtitlesFilter = filter
+	default:
+		subtitlesFilter = s.buildSubtitlesFilter(subtitles, profile.subtitleLayout(0, 0))
 	}
-
-	// Verify input file exists
-	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
-		return fmt.Errorf("input video file does not exist: %s", inputPath)
-	}
-
-	log.Printf("📹 Creating clip from: %s", inputPath)
-	log.Printf("💾 Output path: %s", outputPath)
-	log.Printf("⏱️  Time: %.2f - %.2f (duration: %.2f)", clip.StartTime, clip.EndTime, clip.EndTime-clip.StartTime)
-	log.Printf("📝 Subtitles: %d", len(clip.Subtitles))
-
-	// Build FFmpeg command with subtitles
-	args := []string{
-		"-y",                                       // Overwrite output files
-		"-ss", fmt.Sprintf("%.2f", clip.StartTime), // Start time
-		"-i", inputPath, // Input file
-		"-t", fmt.Sprintf("%.2f", clip.EndTime-clip.StartTime), // Duration
-	}
-
-	// Add subtitles filter if present
-	if len(clip.Subtitles) > 0 {
-		subtitlesFilter := s.buildSubtitlesFilter(clip.Subtitles)
-		// Scale to 1080x1920 maintaining aspect ratio, then crop center if needed
-		// First scale the height to 1920, then crop width to 1080 if wider
-		filterComplex := fmt.Sprintf("scale=-1:1920,crop=min(iw\\,1080):1920,%s", subtitlesFilter)
-		args = append(args, "-vf", filterComplex)
-	} else {
-		// Even without subtitles, scale and crop to 1080x1920
-		args = append(args, "-vf", "scale=-1:1920,crop=min(iw\\,1080):1920")
+	if subtitlesFilter == "" {
+		subtitlesFilter = "null"
 	}
 
-	// Output settings - maintain quality at 1080x1920 (vertical)
-	args = append(args,
-		"-s", "1080x1920", // Force output resolution (vertical format)
-		"-c:v", "libx264", // Video codec
-		"-preset", "medium", // Better quality than fast
-		"-crf", "18", // High quality (lower = better, 18 is visually lossless)
-		"-pix_fmt", "yuv420p", // Pixel format for compatibility
-		"-c:a", "aac", // Audio codec
-		"-b:a", "192k", // Higher audio bitrate
-		"-movflags", "+faststart", // Enable streaming
+	cmd := exec.Command(s.ffmpegPath,
+		"-y",
+		"-f", "lavfi",
+		"-i", fmt.Sprintf("color=c=0x3A4150:s=%s:r=30:d=%.3f", profile, at+0.5),
+		"-vf", subtitlesFilter,
+		"-ss", fmt.Sprintf("%.3f", at),
+		"-frames:v", "1",
 		outputPath,
 	)
-
-	log.Printf("🎬 FFmpeg command: %s %v", s.ffmpegPath, args)
-
-	cmd := exec.Command(s.ffmpegPath, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Printf("❌ FFmpeg error: %s", string(output))
-		return fmt.Errorf("failed to create clip: %v, output: %s", err, output)
+		return fmt.Errorf("ffmpeg error: %v, output: %s", err, lastLines(string(output), 20))
 	}
 
-	log.Printf("✅ Clip created successfully: %s", outputPath)
-
-	clip.FilePath = outputPath
-	clip.Status = "completed"
-	now := time.Now()
-	clip.CompletedAt = &now
-
 	return nil
 }
 
-func (s *ProcessingService) buildSubtitlesFilter(subtitles []models.SubtitleConfig) string {
+func (s *ProcessingService) buildSubtitlesFilter(subtitles []models.SubtitleConfig, layout subtitleLayout) string {
 	// Build subtitle filters that mimic SubtitleCanvas.svelte styling as close as FFmpeg allows
 	filters := []string{}
 
-	const (
-		canvasHeight     = 720.0
-		bottomMargin     = 40.0
-		paddingPx        = 12.0
-		textShadowOffset = 1.0
-		bgShadowOffset   = 4.0
-	)
-
-	scaleFactor := 1920.0 / canvasHeight // Canvas -> video scaling factor (2.666...)
+	scaleFactor := layout.scale
 
 	for _, sub := range subtitles {
 		if strings.TrimSpace(sub.Text) == "" {
 			continue
 		}
 
+		// Word timings are only usable when they line up one-to-one with the displayed words
+		lineWords := strings.Fields(sub.Text)
+		wordTimed := len(sub.Words) > 0 && len(sub.Words) == len(lineWords)
+
 		// Escape special characters for drawtext
-		text := strings.ReplaceAll(sub.Text, "'", "'\\\\\\''")
-		text = strings.ReplaceAll(text, ":", "\\:")
+		text := escapeDrawtext(sub.Text)
+		if wordTimed {
+			text = escapeDrawtext(strings.Join(lineWords, " "))
+		}
 
 		// Font sizing (respect minimum for readability)
-		fontSize := sub.FontSize
-		if fontSize <= 0 {
-			fontSize = 20
-		}
-		scaledFontSize := int(math.Round(float64(fontSize) * scaleFactor))
-		if scaledFontSize < 36 {
-			scaledFontSize = 36
-		}
+		scaledFontSize := layout.fontSize(sub)
 
-		// Resolve font path matching requested family/weight
-		fontPath := resolveFontPath(sub.FontFamily, sub.FontWeight, sub.Bold)
+		// Resolve font path matching requested family/weight/style
+		fontPath := resolveFontPath(sub.FontFamily, sub.FontWeight, sub.Bold, sub.Italic)
 
 		// Colors
 		textColor := s.parseColorToFFmpeg(sub.Color, "#FFFFFF")
-		bgOpacity := sub.BgOpacity
-		if bgOpacity < 0 {
-			bgOpacity = 0
-		}
-		if bgOpacity > 1 {
-			bgOpacity = 1
-		}
-		if sub.BgOpacity == 0 && sub.BgColor == "" {
-			bgOpacity = 0.8
-		}
+		bgOpacity := subtitleBgOpacity(sub)
 		bgColorHex := s.parseColorWithAlpha(sub.BgColor, bgOpacity)
 
 		// Box padding (scale 12px from canvas and add subtle adjustment for radius)
-		radiusAdjustment := float64(sub.BorderRadius) * 0.3
-		boxBorder := int(math.Round((paddingPx + radiusAdjustment) * scaleFactor))
-		if boxBorder < int(math.Round(paddingPx*scaleFactor)) {
-			boxBorder = int(math.Round(paddingPx * scaleFactor))
-		}
+		boxBorder := layout.boxBorder(sub)
 
 		// Positioning: match canvas middle-aligned baseline
-		var targetY float64
-		switch strings.ToLower(sub.Position) {
-		case "top":
-			targetY = bottomMargin
-		case "center":
-			targetY = canvasHeight / 2
-		default:
-			targetY = canvasHeight - bottomMargin
-		}
-		targetY *= scaleFactor
+		targetY := layout.targetY(sub.Position)
 		yExpr := fmt.Sprintf("(%.2f)-text_h/2", targetY)
 		xExpr := "(w-text_w)/2"
+
+		// Entrance transition: every layer fades, scales and moves together
+		anim := drawtextTransition(sub.Transition, sub.StartTime, sub.EndTime, layout.scale)
+		scaleExpr := "1"
+		fontSizeOpt := fmt.Sprintf("%d", scaledFontSize)
+		if anim.scale != "" {
+			scaleExpr = anim.scale
+			fontSizeOpt = filterExpr(fmt.Sprintf("%d*%s", scaledFontSize, anim.scale))
+		}
+		alphaOpt := ""
+		if anim.alpha != "" {
+			alphaOpt = ":alpha=" + filterExpr(anim.alpha)
+		}
+
+		// Karaoke prefixes are drawn left-aligned, so every layer shares a measured left
+		// edge and a common baseline (text_h differs between the prefix and the full line)
+		if wordTimed {
+			lineWidth := measureTextWidth(fontPath, scaledFontSize, strings.Join(lineWords, " "))
+			xExpr = fmt.Sprintf("(w-%d*%s)/2", lineWidth, scaleExpr)
+			yExpr = fmt.Sprintf("(%.2f)+%d*%s-max_glyph_a", targetY, int(math.Round(float64(scaledFontSize)*0.35)), scaleExpr)
+		}
+		if anim.offset != "" {
+			yExpr += "+" + anim.offset
+		}
 		enableExpr := fmt.Sprintf("enable='between(t,%.2f,%.2f)'", sub.StartTime, sub.EndTime)
 
 		// Optional soft background shadow (simulated with offset box)
@@ -824,16 +730,16 @@ func (s *ProcessingService) buildSubtitlesFilter(subtitles []models.SubtitleConf
 			shadowYOffset := int(math.Round(bgShadowOffset * scaleFactor))
 
 			shadowFilter := fmt.Sprintf(
-				"drawtext=text='%s':fontfile=%s:fontsize=%d:fontcolor=0x000000@0:box=1:boxcolor=%s:boxborderw=%d:x=%s:y=%s+%d:%s",
+				"drawtext=text='%s':fontfile=%s:fontsize=%s:fontcolor=0x000000@0:box=1:boxcolor=%s:boxborderw=%d:x=%s:y=%s:%s%s",
 				text,
 				fontPath,
-				scaledFontSize,
+				fontSizeOpt,
 				shadowColor,
 				shadowBorder,
-				xExpr,
-				yExpr,
-				shadowYOffset,
+				filterExpr(xExpr),
+				filterExpr(fmt.Sprintf("%s+%d", yExpr, shadowYOffset)),
 				enableExpr,
+				alphaOpt,
 			)
 			shadowFilters = append(shadowFilters, shadowFilter)
 		}
@@ -842,16 +748,17 @@ func (s *ProcessingService) buildSubtitlesFilter(subtitles []models.SubtitleConf
 
 		// Base text + background
 		baseFilter := fmt.Sprintf(
-			"drawtext=text='%s':fontfile=%s:fontsize=%d:fontcolor=%s:box=1:boxcolor=%s:boxborderw=%d:x=%s:y=%s:%s",
+			"drawtext=text='%s':fontfile=%s:fontsize=%s:fontcolor=%s:box=1:boxcolor=%s:boxborderw=%d:x=%s:y=%s:%s%s",
 			text,
 			fontPath,
-			scaledFontSize,
+			fontSizeOpt,
 			textColor,
 			bgColorHex,
 			boxBorder,
-			xExpr,
-			yExpr,
+			filterExpr(xExpr),
+			filterExpr(yExpr),
 			enableExpr,
+			alphaOpt,
 		)
 
 		textShadowY := int(math.Round(textShadowOffset * scaleFactor))
@@ -867,25 +774,55 @@ func (s *ProcessingService) buildSubtitlesFilter(subtitles []models.SubtitleConf
 
 		filters = append(filters, baseFilter)
 
-		// Karaoke overlay (text only)
-		if sub.ActiveTextColor != "" && sub.ActiveTextColor != sub.Color {
+		// Karaoke overlay (text only): with word timings, redraw the spoken prefix in the
+		// active color from the moment each word starts, like SubtitleCanvas does
+		if wordTimed && sub.ActiveTextColor != "" && sub.ActiveTextColor != sub.Color {
+			activeColor := s.parseColorToFFmpeg(sub.ActiveTextColor, sub.Color)
+			for i := range lineWords {
+				from := clampFloat(sub.Words[i].Start, sub.StartTime, sub.EndTime)
+				to := sub.EndTime
+				if i+1 < len(sub.Words) {
+					to = clampFloat(sub.Words[i+1].Start, from, sub.EndTime)
+				}
+				if to <= from {
+					continue
+				}
+
+				activeFilter := fmt.Sprintf(
+					"drawtext=text='%s':fontfile=%s:fontsize=%s:fontcolor=%s:box=0:x=%s:y=%s:enable='between(t,%.3f,%.3f)'%s",
+					escapeDrawtext(strings.Join(lineWords[:i+1], " ")),
+					fontPath,
+					fontSizeOpt,
+					activeColor,
+					filterExpr(xExpr),
+					filterExpr(yExpr),
+					from,
+					to,
+					alphaOpt,
+				)
+				filters = append(filters, activeFilter)
+			}
+		} else if sub.ActiveTextColor != "" && sub.ActiveTextColor != sub.Color {
 			activeColor := s.parseColorToFFmpeg(sub.ActiveTextColor, sub.Color)
 			duration := sub.EndTime - sub.StartTime
 			if duration <= 0 {
 				duration = 0.1
 			}
 			fadeDuration := duration * 0.85
+			alphaExpr := fmt.Sprintf("min(1,max(0,(t-%.2f)/%.2f))", sub.StartTime, fadeDuration)
+			if anim.alpha != "" {
+				alphaExpr += "*" + anim.alpha
+			}
 			activeFilter := fmt.Sprintf(
-				"drawtext=text='%s':fontfile=%s:fontsize=%d:fontcolor=%s:box=0:x=%s:y=%s:%s:alpha='min(1\\,max(0\\,(t-%.2f)/%.2f))'",
+				"drawtext=text='%s':fontfile=%s:fontsize=%s:fontcolor=%s:box=0:x=%s:y=%s:%s:alpha=%s",
 				text,
 				fontPath,
-				scaledFontSize,
+				fontSizeOpt,
 				activeColor,
-				xExpr,
-				yExpr,
+				filterExpr(xExpr),
+				filterExpr(yExpr),
 				enableExpr,
-				sub.StartTime,
-				fadeDuration,
+				filterExpr(alphaExpr),
 			)
 
 			filters = append(filters, activeFilter)
@@ -895,6 +832,12 @@ func (s *ProcessingService) buildSubtitlesFilter(subtitles []models.SubtitleConf
 	return strings.Join(filters, ",")
 }
 
+// escapeDrawtext escapes quotes and colons for a single-quoted drawtext text value
+func escapeDrawtext(text string) string {
+	text = strings.ReplaceAll(text, "'", "'\\\\\\''")
+	return strings.ReplaceAll(text, ":", "\\:")
+}
+
 // Helper to parse color to FFmpeg hex format (0xRRGGBB)
 func (s *ProcessingService) parseColorToFFmpeg(color string, defaultColor string) string {
 	if color == "" {
@@ -948,7 +891,9 @@ func (s *ProcessingService) parseColorWithAlpha(color string, opacity float64) s
 	return fmt.Sprintf("0x000000%02X", alpha)
 }
 
-func resolveFontPath(fontFamily string, weight int, bold bool) string {
+// resolveFontPath picks the font file closest to the requested weight, preferring an
+// italic variant when italic is set and falling back to the upright one otherwise
+func resolveFontPath(fontFamily string, weight int, bold bool, italic bool) string {
 	family := strings.ToLower(strings.TrimSpace(fontFamily))
 	if family == "" {
 		family = "default"
@@ -993,6 +938,9 @@ func resolveFontPath(fontFamily string, weight int, bold bool) string {
 	bestDiff := int(math.MaxInt32)
 	for _, variant := range variants {
 		diff := absInt(targetWeight - variant.weight)
+		if variant.italic != italic {
+			diff += 1000 // any weight in the right style beats the wrong style
+		}
 		if diff < bestDiff {
 			bestDiff = diff
 			bestPath = variant.path
@@ -1028,13 +976,14 @@ func getEnv(key, defaultValue string) string {
 
 // ExtractClipOnly - Extrae solo el clip de video sin procesarsubtítulos
 // Esto permite que el frontend se encargue del rendering de subtítulos
-func (s *ProcessingService) ExtractClipOnly(inputPath string, videoID string, startTime float64, endTime float64) (string, error) {
+func (s *ProcessingService) ExtractClipOnly(inputPath string, videoID string, startTime float64, endTime float64, options models.RenderOptions) (string, error) {
 	outputDir := filepath.Join(s.storagePath, "clips")
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create clips directory: %v", err)
 	}
 
-	outputFilename := fmt.Sprintf("%s_raw_%.0f-%.0f.mp4", videoID, startTime, endTime)
+	profile := newOutputProfile(options)
+	outputFilename := fmt.Sprintf("%s_raw_%.0f-%.0f_%s_%s.mp4", videoID, startTime, endTime, profile, profile.framing)
 	outputPath := filepath.Join(outputDir, outputFilename)
 
 	// Verificar que el video existe
@@ -1046,14 +995,13 @@ func (s *ProcessingService) ExtractClipOnly(inputPath string, videoID string, st
 	log.Printf("⏱️  Time: %.2f - %.2f (duration: %.2f)", startTime, endTime, endTime-startTime)
 
 	// Comando FFmpeg para extraer solo el clip (sin subtítulos)
-	// Escalar a formato vertical 1080x1920 (9:16)
+	// Encajar en el perfil de salida (9:16 1080x1920 por defecto)
 	args := []string{
 		"-y",                                  // Sobrescribir si existe
 		"-ss", fmt.Sprintf("%.2f", startTime), // Tiempo de inicio
 		"-i", inputPath, // Video de entrada
 		"-t", fmt.Sprintf("%.2f", endTime-startTime), // Duración
-		"-vf", "scale=-1:1920,crop=min(iw\\,1080):1920", // Escalar a vertical
-		"-s", "1080x1920", // Resolución de salida
+		"-vf", profile.videoFilter(), // Encuadre y resolución de salida
 		"-c:v", "libx264", // Codec de video
 		"-preset", "fast", // Preset rápido (frontend hará el render final)
 		"-crf", "18", // Alta calidad
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress stages besides the pipeline stages (StageDownload, StageTranscribe)
+const (
+	StageExtractAudio = "extract_audio"
+	StageReframe      = "reframe"
+	StageAnalyzeAudio = "analyze_audio" // silence detection and loudness measurement
+	StageRender       = "render"
+	StageThumbnails   = "thumbnails" // cover frame scoring
+	StagePreview      = "preview"    // animated GIF or WebP of a clip
+)
+
+// ProgressUpdate is a point-in-time report of a long running ffmpeg, yt-dlp or
+// transcription step. Fields that the tool does not report are left at zero.
+type ProgressUpdate struct {
+	VideoID    string  `json:"video_id"`
+	ClipID     string  `json:"clip_id,omitempty"`
+	Stage      string  `json:"stage"`
+	Percent    float64 `json:"percent"`
+	ETASeconds float64 `json:"eta_seconds,omitempty"`
+	Bytes      int64   `json:"bytes,omitempty"`
+	TotalBytes int64   `json:"total_bytes,omitempty"`
+	FPS        float64 `json:"fps,omitempty"`
+	Speed      string  `json:"speed,omitempty"`
+	Chunk      int     `json:"chunk,omitempty"`
+	Chunks     int     `json:"chunks,omitempty"`
+}
+
+// ProgressNotifier is called with progress updates (e.g. to push them over WebSocket)
+type ProgressNotifier func(update ProgressUpdate)
+
+// progressThrottle is the minimum time between two updates of the same stage
+const progressThrottle = 500 * time.Millisecond
+
+// progressReporter rate-limits updates per video, clip and stage. The first and the
+// final (100%) update of a stage are always delivered.
+type progressReporter struct {
+	mu       sync.Mutex
+	notify   ProgressNotifier
+	lastSent map[string]time.Time
+}
+
+func newProgressReporter() *progressReporter {
+	return &progressReporter{
+		notify:   func(ProgressUpdate) {},
+		lastSent: make(map[string]time.Time),
+	}
+}
+
+func (r *progressReporter) setNotifier(notify ProgressNotifier) {
+	if notify == nil {
+		notify = func(ProgressUpdate) {}
+	}
+	r.mu.Lock()
+	r.notify = notify
+	r.mu.Unlock()
+}
+
+func (r *progressReporter) report(update ProgressUpdate) {
+	update.Percent = clampFloat(update.Percent, 0, 100)
+	key := update.VideoID + "/" + update.ClipID + "/" + update.Stage
+
+	r.mu.Lock()
+	last, seen := r.lastSent[key]
+	done := update.Percent >= 100
+	if seen && !done && time.Since(last) < progressThrottle {
+		r.mu.Unlock()
+		return
+	}
+	if done {
+		delete(r.lastSent, key)
+	} else {
+		r.lastSent[key] = time.Now()
+	}
+	notify := r.notify
+	r.mu.Unlock()
+
+	notify(update)
+}
+
+// ffmpegProgress is one block of ffmpeg's -progress output
+type ffmpegProgress struct {
+	outTime float64 // seconds of output written
+	fps     float64
+	bytes   int64
+	speed   float64 // multiple of realtime
+	ended   bool
+}
+
+// ffmpegProgressParser accumulates "key=value" lines until the "progress=" line that
+// closes each block
+type ffmpegProgressParser struct {
+	current ffmpegProgress
+}
+
+func (p *ffmpegProgressParser) feed(line string) (ffmpegProgress, bool) {
+	key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+	if !found {
+		return ffmpegProgress{}, false
+	}
+	value = strings.TrimSpace(value)
+
+	switch key {
+	case "out_time_us", "out_time_ms": // both are microseconds
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil && v >= 0 {
+			p.current.outTime = float64(v) / 1e6
+		}
+	case "fps":
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			p.current.fps = v
+		}
+	case "total_size":
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			p.current.bytes = v
+		}
+	case "speed":
+		if v, err := strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64); err == nil {
+			p.current.speed = v
+		}
+	case "progress":
+		block := p.current
+		block.ended = value == "end"
+		p.current = ffmpegProgress{}
+		return block, true
+	}
+
+	return ffmpegProgress{}, false
+}
+
+// update turns the block into a ProgressUpdate for an output of the given duration
+func (b ffmpegProgress) update(duration float64) ProgressUpdate {
+	update := ProgressUpdate{FPS: b.fps, Bytes: b.bytes}
+	if b.speed > 0 {
+		update.Speed = fmt.Sprintf("%.2fx", b.speed)
+	}
+	if duration > 0 {
+		update.Percent = b.outTime / duration * 100
+		if b.speed > 0 && b.outTime < duration {
+			update.ETASeconds = (duration - b.outTime) / b.speed
+		}
+	}
+	if b.ended {
+		update.Percent = 100
+		update.ETASeconds = 0
+	}
+	return update
+}
+
+// runFFmpegWithProgress runs ffmpeg with -progress on stdout and calls onProgress for
+// every block. duration is the expected output length in seconds, used for percent
+// and ETA. The returned output is ffmpeg's stderr, for error messages.
+func (s *ProcessingService) runFFmpegWithProgress(args []string, duration float64, onProgress func(ProgressUpdate)) ([]byte, error) {
+	args = append([]string{"-progress", "pipe:1", "-nostats"}, args...)
+	cmd := exec.Command(s.ffmpegPath, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	parser := &ffmpegProgressParser{}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if block, ok := parser.feed(scanner.Text()); ok {
+			onProgress(block.update(duration))
+		}
+	}
+	io.Copy(io.Discard, stdout)
+
+	err = cmd.Wait()
+	return stderr.Bytes(), err
+}
+
+// yt-dlp --newline progress, e.g.
+// [download]  45.3% of ~ 120.50MiB at    3.20MiB/s ETA 00:30 (frag 12/40)
+var ytdlpProgressRe = regexp.MustCompile(`^\[download\]\s+([\d.]+)%\s+of\s+~?\s*([\d.]+)([KMGT]?i?B)(?:\s+at\s+(\S+))?(?:\s+ETA\s+(\S+))?`)
+
+// parseYtdlpProgress reads one yt-dlp progress line
+func parseYtdlpProgress(line string) (ProgressUpdate, bool) {
+	m := ytdlpProgressRe.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return ProgressUpdate{}, false
+	}
+
+	percent, _ := strconv.ParseFloat(m[1], 64)
+	size, _ := strconv.ParseFloat(m[2], 64)
+	total := int64(size * float64(byteUnit(m[3])))
+
+	update := ProgressUpdate{
+		Stage:      StageDownload,
+		Percent:    percent,
+		TotalBytes: total,
+		Bytes:      int64(float64(total) * percent / 100),
+	}
+	if m[4] != "" && !strings.HasPrefix(m[4], "Unknown") {
+		update.Speed = m[4]
+	}
+	if eta, ok := parseClockDuration(m[5]); ok {
+		update.ETASeconds = eta
+	}
+
+	return update, true
+}
+
+// byteUnit returns the multiplier of a yt-dlp size suffix (KiB, MiB, MB...)
+func byteUnit(unit string) int64 {
+	base := int64(1000)
+	if strings.Contains(unit, "i") {
+		base = 1024
+	}
+	switch unit[0] {
+	case 'K':
+		return base
+	case 'M':
+		return base * base
+	case 'G':
+		return base * base * base
+	case 'T':
+		return base * base * base * base
+	}
+	return 1
+}
+
+// parseClockDuration parses "SS", "MM:SS" or "HH:MM:SS"
+func parseClockDuration(value string) (float64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	seconds := 0.0
+	for _, part := range strings.Split(value, ":") {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, false
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds, true
+}
+
+// runStreamingLines runs cmd and calls onLine for every line it writes to stdout or
+// stderr as soon as it is written. The combined output is returned for error messages.
+func runStreamingLines(cmd *exec.Cmd, onLine func(line string)) ([]byte, error) {
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	waitErr := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		writer.Close()
+		waitErr <- err
+	}()
+
+	var output bytes.Buffer
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		output.WriteString(line)
+		output.WriteByte('\n')
+		onLine(line)
+	}
+	io.Copy(io.Discard, reader)
+
+	return output.Bytes(), <-waitErr
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Built-in prompt templates. Each file defines "version", "system" and "user".
+//
+//go:embed prompts/*.tmpl
+var builtinPrompts embed.FS
+
+// Template names used by the services
+const (
+	PromptClipAnalysis = "clip_analysis"
+	PromptClipRerank   = "clip_rerank"
+	PromptSEO          = "seo"
+)
+
+var promptNameRe = regexp.MustCompile(`^[a-z0-9_\-]+$`)
+
+// PromptVars are the variables available to every template
+type PromptVars struct {
+	Language      string // human readable output language, e.g. "ESPAÑOL"
+	LanguageCode  string
+	Platform      string
+	ClipCount     string
+	MinDuration   int
+	IdealDuration string
+	MaxDuration   int
+	Transcript    string
+	Window        *PromptWindow // set for windowed analysis only
+	Candidates    string        // clip_rerank
+	VideoTitle    string        // seo
+	ClipTitle     string        // seo
+}
+
+// PromptWindow describes the transcript slice of a windowed analysis call
+type PromptWindow struct {
+	Index int
+	Count int
+	Start float64
+	End   float64
+}
+
+// PromptTemplate is a parsed template plus the identifiers recorded with its output
+type PromptTemplate struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Hash     string `json:"hash"`
+	Override bool   `json:"override"`
+	tmpl     *template.Template
+}
+
+// VersionID identifies exactly which prompt produced a result, e.g. clip_analysis@v2#1a2b3c4d
+func (t *PromptTemplate) VersionID() string {
+	return fmt.Sprintf("%s@%s#%s", t.Name, t.Version, t.Hash)
+}
+
+// Render executes the system and user parts of the template
+func (t *PromptTemplate) Render(vars PromptVars) (LLMRequest, error) {
+	var system, user strings.Builder
+	if err := t.tmpl.ExecuteTemplate(&system, "system", vars); err != nil {
+		return LLMRequest{}, fmt.Errorf("failed to render %s system prompt: %v", t.Name, err)
+	}
+	if err := t.tmpl.ExecuteTemplate(&user, "user", vars); err != nil {
+		return LLMRequest{}, fmt.Errorf("failed to render %s prompt: %v", t.Name, err)
+	}
+
+	return LLMRequest{
+		System: strings.TrimSpace(system.String()),
+		Prompt: strings.TrimSpace(user.String()),
+	}, nil
+}
+
+// PromptLibrary resolves templates by name. A file <dir>/<name>.tmpl overrides the
+// built-in template of the same name, and new names add selectable variants. Files
+// are read on every lookup so prompts can be tuned without a restart.
+type PromptLibrary struct {
+	dir string
+}
+
+func NewPromptLibrary(dir string) *PromptLibrary {
+	return &PromptLibrary{dir: dir}
+}
+
+// Get loads and parses the named template
+func (l *PromptLibrary) Get(name string) (*PromptTemplate, error) {
+	if !promptNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid prompt template name: %q", name)
+	}
+
+	override := true
+	data, err := os.ReadFile(filepath.Join(l.dir, name+".tmpl"))
+	if err != nil {
+		override = false
+		data, err = builtinPrompts.ReadFile("prompts/" + name + ".tmpl")
+		if err != nil {
+			return nil, fmt.Errorf("prompt template %q not found", name)
+		}
+	}
+
+	tmpl, err := template.New(name).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template %q: %v", name, err)
+	}
+	for _, part := range []string{"system", "user"} {
+		if tmpl.Lookup(part) == nil {
+			return nil, fmt.Errorf("prompt template %q does not define %q", name, part)
+		}
+	}
+
+	version := "v0"
+	if tmpl.Lookup("version") != nil {
+		var b strings.Builder
+		if err := tmpl.ExecuteTemplate(&b, "version", nil); err == nil && strings.TrimSpace(b.String()) != "" {
+			version = strings.TrimSpace(b.String())
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	return &PromptTemplate{
+		Name:     name,
+		Version:  version,
+		Hash:     hex.EncodeToString(sum[:])[:8],
+		Override: override,
+		tmpl:     tmpl,
+	}, nil
+}
+
+// List returns every available template, built-in and from the override directory
+func (l *PromptLibrary) List() []*PromptTemplate {
+	names := map[string]bool{}
+	if entries, err := builtinPrompts.ReadDir("prompts"); err == nil {
+		for _, entry := range entries {
+			names[strings.TrimSuffix(entry.Name(), ".tmpl")] = true
+		}
+	}
+	if files, err := filepath.Glob(filepath.Join(l.dir, "*.tmpl")); err == nil {
+		for _, file := range files {
+			names[strings.TrimSuffix(filepath.Base(file), ".tmpl")] = true
+		}
+	}
+
+	templates := []*PromptTemplate{}
+	for name := range names {
+		if t, err := l.Get(name); err == nil {
+			templates = append(templates, t)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+
+	return templates
+}
+
+// Language names as they are written into the (Spanish) prompts
+var promptLanguages = map[string]string{
+	"es": "ESPAÑOL",
+	"en": "INGLÉS (English)",
+	"pt": "PORTUGUÉS (Português)",
+	"fr": "FRANCÉS (Français)",
+	"de": "ALEMÁN (Deutsch)",
+	"it": "ITALIANO (Italiano)",
+}
+
+var platformNames = map[string]string{
+	"tiktok":          "TikTok",
+	"youtube_shorts":  "YouTube Shorts",
+	"instagram_reels": "Instagram Reels",
+}
+
+// languageName turns a language code into the name used in prompts
+func languageName(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if name, ok := promptLanguages[code]; ok {
+		return name
+	}
+	if base, _, found := strings.Cut(code, "-"); found {
+		if name, ok := promptLanguages[base]; ok {
+			return name
+		}
+	}
+	return strings.ToUpper(code)
+}
+
+// platformName turns a platform key into the name used in prompts
+func platformName(platform string) string {
+	if platform == "" {
+		return "TikTok, YouTube Shorts e Instagram Reels"
+	}
+	if name, ok := platformNames[strings.ToLower(platform)]; ok {
+		return name
+	}
+	return platform
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Speaker reframing: faces are detected on low resolution grayscale samples of the
+// clip and the crop window follows the main face, moving only when it drifts out of a
+// dead zone so the crop does not jitter.
+const (
+	reframeSampleFPS    = 2
+	reframeSampleWidth  = 480  // analysis frame width in pixels
+	reframeMinFaceSize  = 24   // smallest face window in analysis pixels
+	reframeMinScore     = 5.0  // cascade score for a face to count
+	reframeDeadZone     = 0.08 // drift, as a fraction of the source width, before the crop moves
+	reframeMoveSeconds  = 0.6  // duration of a crop move
+	reframeMedianWindow = 5    // samples in the median filter that drops detection outliers
+)
+
+var (
+	faceCascadeOnce sync.Once
+	faceCascadeData *faceCascade
+	faceCascadeErr  error
+)
+
+// faceCascade loads the cascade from FACE_CASCADE_PATH on first use
+func (s *ProcessingService) faceCascade() (*faceCascade, error) {
+	faceCascadeOnce.Do(func() {
+		path := getEnv("FACE_CASCADE_PATH", "./models/facefinder")
+		faceCascadeData, faceCascadeErr = loadFaceCascade(path)
+		if faceCascadeErr != nil {
+			faceCascadeErr = fmt.Errorf("failed to load face cascade %s: %v", path, faceCascadeErr)
+		} else {
+			log.Printf("🙂 Face cascade loaded: %s (%d trees)", path, faceCascadeData.treeNum)
+		}
+	})
+	return faceCascadeData, faceCascadeErr
+}
+
+// reframeCenter tracks the main face of the clip and returns an ffmpeg expression of
+// t for the horizontal center of the crop window, as a fraction of the frame width.
+// It returns "" when no face was found, leaving the center crop.
+func (s *ProcessingService) reframeCenter(videoID, clipID, inputPath string, start, duration float64, sourceWidth, sourceHeight int) (string, error) {
+	cascade, err := s.faceCascade()
+	if err != nil {
+		return "", err
+	}
+
+	centers, err := s.sampleFaceCenters(cascade, videoID, clipID, inputPath, start, duration, sourceWidth, sourceHeight)
+	if err != nil {
+		return "", err
+	}
+
+	found := 0
+	for _, c := range centers {
+		if c >= 0 {
+			found++
+		}
+	}
+	log.Printf("🙂 Faces found in %d of %d samples", found, len(centers))
+	if found == 0 {
+		return "", nil
+	}
+
+	return cropCenterExpr(cropKeyframes(smoothFaceCenters(centers))), nil
+}
+
+// sampleFaceCenters decodes the clip at reframeSampleFPS and returns the horizontal
+// center of the main face in each sample (0 to 1), or -1 when there is none
+func (s *ProcessingService) sampleFaceCenters(cascade *faceCascade, videoID, clipID, inputPath string, start, duration float64, sourceWidth, sourceHeight int) ([]float64, error) {
+	cols := reframeSampleWidth
+	rows := evenInt(float64(cols) * float64(sourceHeight) / float64(sourceWidth))
+
+	cmd := exec.Command(s.ffmpegPath,
+		"-v", "error",
+		"-ss", fmt.Sprintf("%.2f", start),
+		"-i", inputPath,
+		"-t", fmt.Sprintf("%.2f", duration),
+		"-vf", fmt.Sprintf("fps=%d,scale=%d:%d,format=gray", reframeSampleFPS, cols, rows),
+		"-f", "rawvideo",
+		"pipe:1",
+	)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	expected := int(math.Ceil(duration * reframeSampleFPS))
+	frame := make([]uint8, rows*cols)
+	var centers []float64
+	previous := 0.5
+	for {
+		if _, err := io.ReadFull(stdout, frame); err != nil {
+			break
+		}
+
+		center := -1.0
+		if face, ok := mainFace(cascade.detect(frame, rows, cols, reframeMinFaceSize, reframeMinScore), previous*float64(cols)); ok {
+			center = float64(face.col) / float64(cols)
+			previous = center
+		}
+		centers = append(centers, center)
+
+		if expected > 0 {
+			s.progress.report(ProgressUpdate{
+				VideoID: videoID,
+				ClipID:  clipID,
+				Stage:   StageReframe,
+				Percent: float64(len(centers)) / float64(expected) * 100,
+			})
+		}
+	}
+	io.Copy(io.Discard, stdout)
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to sample frames: %v, output: %s", err, lastLines(stderr.String(), 20))
+	}
+	return centers, nil
+}
+
+// mainFace picks the speaker among the faces of a sample: the largest ones are
+// candidates and the one closest to the previous position wins, so the crop does
+// not hop between two similar faces
+func mainFace(faces []faceDetection, previousCol float64) (faceDetection, bool) {
+	if len(faces) == 0 {
+		return faceDetection{}, false
+	}
+
+	largest := 0
+	for _, face := range faces {
+		if face.size > largest {
+			largest = face.size
+		}
+	}
+
+	best := faceDetection{}
+	bestDistance := math.Inf(1)
+	for _, face := range faces {
+		if float64(face.size) < 0.7*float64(largest) {
+			continue
+		}
+		if d := math.Abs(float64(face.col) - previousCol); d < bestDistance {
+			best, bestDistance = face, d
+		}
+	}
+	return best, true
+}
+
+// smoothFaceCenters fills samples without a face with the closest earlier (or, at
+// the start, later) position and runs a median filter over the result
+func smoothFaceCenters(centers []float64) []float64 {
+	filled := make([]float64, len(centers))
+	last := -1.0
+	for i, c := range centers {
+		if c >= 0 {
+			last = c
+		}
+		filled[i] = last
+	}
+	first := -1.0
+	for i := range filled {
+		if filled[i] >= 0 {
+			first = filled[i]
+			break
+		}
+	}
+	for i := range filled {
+		if filled[i] >= 0 {
+			break
+		}
+		filled[i] = first
+	}
+
+	smoothed := make([]float64, len(filled))
+	half := reframeMedianWindow / 2
+	for i := range filled {
+		window := append([]float64(nil), filled[maxInt(0, i-half):min(len(filled), i+half+1)]...)
+		sort.Float64s(window)
+		smoothed[i] = window[len(window)/2]
+	}
+	return smoothed
+}
+
+// cropKeyframe is a position the crop window moves to, reached at time at
+type cropKeyframe struct {
+	at     float64 // seconds into the clip
+	center float64 // fraction of the frame width
+}
+
+// cropKeyframes keeps the crop still until the face leaves the dead zone around it
+func cropKeyframes(centers []float64) []cropKeyframe {
+	if len(centers) == 0 {
+		return nil
+	}
+
+	keyframes := []cropKeyframe{{at: 0, center: centers[0]}}
+	current := centers[0]
+	for i, c := range centers {
+		if math.Abs(c-current) > reframeDeadZone {
+			keyframes = append(keyframes, cropKeyframe{at: float64(i) / reframeSampleFPS, center: c})
+			current = c
+		}
+	}
+	return keyframes
+}
+
+// cropCenterExpr turns keyframes into an expression of t. Each move is a term that
+// eases from 0 to the change in position, so the expression stays flat (no nested
+// ifs) however many moves there are.
+func cropCenterExpr(keyframes []cropKeyframe) string {
+	if len(keyframes) == 0 {
+		return ""
+	}
+
+	expr := fmt.Sprintf("%.4f", keyframes[0].center)
+	for i := 1; i < len(keyframes); i++ {
+		delta := keyframes[i].center - keyframes[i-1].center
+		moveStart := math.Max(0, keyframes[i].at-reframeMoveSeconds)
+		expr += fmt.Sprintf("%+.4f*(1-cos(PI*clip((t-%.2f)/%.2f,0,1)))/2", delta, moveStart, reframeMoveSeconds)
+	}
+	return expr
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"shortgenerator/models"
+	"strconv"
+	"sync"
+)
+
+// Clip render statuses
+const (
+	ClipStatusQueued     = "queued"
+	ClipStatusProcessing = "processing"
+	ClipStatusCompleted  = "completed"
+	ClipStatusError      = "error"
+)
+
+// ClipNotifier is called whenever a clip render changes status
+type ClipNotifier func(clip *models.Clip)
+
+// RenderService encodes exported clips on a bounded worker pool so the HTTP request
+// that asked for the export can return immediately
+type RenderService struct {
+	videoService      *VideoService
+	clipService       *ClipService
+	processingService *ProcessingService
+	brandKitService   *BrandKitService
+	musicService      *MusicService
+	pool              *WorkerPool
+	notify            ClipNotifier
+
+	mu     sync.Mutex
+	active map[string]bool // clips queued or rendering
+}
+
+func NewRenderService(videoService *VideoService, clipService *ClipService, processingService *ProcessingService, brandKitService *BrandKitService, musicService *MusicService) *RenderService {
+	workers, err := strconv.Atoi(getEnv("MAX_PARALLEL_RENDERS", "1"))
+	if err != nil || workers < 1 {
+		workers = 1
+	}
+
+	return &RenderService{
+		videoService:      videoService,
+		clipService:       clipService,
+		processingService: processingService,
+		brandKitService:   brandKitService,
+		musicService:      musicService,
+		pool:              NewWorkerPool("render", workers),
+		notify:            func(*models.Clip) {},
+		active:            make(map[string]bool),
+	}
+}
+
+// SetNotifier registers the callback used to broadcast clip status changes
+func (r *RenderService) SetNotifier(notify ClipNotifier) {
+	r.notify = notify
+}
+
+// Start launches the render workers
+func (r *RenderService) Start() {
+	r.pool.Start()
+}
+
+// Submit queues a clip for rendering. It returns false if the clip is already queued or rendering.
+func (r *RenderService) Submit(clipID string) bool {
+	r.mu.Lock()
+	if r.active[clipID] {
+		r.mu.Unlock()
+		return false
+	}
+	r.active[clipID] = true
+	r.mu.Unlock()
+
+	queued, running := r.pool.Stats()
+	log.Printf("🎞️  [%s] Render queued (%d queued, %d rendering)", clipID, queued, running)
+
+	r.pool.Enqueue(func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.active, clipID)
+			r.mu.Unlock()
+		}()
+		r.render(clipID)
+	})
+
+	return true
+}
+
+// IsActive reports whether a clip is queued or rendering
+func (r *RenderService) IsActive(clipID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active[clipID]
+}
+
+// Recover re-queues renders that were queued or running when the server stopped
+func (r *RenderService) Recover() error {
+	clips, err := r.clipService.GetClipsByStatus(ClipStatusQueued, ClipStatusProcessing)
+	if err != nil {
+		return err
+	}
+
+	for _, clip := range clips {
+		log.Printf("🔄 [%s] Resuming interrupted render (was %s)", clip.ID, clip.Status)
+		if clip.Status != ClipStatusQueued {
+			clip := clip
+			clip.Status = ClipStatusQueued
+			if err := r.clipService.UpdateClip(&clip); err != nil {
+				log.Printf("⚠️  [%s] Failed to reset clip status: %v", clip.ID, err)
+			}
+		}
+		r.Submit(clip.ID)
+	}
+
+	return nil
+}
+
+func (r *RenderService) render(clipID string) {
+	clip, err := r.clipService.GetClip(clipID)
+	if err != nil {
+		log.Printf("❌ [%s] Failed to load clip for render: %v", clipID, err)
+		return
+	}
+
+	video, err := r.videoService.GetVideo(clip.VideoID)
+	if err != nil {
+		r.fail(clip, fmt.Errorf("video not found: %v", err))
+		return
+	}
+	if video.FilePath == "" {
+		r.fail(clip, fmt.Errorf("video file not available"))
+		return
+	}
+
+	clip.Status = ClipStatusProcessing
+	clip.Error = ""
+	if err := r.clipService.UpdateClip(clip); err != nil {
+		log.Printf("⚠️  [%s] Failed to update clip status: %v", clip.ID, err)
+	}
+	r.notify(clip)
+
+	log.Printf("🎬 [%s] Rendering clip %.2f - %.2f of video %s", clip.ID, clip.StartTime, clip.EndTime, video.ID)
+
+	// Jump cuts find filler words in the word timings of the transcript
+	var transcript *models.Transcript
+	if clip.JumpCut {
+		if transcript, err = r.videoService.GetTranscript(video.ID); err != nil {
+			log.Printf("⚠️  [%s] No transcript for jump cuts, using subtitle words: %v", clip.ID, err)
+		}
+	}
+
+	// The brand kit is read at render time, so edits to it apply to queued clips
+	var brand *models.BrandKit
+	if clip.BrandKitID != "" {
+		if brand, err = r.brandKitService.GetBrandKit(clip.BrandKitID); err != nil {
+			r.fail(clip, fmt.Errorf("brand kit not found: %v", err))
+			return
+		}
+	}
+	var music *models.MusicTrack
+	if clip.MusicID != "" {
+		if music, err = r.musicService.GetTrack(clip.MusicID); err != nil {
+			r.fail(clip, fmt.Errorf("music track not found: %v", err))
+			return
+		}
+	}
+
+	if err := r.processingService.CreateClip(video, clip, transcript, brand, music); err != nil {
+		r.fail(clip, err)
+		return
+	}
+
+	if err := r.clipService.UpdateClip(clip); err != nil {
+		r.fail(clip, fmt.Errorf("failed to update clip: %v", err))
+		return
+	}
+
+	log.Printf("✅ [%s] Clip rendered: %s", clip.ID, clip.FilePath)
+	r.notify(clip)
+}
+
+func (r *RenderService) fail(clip *models.Clip, err error) {
+	log.Printf("❌ [%s] Render failed: %v", clip.ID, err)
+
+	clip.Status = ClipStatusError
+	clip.Error = err.Error()
+	if len(clip.Error) > 2000 {
+		clip.Error = clip.Error[:2000]
+	}
+	if updateErr := r.clipService.UpdateClip(clip); updateErr != nil {
+		log.Printf("⚠️  [%s] Failed to record render error: %v", clip.ID, updateErr)
+	}
+	r.notify(clip)
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"shortgenerator/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// minSceneLength drops cuts closer than this to the previous one (flashes, fades)
+const minSceneLength = 1.0
+
+// ffmpeg's metadata=print lines, e.g.
+// [Parsed_metadata_2 @ 0x5581] frame:3    pts:129129  pts_time:4.3043
+// [Parsed_metadata_2 @ 0x5581] lavfi.scene_score=0.412345
+var (
+	scenePtsTimeRe = regexp.MustCompile(`pts_time:([\d.]+)`)
+	sceneScoreRe   = regexp.MustCompile(`lavfi\.scene_score=([\d.]+)`)
+)
+
+// sceneCut is a detected shot change
+type sceneCut struct {
+	at    float64
+	score float64
+}
+
+// DetectScenes finds the shot changes of a video with ffmpeg's scene score and
+// returns the shots between them. duration is the video length in seconds, used for
+// progress and to close the last shot.
+func (s *ProcessingService) DetectScenes(videoPath, videoID string, duration float64) ([]models.Scene, error) {
+	threshold, err := strconv.ParseFloat(getEnv("SCENE_THRESHOLD", "0.3"), 64)
+	if err != nil || threshold <= 0 || threshold >= 1 {
+		threshold = 0.3
+	}
+
+	// Scores are computed on a small copy: cuts do not need full resolution
+	output, err := s.runFFmpegWithProgress([]string{
+		"-i", videoPath,
+		"-an",
+		"-vf", fmt.Sprintf("scale=320:-2,select='gt(scene\\,%.2f)',metadata=print", threshold),
+		"-f", "null", "-",
+	}, duration, func(update ProgressUpdate) {
+		update.VideoID = videoID
+		update.Stage = StageScenes
+		s.progress.report(update)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect scenes: %v, output: %s", err, lastLines(string(output), 20))
+	}
+
+	cuts := parseSceneCuts(string(output))
+	log.Printf("🎞️  [%s] %d scene cuts above %.2f", videoID, len(cuts), threshold)
+
+	return buildScenes(cuts, duration), nil
+}
+
+// parseSceneCuts reads the cut times and scores from ffmpeg's metadata=print output.
+// Each frame prints its time first and its scene score after.
+func parseSceneCuts(output string) []sceneCut {
+	var cuts []sceneCut
+	current := -1.0
+	for _, line := range strings.Split(output, "\n") {
+		if m := scenePtsTimeRe.FindStringSubmatch(line); m != nil {
+			current, _ = strconv.ParseFloat(m[1], 64)
+			continue
+		}
+		if m := sceneScoreRe.FindStringSubmatch(line); m != nil && current >= 0 {
+			score, _ := strconv.ParseFloat(m[1], 64)
+			cuts = append(cuts, sceneCut{at: current, score: score})
+			current = -1
+		}
+	}
+	return cuts
+}
+
+// buildScenes turns cuts into consecutive shots covering the whole video
+func buildScenes(cuts []sceneCut, duration float64) []models.Scene {
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].at < cuts[j].at })
+
+	scenes := []models.Scene{}
+	start, score := 0.0, 0.0
+	for _, cut := range cuts {
+		if cut.at-start < minSceneLength || (duration > 0 && duration-cut.at < minSceneLength) {
+			continue
+		}
+		scenes = append(scenes, models.Scene{Index: len(scenes), StartTime: start, EndTime: cut.at, Score: score})
+		start, score = cut.at, cut.score
+	}
+	if duration > start {
+		scenes = append(scenes, models.Scene{Index: len(scenes), StartTime: start, EndTime: duration, Score: score})
+	}
+
+	return scenes
+}
+
+// sceneCutTimes returns the times where a new shot starts
+func sceneCutTimes(scenes []models.Scene) []float64 {
+	var cuts []float64
+	for _, scene := range scenes {
+		if scene.Index > 0 {
+			cuts = append(cuts, scene.StartTime)
+		}
+	}
+	return cuts
+}
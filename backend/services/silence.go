@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Silence detection for trimming: anything under silenceNoise for at least
+// silenceMinDuration counts as silence. Cuts leave silencePadding of it on each side
+// so words are not clipped.
+const (
+	silenceNoise       = "-35dB"
+	silenceMinDuration = 0.2
+	silencePadding     = 0.12
+	maxPauseLimit      = 10.0
+)
+
+// detectSilences returns the silent ranges of a clip, in seconds from the clip start
+func (s *ProcessingService) detectSilences(videoID, clipID, inputPath string, start, duration float64) ([]timeRange, error) {
+	output, err := s.runFFmpegWithProgress([]string{
+		"-ss", fmt.Sprintf("%.2f", start),
+		"-i", inputPath,
+		"-t", fmt.Sprintf("%.2f", duration),
+		"-vn",
+		"-af", fmt.Sprintf("silencedetect=noise=%s:d=%.2f", silenceNoise, silenceMinDuration),
+		"-f", "null", "-",
+	}, duration, func(update ProgressUpdate) {
+		update.VideoID = videoID
+		update.ClipID = clipID
+		update.Stage = StageAnalyzeAudio
+		s.progress.report(update)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect silence: %v, output: %s", err, lastLines(string(output), 20))
+	}
+
+	return parseSilences(string(output), duration), nil
+}
+
+// parseSilences pairs silence_start/silence_end lines from ffmpeg output (see
+// parseSilenceMidpoints). A silence still open at the end of the input lasts until
+// duration.
+func parseSilences(output string, duration float64) []timeRange {
+	var silences []timeRange
+	open := -1.0
+	for _, line := range strings.Split(output, "\n") {
+		if m := silenceStartRe.FindStringSubmatch(line); m != nil {
+			open, _ = strconv.ParseFloat(m[1], 64)
+			open = math.Max(0, open)
+			continue
+		}
+		if m := silenceEndRe.FindStringSubmatch(line); m != nil && open >= 0 {
+			end, _ := strconv.ParseFloat(m[1], 64)
+			silences = append(silences, timeRange{open, math.Min(end, duration)})
+			open = -1
+		}
+	}
+	if open >= 0 && open < duration {
+		silences = append(silences, timeRange{open, duration})
+	}
+	return silences
+}
+
+// cutsForSilences picks the silent ranges to remove: leading and trailing silence when
+// trimEdges is set, and internal pauses longer than maxPause (0 keeps them). Each cut
+// leaves silencePadding of silence next to the speech it touches.
+func cutsForSilences(silences []timeRange, duration float64, trimEdges bool, maxPause float64) []timeRange {
+	var cuts []timeRange
+	for _, silence := range silences {
+		atStart := silence.start <= 0.01
+		atEnd := silence.end >= duration-0.01
+
+		switch {
+		case atStart && atEnd:
+			// All silence: nothing sensible to keep, leave the clip alone
+			continue
+		case atStart:
+			if trimEdges {
+				cuts = append(cuts, timeRange{0, silence.end - silencePadding})
+			}
+		case atEnd:
+			if trimEdges {
+				cuts = append(cuts, timeRange{silence.start + silencePadding, duration})
+			}
+		default:
+			if maxPause > 0 && silence.end-silence.start > maxPause {
+				cuts = append(cuts, timeRange{silence.start + silencePadding, silence.end - silencePadding})
+			}
+		}
+	}
+	return cuts
+}
+
+// silenceCuts detects the silence of a clip and returns the ranges to remove for the
+// silence options (see cutsForSilences). Detection errors are not fatal, nothing is
+// removed.
+func (s *ProcessingService) silenceCuts(videoID, clipID, inputPath string, start, duration float64, trimEdges bool, maxPause float64) []timeRange {
+	if !trimEdges && maxPause <= 0 {
+		return nil
+	}
+
+	silences, err := s.detectSilences(videoID, clipID, inputPath, start, duration)
+	if err != nil {
+		log.Printf("⚠️  Silence detection failed, keeping the whole clip: %v", err)
+		return nil
+	}
+	log.Printf("🤫 %d silences found", len(silences))
+
+	return cutsForSilences(silences, duration, trimEdges, maxPause)
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSilences(t *testing.T) {
+	output := "[silencedetect @ 0x1] silence_start: -0.01\n" +
+		"[silencedetect @ 0x1] silence_end: 0.8 | silence_duration: 0.81\n" +
+		"size=N/A time=00:00:05.00 bitrate=N/A\n" +
+		"[silencedetect @ 0x1] silence_start: 4.2\n" +
+		"[silencedetect @ 0x1] silence_end: 5.1 | silence_duration: 0.9\n" +
+		"[silencedetect @ 0x1] silence_start: 9.3\n"
+
+	want := []timeRange{{0, 0.8}, {4.2, 5.1}, {9.3, 10}}
+	if got := parseSilences(output, 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSilences() = %v, want %v", got, want)
+	}
+
+	if got := parseSilences("[silencedetect @ 0x1] silence_start: 10\n", 10); len(got) != 0 {
+		t.Errorf("silence starting at the end = %v, want none", got)
+	}
+}
+
+func TestCutsForSilences(t *testing.T) {
+	silences := []timeRange{{0, 1}, {3, 3.3}, {5, 7}, {9, 10}}
+
+	tests := []struct {
+		name      string
+		silences  []timeRange
+		trimEdges bool
+		maxPause  float64
+		want      []timeRange
+	}{
+		{name: "nothing asked", silences: silences},
+		{name: "trim edges", silences: silences, trimEdges: true, want: []timeRange{{0, 1 - silencePadding}, {9 + silencePadding, 10}}},
+		{name: "long pauses", silences: silences, maxPause: 1, want: []timeRange{{5 + silencePadding, 7 - silencePadding}}},
+		{
+			name:      "both",
+			silences:  silences,
+			trimEdges: true,
+			maxPause:  0.25,
+			want: []timeRange{
+				{0, 1 - silencePadding},
+				{3 + silencePadding, 3.3 - silencePadding},
+				{5 + silencePadding, 7 - silencePadding},
+				{9 + silencePadding, 10},
+			},
+		},
+		{name: "pauses at max_pause are kept", silences: []timeRange{{5, 6}}, maxPause: 1},
+		{name: "all silence is left alone", silences: []timeRange{{0, 10}}, trimEdges: true, maxPause: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cutsForSilences(tt.silences, 10, tt.trimEdges, tt.maxPause); !rangesClose(got, tt.want) {
+				t.Errorf("cutsForSilences() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// rangesClose compares time ranges with a tolerance for float arithmetic
+func rangesClose(a, b []timeRange) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !floatsClose(a[i].start, b[i].start) || !floatsClose(a[i].end, b[i].end) {
+			return false
+		}
+	}
+	return true
+}
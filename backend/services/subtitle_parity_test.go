@@ -0,0 +1,787 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"shortgenerator/models"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The editor sources both renderers follow, relative to this package
+const (
+	editorTemplatesPath = "../../frontend/src/lib/data/templates.ts"
+	editorCanvasPath    = "../../frontend/src/lib/components/SubtitleCanvas.svelte"
+)
+
+// parityText fits one line at the 9:16 size, so positions do not depend on wrapping
+const parityText = "Cada plantilla igual"
+
+func readEditorSource(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skipf("editor source %s not found", path)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+var (
+	editorCaseRe     = regexp.MustCompile(`case "(\w+)":`)
+	editorScaleRe    = regexp.MustCompile(`fromScale = ([\d.]+);`)
+	editorTopRe      = regexp.MustCompile(`fromTop = finalTop(?: ([+-]) ([\d.]+))?;`)
+	editorBlurRe     = regexp.MustCompile(`fromBlur = (-?[\d.]+);`)
+	editorRotationRe = regexp.MustCompile(`fromRotation = (-?[\d.]+);`)
+	editorDurationRe = regexp.MustCompile(`duration: (\d+),`)
+	editorEasingRe   = regexp.MustCompile(`easing: easingFunctions\.(\w+),`)
+)
+
+// editorTransitions reads the cases of applyTransition in SubtitleCanvas.svelte
+func editorTransitions(t *testing.T) map[string]subtitleTransition {
+	source := readEditorSource(t, editorCanvasPath)
+	start := strings.Index(source, "function applyTransition")
+	if start < 0 {
+		t.Fatalf("applyTransition not found in %s", editorCanvasPath)
+	}
+	end := strings.Index(source[start:], "default:")
+	if end < 0 {
+		t.Fatalf("applyTransition has no default case in %s", editorCanvasPath)
+	}
+	source = source[start : start+end]
+
+	number := func(re *regexp.Regexp, block string, group int) float64 {
+		m := re.FindStringSubmatch(block)
+		if m == nil || m[group] == "" {
+			return 0
+		}
+		v, err := strconv.ParseFloat(m[group], 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	transitions := map[string]subtitleTransition{}
+	cases := editorCaseRe.FindAllStringSubmatchIndex(source, -1)
+	for i, c := range cases {
+		blockEnd := len(source)
+		if i+1 < len(cases) {
+			blockEnd = cases[i+1][0]
+		}
+		block := source[c[1]:blockEnd]
+
+		tr := subtitleTransition{
+			durationMs: int(number(editorDurationRe, block, 1)),
+			fromScale:  number(editorScaleRe, block, 1),
+			fromOffset: number(editorTopRe, block, 2),
+			fromRotate: number(editorRotationRe, block, 1),
+			fromBlur:   number(editorBlurRe, block, 1),
+		}
+		if m := editorTopRe.FindStringSubmatch(block); m != nil && m[1] == "-" {
+			tr.fromOffset = -tr.fromOffset
+		}
+		if m := editorEasingRe.FindStringSubmatch(block); m != nil {
+			tr.easing = m[1]
+		}
+		transitions[source[c[2]:c[3]]] = tr
+	}
+	return transitions
+}
+
+func TestSubtitleTransitionsMatchEditor(t *testing.T) {
+	editor := editorTransitions(t)
+	if len(editor) == 0 {
+		t.Fatal("no transitions found in the editor")
+	}
+
+	for name, want := range editor {
+		got, ok := subtitleTransitions[name]
+		if !ok {
+			t.Errorf("editor transition %q has no backend counterpart", name)
+			continue
+		}
+		if got != want {
+			t.Errorf("transition %q = %+v, editor has %+v", name, got, want)
+		}
+	}
+	for name := range subtitleTransitions {
+		if _, ok := editor[name]; !ok {
+			t.Errorf("transition %q is not offered by the editor", name)
+		}
+	}
+}
+
+// The drawtext renderer evaluates easeExpr in ffmpeg and the ASS renderer samples
+// ease, so both must draw the same curve
+func TestTransitionEaseExpressions(t *testing.T) {
+	for name, tr := range subtitleTransitions {
+		t.Run(name, func(t *testing.T) {
+			if got := tr.ease(0); math.Abs(got) > 1e-9 {
+				t.Errorf("ease(0) = %v, want 0", got)
+			}
+			if got := tr.ease(1); math.Abs(got-1) > 1e-9 {
+				t.Errorf("ease(1) = %v, want 1", got)
+			}
+
+			expr := tr.easeExpr("p")
+			for i := 0; i <= 20; i++ {
+				p := float64(i) / 20
+				got, err := evalFFmpegExpr(expr, map[string]float64{"p": p})
+				if err != nil {
+					t.Fatalf("%s: %v", expr, err)
+				}
+				if want := tr.ease(p); math.Abs(got-want) > 1e-5 {
+					t.Errorf("easeExpr at %.2f = %.6f, ease = %.6f", p, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateSubtitleParity(t *testing.T) {
+	readEditorSource(t, editorTemplatesPath) // skips without the frontend
+	templates, err := LoadEditorTemplates(editorTemplatesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) == 0 {
+		t.Fatal("no templates found in the editor")
+	}
+
+	layout := newSubtitleLayout(1080, 1920)
+	for _, tpl := range templates {
+		sub := tpl.Subtitle(parityText, false, 1, 3)
+		t.Run(tpl.Name+"/drawtext", func(t *testing.T) { checkDrawtextParity(t, sub, layout) })
+		t.Run(tpl.Name+"/ass", func(t *testing.T) { checkASSParity(t, sub, layout) })
+	}
+}
+
+// Templates only use some transitions, so every transition is also checked on a
+// subtitle with a background and an active colour
+func TestTransitionSubtitleParity(t *testing.T) {
+	layout := newSubtitleLayout(1080, 1920)
+	names := []string{"none"}
+	for name := range subtitleTransitions {
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		sub := models.SubtitleConfig{
+			Text:            parityText,
+			StartTime:       1,
+			EndTime:         3,
+			FontSize:        20,
+			Color:           "#FFFFFF",
+			ActiveTextColor: "#FFD700",
+			BgColor:         "#101820",
+			BgOpacity:       0.6,
+			Position:        "bottom",
+			BorderRadius:    12,
+			ShadowBlur:      18,
+			Transition:      name,
+		}
+		t.Run(name+"/drawtext", func(t *testing.T) { checkDrawtextParity(t, sub, layout) })
+		t.Run(name+"/ass", func(t *testing.T) { checkASSParity(t, sub, layout) })
+	}
+}
+
+// transitionSamples are the times a subtitle is checked at: before it starts moving,
+// during the transition, when it ends and once it has settled
+func transitionSamples(sub models.SubtitleConfig) []float64 {
+	d := 0.65
+	if tr, ok := lookupSubtitleTransition(sub.Transition); ok {
+		d = tr.duration(sub.EndTime - sub.StartTime)
+	}
+	return []float64{sub.StartTime, sub.StartTime + d/8, sub.StartTime + d/3, sub.StartTime + d/2, sub.StartTime + d, sub.EndTime - 0.01}
+}
+
+// editorEase is where the editor animation is at time at, 1 when there is none
+func editorEase(sub models.SubtitleConfig, at float64) float64 {
+	tr, ok := lookupSubtitleTransition(sub.Transition)
+	if !ok {
+		return 1
+	}
+	return tr.ease((at - sub.StartTime) / tr.duration(sub.EndTime-sub.StartTime))
+}
+
+// checkDrawtextParity evaluates the drawtext expressions of a subtitle over its
+// transition and compares them with the editor animation
+func checkDrawtextParity(t *testing.T, sub models.SubtitleConfig, layout subtitleLayout) {
+	s := &ProcessingService{}
+	tr, animated := lookupSubtitleTransition(sub.Transition)
+	fontSize := float64(layout.fontSize(sub))
+	bgOpacity := subtitleBgOpacity(sub)
+	targetY := layout.targetY(sub.Position)
+	shadowOffset := math.Round(bgShadowOffset * layout.scale)
+
+	layers := parseDrawtextLayers(t, s.buildSubtitlesFilter([]models.SubtitleConfig{sub}, layout))
+
+	// Background shadow, text with its box and the active colour fading in
+	kinds := []string{"text"}
+	if bgOpacity > 0 {
+		kinds = append([]string{"shadow"}, kinds...)
+	}
+	hasActive := sub.ActiveTextColor != "" && sub.ActiveTextColor != sub.Color
+	if hasActive {
+		kinds = append(kinds, "active")
+	}
+	if len(layers) != len(kinds) {
+		t.Fatalf("%d drawtext layers, want %v", len(layers), kinds)
+	}
+
+	wantEnable := fmt.Sprintf("between(t,%.2f,%.2f)", sub.StartTime, sub.EndTime)
+	for i, layer := range layers {
+		kind := kinds[i]
+		if layer["text"] != parityText {
+			t.Errorf("%s text = %q", kind, layer["text"])
+		}
+		if layer["enable"] != wantEnable {
+			t.Errorf("%s enable = %q, want %q", kind, layer["enable"], wantEnable)
+		}
+
+		switch kind {
+		case "shadow":
+			if layer["fontcolor"] != "0x000000@0" {
+				t.Errorf("shadow fontcolor = %q, want an invisible text", layer["fontcolor"])
+			}
+		case "text":
+			if want := "0x" + strings.TrimPrefix(sub.Color, "#"); layer["fontcolor"] != want {
+				t.Errorf("fontcolor = %q, want %q", layer["fontcolor"], want)
+			}
+			if want := fmt.Sprintf("0x%s%02X", strings.TrimPrefix(sub.BgColor, "#"), int(bgOpacity*255)); layer["boxcolor"] != want {
+				t.Errorf("boxcolor = %q, want %q", layer["boxcolor"], want)
+			}
+		case "active":
+			if want := "0x" + strings.TrimPrefix(sub.ActiveTextColor, "#"); layer["fontcolor"] != want {
+				t.Errorf("active fontcolor = %q, want %q", layer["fontcolor"], want)
+			}
+		}
+
+		if !animated && kind != "active" {
+			if _, ok := layer["alpha"]; ok {
+				t.Errorf("%s is faded without a transition: %q", kind, layer["alpha"])
+			}
+		}
+
+		for _, at := range transitionSamples(sub) {
+			vars := map[string]float64{"t": at, "w": float64(layout.width), "h": float64(layout.height), "text_w": 0, "text_h": 0, "max_glyph_a": 0}
+			eased := editorEase(sub, at)
+			value := func(option string) float64 {
+				v, err := evalFFmpegExpr(layer[option], vars)
+				if err != nil {
+					t.Fatalf("%s %s %q: %v", kind, option, layer[option], err)
+				}
+				return v
+			}
+
+			wantAlpha := math.Min(1, eased)
+			if kind == "active" {
+				fade := math.Round((sub.EndTime-sub.StartTime)*0.85*100) / 100
+				wantAlpha = clampFloat((at-sub.StartTime)/fade, 0, 1)
+				if animated {
+					wantAlpha *= math.Min(1, eased)
+				}
+			}
+			if _, ok := layer["alpha"]; ok || animated {
+				if got := value("alpha"); math.Abs(got-wantAlpha) > 1e-3 {
+					t.Errorf("%s alpha at %.3fs = %.4f, editor opacity %.4f", kind, at, got, wantAlpha)
+				}
+			}
+
+			wantSize := fontSize
+			if animated {
+				wantSize = fontSize * (tr.fromScale + (1-tr.fromScale)*eased)
+			}
+			if got := value("fontsize"); math.Abs(got-wantSize) > 0.01 {
+				t.Errorf("%s fontsize at %.3fs = %.3f, editor %.3f", kind, at, got, wantSize)
+			}
+
+			// Centered, and moved from the editor's start offset to the rest position
+			wantY := targetY
+			if animated {
+				wantY += tr.fromOffset * layout.scale * (1 - eased)
+			}
+			if kind == "shadow" {
+				wantY += shadowOffset
+			}
+			if got := value("y"); math.Abs(got-wantY) > 0.01 {
+				t.Errorf("%s y at %.3fs = %.3f, editor %.3f", kind, at, got, wantY)
+			}
+			if got := value("x"); got != float64(layout.width)/2 {
+				t.Errorf("%s x = %v, want centered", kind, got)
+			}
+		}
+	}
+}
+
+// parseDrawtextLayers splits a drawtext chain into the options of every filter,
+// with expressions unquoted
+func parseDrawtextLayers(t *testing.T, chain string) []map[string]string {
+	t.Helper()
+	layers := []map[string]string{}
+	for _, filter := range strings.Split(chain, ",drawtext=") {
+		filter = strings.TrimPrefix(filter, "drawtext=")
+
+		options := map[string]string{}
+		var current strings.Builder
+		quoted := false
+		flush := func() {
+			key, value, ok := strings.Cut(current.String(), "=")
+			if !ok {
+				t.Fatalf("drawtext option without a value: %q", current.String())
+			}
+			options[key] = strings.ReplaceAll(strings.Trim(value, "'"), "\\,", ",")
+			current.Reset()
+		}
+		for i := 0; i < len(filter); i++ {
+			switch c := filter[i]; {
+			case c == '\'':
+				quoted = !quoted
+				current.WriteByte(c)
+			case c == ':' && !quoted:
+				flush()
+			default:
+				current.WriteByte(c)
+			}
+		}
+		flush()
+		layers = append(layers, options)
+	}
+	return layers
+}
+
+// checkASSParity checks the events of a subtitle: timing, colours, the rest position
+// and every \t step of the transition against the editor curve
+func checkASSParity(t *testing.T, sub models.SubtitleConfig, layout subtitleLayout) {
+	s := &ProcessingService{}
+	tr, animated := lookupSubtitleTransition(sub.Transition)
+	bgOpacity := subtitleBgOpacity(sub)
+	script := s.buildASSSubtitles([]models.SubtitleConfig{sub}, layout)
+
+	styles := map[string][]string{}
+	events := [][]string{}
+	for _, line := range strings.Split(script, "\n") {
+		if rest, ok := strings.CutPrefix(line, "Style: "); ok {
+			fields := strings.Split(rest, ",")
+			styles[fields[0]] = fields
+		}
+		if rest, ok := strings.CutPrefix(line, "Dialogue: "); ok {
+			events = append(events, strings.SplitN(rest, ",", 10))
+		}
+	}
+
+	// Background shadow, background box and text
+	wantLayers := []string{"2"}
+	if bgOpacity > 0 {
+		wantLayers = []string{"0", "1", "2"}
+	}
+	if len(events) != len(wantLayers) {
+		t.Fatalf("%d events, want layers %v:\n%s", len(events), wantLayers, script)
+	}
+
+	x, y := float64(layout.width)/2, math.Round(layout.targetY(sub.Position))
+	shownMs := 0
+	if animated {
+		shownMs = int(math.Round(tr.duration(sub.EndTime-sub.StartTime) * 1000))
+	}
+
+	for i, event := range events {
+		layer := event[0]
+		if layer != wantLayers[i] {
+			t.Errorf("event %d on layer %s, want %s", i, layer, wantLayers[i])
+		}
+		if event[1] != assTime(sub.StartTime) || event[2] != assTime(sub.EndTime) {
+			t.Errorf("layer %s shown %s-%s, want %s-%s", layer, event[1], event[2], assTime(sub.StartTime), assTime(sub.EndTime))
+		}
+
+		block, text, ok := strings.Cut(strings.TrimPrefix(event[9], "{"), "}")
+		if !ok {
+			t.Fatalf("layer %s has no override block: %q", layer, event[9])
+		}
+		tags := parseASSTags(block)
+
+		restBlur := 0.0
+		wantY := y
+		switch layer {
+		case "0":
+			shadowBlur := sub.ShadowBlur
+			if shadowBlur <= 0 {
+				shadowBlur = 12
+			}
+			restBlur = float64(shadowBlur) * layout.scale / 6
+			wantY += math.Round(bgShadowOffset * layout.scale)
+			// The layer's own blur comes before the transition's
+			if got, _ := strconv.ParseFloat(tags.first("blur"), 64); math.Abs(got-restBlur) > 0.05 {
+				t.Errorf("shadow \\blur %.2f, want %.2f", got, restBlur)
+			}
+		case "1":
+			if want := assColorBGR(sub.BgColor, "#000000"); tags.last("1c") != want {
+				t.Errorf("box \\1c = %q, want %q", tags.last("1c"), want)
+			}
+			if want := fmt.Sprintf("&H%02X&", 255-int(math.Round(bgOpacity*255))); tags.last("1a") != want {
+				t.Errorf("box \\1a = %q, want %q", tags.last("1a"), want)
+			}
+		case "2":
+			wantText := escapeASS(parityText)
+			if got := strings.ReplaceAll(text, "\\N", " "); got != wantText {
+				t.Errorf("text = %q, want %q", got, wantText)
+			}
+			style := styles[event[3]]
+			if len(style) < 4 {
+				t.Fatalf("style %q not defined", event[3])
+			}
+			if want := assColor(sub.Color, "#FFFFFF"); style[3] != want || style[4] != want {
+				t.Errorf("style colours %s %s, want %s", style[3], style[4], want)
+			}
+		}
+
+		// Rest position, and the start offset of \move
+		if animated && tr.fromOffset != 0 {
+			want := fmt.Sprintf("%.0f,%.0f,%.0f,%.0f,0,%d", x, wantY+tr.fromOffset*layout.scale, x, wantY, shownMs)
+			if got := tags.last("move"); got != want {
+				t.Errorf("layer %s \\move(%s), want \\move(%s)", layer, got, want)
+			}
+		} else if got, want := tags.last("pos"), fmt.Sprintf("%.0f,%.0f", x, wantY); got != want {
+			t.Errorf("layer %s \\pos(%s), want \\pos(%s)", layer, got, want)
+		}
+
+		if !animated {
+			if tags.last("fad") != "" {
+				t.Errorf("layer %s fades without a transition", layer)
+			}
+		} else if want := fmt.Sprintf("%d,0", shownMs); tags.last("fad") != want {
+			t.Errorf("layer %s \\fad(%s), want \\fad(%s)", layer, tags.last("fad"), want)
+		}
+
+		// Steps of the transition, then the active colour fading in over the line
+		steps := []assTag{}
+		for _, tag := range tags {
+			if tag.name != "t" {
+				continue
+			}
+			if layer == "2" && strings.Contains(tag.value, "\\1c") {
+				want := fmt.Sprintf("0,%d,\\1c%s", int((sub.EndTime-sub.StartTime)*0.85*1000), assColorBGR(sub.ActiveTextColor, sub.Color))
+				if tag.value != want {
+					t.Errorf("active colour \\t(%s), want \\t(%s)", tag.value, want)
+				}
+				continue
+			}
+			steps = append(steps, tag)
+		}
+
+		moves := animated && (tr.fromScale != 1 || tr.fromRotate != 0 || tr.fromBlur != 0)
+		if !moves {
+			if len(steps) > 0 {
+				t.Errorf("layer %s has %d \\t steps without a transition", layer, len(steps))
+			}
+			continue
+		}
+		if len(steps) != assTransitionSteps {
+			t.Fatalf("layer %s has %d \\t steps, want %d", layer, len(steps), assTransitionSteps)
+		}
+
+		checkState := func(label string, state assTags, eased float64) {
+			if tr.fromScale != 1 {
+				want := (tr.fromScale + (1-tr.fromScale)*eased) * 100
+				if got := state.float(t, "fscx"); math.Abs(got-want) > 0.05 {
+					t.Errorf("layer %s %s \\fscx%.1f, editor %.2f", layer, label, got, want)
+				}
+				if state.last("fscy") != state.last("fscx") {
+					t.Errorf("layer %s %s scales unevenly", layer, label)
+				}
+			}
+			if tr.fromRotate != 0 {
+				// The canvas rotates clockwise, \frz counter-clockwise
+				want := -tr.fromRotate * (1 - eased)
+				if got := state.float(t, "frz"); math.Abs(got-want) > 0.05 {
+					t.Errorf("layer %s %s \\frz%.1f, editor %.2f", layer, label, got, want)
+				}
+			}
+			if tr.fromBlur != 0 {
+				want := restBlur + tr.fromBlur*layout.scale*math.Max(0, 1-eased)
+				if got := state.float(t, "blur"); math.Abs(got-want) > 0.05 {
+					t.Errorf("layer %s %s \\blur%.1f, editor %.2f", layer, label, got, want)
+				}
+			}
+		}
+
+		checkState("start", tags, 0)
+		for step, tag := range steps {
+			from, to, state := tag.transform(t)
+			if wantFrom, wantTo := shownMs*step/assTransitionSteps, shownMs*(step+1)/assTransitionSteps; from != wantFrom || to != wantTo {
+				t.Errorf("layer %s step %d runs %d-%dms, want %d-%dms", layer, step, from, to, wantFrom, wantTo)
+			}
+			checkState(fmt.Sprintf("step %d", step+1), state, tr.ease(float64(step+1)/assTransitionSteps))
+		}
+	}
+}
+
+// assTag is one override tag, \fscx94.0 is {fscx 94.0} and \pos(1,2) is {pos 1,2}
+type assTag struct {
+	name, value string
+}
+
+type assTags []assTag
+
+var assTagNameRe = regexp.MustCompile(`^(\d[a-z]|[a-z]+)`)
+
+// parseASSTags splits an override block into its tags, keeping \t as one tag
+func parseASSTags(block string) assTags {
+	tags := assTags{}
+	for len(block) > 0 {
+		if block[0] != '\\' {
+			block = block[1:]
+			continue
+		}
+		block = block[1:]
+		name := assTagNameRe.FindString(block)
+		block = block[len(name):]
+
+		var value string
+		if strings.HasPrefix(block, "(") {
+			depth, end := 0, len(block)
+			for i, c := range block {
+				if c == '(' {
+					depth++
+				} else if c == ')' {
+					depth--
+					if depth == 0 {
+						end = i
+						break
+					}
+				}
+			}
+			value = block[1:end]
+			block = block[min(end+1, len(block)):]
+		} else {
+			end := strings.IndexByte(block, '\\')
+			if end < 0 {
+				end = len(block)
+			}
+			value, block = block[:end], block[end:]
+		}
+		tags = append(tags, assTag{name: name, value: value})
+	}
+	return tags
+}
+
+// first returns the value of the first tag with the name
+func (tags assTags) first(name string) string {
+	for _, tag := range tags {
+		if tag.name == name {
+			return tag.value
+		}
+	}
+	return ""
+}
+
+// last returns the value of the last tag with the name, the one libass applies
+func (tags assTags) last(name string) string {
+	for i := len(tags) - 1; i >= 0; i-- {
+		if tags[i].name == name {
+			return tags[i].value
+		}
+	}
+	return ""
+}
+
+// float returns the last value of a tag outside \t as a number
+func (tags assTags) float(t *testing.T, name string) float64 {
+	t.Helper()
+	v, err := strconv.ParseFloat(tags.last(name), 64)
+	if err != nil {
+		t.Fatalf("\\%s: %v", name, err)
+	}
+	return v
+}
+
+// transform splits \t(from,to,tags)
+func (tag assTag) transform(t *testing.T) (int, int, assTags) {
+	t.Helper()
+	parts := strings.SplitN(tag.value, ",", 3)
+	if len(parts) != 3 {
+		t.Fatalf("\\t(%s) has no times", tag.value)
+	}
+	from, err1 := strconv.Atoi(parts[0])
+	to, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		t.Fatalf("\\t(%s) has invalid times", tag.value)
+	}
+	return from, to, parseASSTags(parts[2])
+}
+
+// evalFFmpegExpr evaluates the subset of the ffmpeg expression language the
+// subtitle renderers write: arithmetic, comparisons, if, clip, min, max, pow, sqrt
+// and sin
+func evalFFmpegExpr(expr string, vars map[string]float64) (float64, error) {
+	p := &exprParser{src: strings.ReplaceAll(expr, " ", ""), vars: vars}
+	v := p.sum()
+	if p.err == nil && p.pos < len(p.src) {
+		p.err = fmt.Errorf("unexpected %q at %d", p.src[p.pos:], p.pos)
+	}
+	return v, p.err
+}
+
+type exprParser struct {
+	src  string
+	pos  int
+	vars map[string]float64
+	err  error
+}
+
+func (p *exprParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) expect(c byte) {
+	if p.peek() != c {
+		if p.err == nil {
+			p.err = fmt.Errorf("expected %q at %d in %q", c, p.pos, p.src)
+		}
+		return
+	}
+	p.pos++
+}
+
+func (p *exprParser) sum() float64 {
+	v := p.product()
+	for p.err == nil {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			v += p.product()
+		case '-':
+			p.pos++
+			v -= p.product()
+		default:
+			return v
+		}
+	}
+	return v
+}
+
+func (p *exprParser) product() float64 {
+	v := p.unary()
+	for p.err == nil {
+		switch p.peek() {
+		case '*':
+			p.pos++
+			v *= p.unary()
+		case '/':
+			p.pos++
+			v /= p.unary()
+		default:
+			return v
+		}
+	}
+	return v
+}
+
+func (p *exprParser) unary() float64 {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		return -p.unary()
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() float64 {
+	start := p.pos
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		v := p.sum()
+		p.expect(')')
+		return v
+	case c >= '0' && c <= '9' || c == '.':
+		for c := p.peek(); c >= '0' && c <= '9' || c == '.'; c = p.peek() {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil && p.err == nil {
+			p.err = err
+		}
+		return v
+	}
+
+	for c := p.peek(); c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_'; c = p.peek() {
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	if name == "" {
+		if p.err == nil {
+			p.err = fmt.Errorf("unexpected %q at %d in %q", c, p.pos, p.src)
+		}
+		return 0
+	}
+	if p.peek() != '(' {
+		v, ok := p.vars[name]
+		if !ok && p.err == nil {
+			p.err = fmt.Errorf("unknown variable %q", name)
+		}
+		return v
+	}
+
+	p.pos++
+	args := []float64{p.sum()}
+	for p.err == nil && p.peek() == ',' {
+		p.pos++
+		args = append(args, p.sum())
+	}
+	p.expect(')')
+	if p.err != nil {
+		return 0
+	}
+
+	truth := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	arity := map[string]int{"if": 3, "clip": 3, "min": 2, "max": 2, "pow": 2, "gte": 2, "lte": 2, "lt": 2, "sqrt": 1, "sin": 1}
+	if n, ok := arity[name]; !ok || n != len(args) {
+		p.err = fmt.Errorf("unsupported function %s with %d arguments", name, len(args))
+		return 0
+	}
+	switch name {
+	case "if":
+		if args[0] != 0 {
+			return args[1]
+		}
+		return args[2]
+	case "clip":
+		return clampFloat(args[0], args[1], args[2])
+	case "min":
+		return math.Min(args[0], args[1])
+	case "max":
+		return math.Max(args[0], args[1])
+	case "pow":
+		return math.Pow(args[0], args[1])
+	case "gte":
+		return truth(args[0] >= args[1])
+	case "lte":
+		return truth(args[0] <= args[1])
+	case "lt":
+		return truth(args[0] < args[1])
+	case "sqrt":
+		return math.Sqrt(args[0])
+	default:
+		return math.Sin(args[0])
+	}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// subtitleTransition is the entrance animation of a subtitle as applyTransition in
+// SubtitleCanvas.svelte defines it: opacity goes from 0 to 1 and every other property
+// from its start value to rest, all following the same easing curve.
+type subtitleTransition struct {
+	durationMs int
+	fromScale  float64 // 1 is the rest size
+	fromOffset float64 // vertical start offset in canvas pixels, positive is below
+	fromRotate float64 // degrees, clockwise like the canvas
+	fromBlur   float64 // canvas pixels
+	easing     string
+}
+
+// subtitleTransitions has every transition name the editor offers, "none" excluded
+var subtitleTransitions = map[string]subtitleTransition{
+	"pop":     {durationMs: 650, fromScale: 0.94, easing: "easeOutQuart"},
+	"fade":    {durationMs: 700, fromScale: 0.99, easing: "easeOutCubic"},
+	"slide":   {durationMs: 700, fromScale: 0.98, fromOffset: 30, easing: "easeOutExpo"},
+	"bounce":  {durationMs: 800, fromScale: 0.95, fromOffset: -15, easing: "easeOutBack"},
+	"zoom":    {durationMs: 700, fromScale: 0.85, easing: "easeOutQuart"},
+	"blur":    {durationMs: 800, fromScale: 1, fromBlur: 20, easing: "easeOutExpo"},
+	"scale":   {durationMs: 750, fromScale: 0.7, easing: "easeOutCirc"},
+	"rotate":  {durationMs: 800, fromScale: 0.9, fromRotate: -10, easing: "easeOutQuart"},
+	"flip":    {durationMs: 850, fromScale: 0.8, fromRotate: 90, easing: "easeOutBack"},
+	"elastic": {durationMs: 900, fromScale: 0.88, fromOffset: -20, easing: "easeOutElastic"},
+	"spring":  {durationMs: 850, fromScale: 0.92, fromOffset: 25, easing: "easeInOutBack"},
+}
+
+// lookupSubtitleTransition returns the transition for a name, false for "none" or
+// unknown names
+func lookupSubtitleTransition(name string) (subtitleTransition, bool) {
+	t, ok := subtitleTransitions[strings.ToLower(strings.TrimSpace(name))]
+	return t, ok
+}
+
+// duration of the transition in seconds, capped to the time the subtitle is shown
+func (t subtitleTransition) duration(shown float64) float64 {
+	return math.Max(0.01, math.Min(float64(t.durationMs)/1000, shown))
+}
+
+// ease evaluates the easing curve at progress p in [0, 1]; overshooting curves
+// (back, elastic) go past 1 before settling
+func (t subtitleTransition) ease(p float64) float64 {
+	p = clampFloat(p, 0, 1)
+	switch t.easing {
+	case "easeOutCubic":
+		return 1 - math.Pow(1-p, 3)
+	case "easeOutQuart":
+		return 1 - math.Pow(1-p, 4)
+	case "easeOutExpo":
+		if p == 1 {
+			return 1
+		}
+		return 1 - math.Pow(2, -10*p)
+	case "easeOutCirc":
+		return math.Sqrt(1 - math.Pow(p-1, 2))
+	case "easeOutBack":
+		return 1 + 1.8*math.Pow(p-1, 3) + 0.8*math.Pow(p-1, 2)
+	case "easeOutElastic":
+		if p == 0 || p == 1 {
+			return p
+		}
+		return math.Pow(2, -8*p)*math.Sin((p*10-0.75)*2*math.Pi/3) + 1
+	case "easeInOutBack":
+		const c2 = 1.70158 * 1.525
+		if p < 0.5 {
+			return math.Pow(2*p, 2) * ((c2+1)*2*p - c2) / 2
+		}
+		return (math.Pow(2*p-2, 2)*((c2+1)*(p*2-2)+c2) + 2) / 2
+	}
+	return p
+}
+
+// easeExpr is ease as an ffmpeg expression of the progress expression p
+func (t subtitleTransition) easeExpr(p string) string {
+	switch t.easing {
+	case "easeOutCubic":
+		return fmt.Sprintf("(1-pow(1-%s,3))", p)
+	case "easeOutQuart":
+		return fmt.Sprintf("(1-pow(1-%s,4))", p)
+	case "easeOutExpo":
+		return fmt.Sprintf("if(gte(%s,1),1,1-pow(2,-10*%s))", p, p)
+	case "easeOutCirc":
+		return fmt.Sprintf("sqrt(1-pow(%s-1,2))", p)
+	case "easeOutBack":
+		return fmt.Sprintf("(1+1.8*pow(%s-1,3)+0.8*pow(%s-1,2))", p, p)
+	case "easeOutElastic":
+		return fmt.Sprintf("if(gte(%s,1),1,if(lte(%s,0),0,pow(2,-8*%s)*sin((%s*10-0.75)*%.6f)+1))", p, p, p, p, 2*math.Pi/3)
+	case "easeInOutBack":
+		const c2 = 1.70158 * 1.525
+		return fmt.Sprintf("if(lt(%s,0.5),pow(2*%s,2)*(%.6f*2*%s-%.6f)/2,(pow(2*%s-2,2)*(%.6f*(%s*2-2)+%.6f)+2)/2)",
+			p, p, c2+1, p, c2, p, c2+1, p, c2)
+	}
+	return p
+}
+
+// drawtextAnimation holds the drawtext option expressions that animate one subtitle
+type drawtextAnimation struct {
+	alpha  string // empty when not animated
+	scale  string // multiplier of the font size, empty when constant
+	offset string // added to y, empty when constant
+}
+
+// drawtextTransition builds the expressions for a subtitle shown from start to end,
+// with canvas offsets scaled to the output by scale.
+// drawtext cannot rotate or blur text, so those transitions keep their fade, scale
+// and movement only.
+func drawtextTransition(name string, start, end, scale float64) drawtextAnimation {
+	t, ok := lookupSubtitleTransition(name)
+	if !ok {
+		return drawtextAnimation{}
+	}
+
+	progress := fmt.Sprintf("clip((t-%.3f)/%.3f,0,1)", start, t.duration(end-start))
+	eased := t.easeExpr(progress)
+
+	// Opacity never overshoots
+	animation := drawtextAnimation{alpha: fmt.Sprintf("min(1,%s)", eased)}
+	if t.fromScale != 1 {
+		animation.scale = fmt.Sprintf("(%.3f+%.3f*%s)", t.fromScale, 1-t.fromScale, eased)
+	}
+	if t.fromOffset != 0 {
+		animation.offset = fmt.Sprintf("%.2f*(1-%s)", t.fromOffset*scale, eased)
+	}
+
+	return animation
+}
+
+// filterExpr quotes an expression as a filter option value (drawtext, crop...)
+func filterExpr(expr string) string {
+	return "'" + strings.ReplaceAll(expr, ",", "\\,") + "'"
+}
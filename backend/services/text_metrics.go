@@ -0,0 +1,95 @@
+package services
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+)
+
+// Parsed fonts keyed by file path; nil entries remember fonts that failed to load
+var (
+	parsedFonts   = map[string]*opentype.Font{}
+	parsedFontsMu sync.Mutex
+)
+
+func loadFont(path string) *opentype.Font {
+	parsedFontsMu.Lock()
+	defer parsedFontsMu.Unlock()
+
+	if f, ok := parsedFonts[path]; ok {
+		return f
+	}
+
+	var parsed *opentype.Font
+	data, err := os.ReadFile(path)
+	if err == nil {
+		parsed, err = opentype.Parse(data)
+	}
+	if err != nil {
+		log.Printf("⚠️  Failed to load font %s for text measurement: %v", path, err)
+		parsed = nil
+	}
+
+	parsedFonts[path] = parsed
+	return parsed
+}
+
+// measureTextWidth returns the rendered width in pixels of text at fontSize px,
+// matching what drawtext produces for the same font file. When the font cannot
+// be read it falls back to an average glyph width estimate.
+func measureTextWidth(fontPath string, fontSize int, text string) int {
+	if f := loadFont(fontPath); f != nil {
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{
+			Size:    float64(fontSize),
+			DPI:     72,
+			Hinting: font.HintingNone,
+		})
+		if err == nil {
+			defer face.Close()
+			return font.MeasureString(face, text).Round()
+		}
+	}
+
+	return int(float64(utf8.RuneCountInString(text)*fontSize) * 0.56)
+}
+
+// fontLineHeight returns ascent plus descent in pixels at fontSize px. libass sizes
+// fonts by this height rather than by the em size drawtext uses.
+func fontLineHeight(fontPath string, fontSize int) float64 {
+	if f := loadFont(fontPath); f != nil {
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{
+			Size:    float64(fontSize),
+			DPI:     72,
+			Hinting: font.HintingNone,
+		})
+		if err == nil {
+			defer face.Close()
+			metrics := face.Metrics()
+			return float64(metrics.Ascent+metrics.Descent) / 64
+		}
+	}
+
+	return float64(fontSize) * 1.16
+}
+
+// wrapWords greedily breaks words into lines no wider than maxWidth and returns the
+// index of the first word of every line
+func wrapWords(fontPath string, fontSize int, words []string, maxWidth int) []int {
+	starts := []int{}
+	for i := range words {
+		if len(starts) == 0 {
+			starts = append(starts, i)
+			continue
+		}
+		line := strings.Join(words[starts[len(starts)-1]:i+1], " ")
+		if measureTextWidth(fontPath, fontSize, line) > maxWidth {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"shortgenerator/models"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ThumbnailService finds cover frames for clip ranges and renders the chosen one at
+// the cover sizes. Images are stored under storage/thumbnails/<video id>.
+type ThumbnailService struct {
+	db                *sql.DB
+	processingService *ProcessingService
+}
+
+func NewThumbnailService(db *sql.DB, processingService *ProcessingService) *ThumbnailService {
+	return &ThumbnailService{db: db, processingService: processingService}
+}
+
+const thumbnailColumns = `id, video_id, COALESCE(clip_id, ''), kind, COALESCE(time, 0), COALESCE(score, 0),
+			  COALESCE(width, 0), COALESCE(height, 0), COALESCE(title, ''), COALESCE(source_id, ''),
+			  COALESCE(selected, 0), file_path, created_at`
+
+func scanThumbnail(row interface{ Scan(...interface{}) error }, thumb *models.Thumbnail) error {
+	err := row.Scan(
+		&thumb.ID, &thumb.VideoID, &thumb.ClipID, &thumb.Kind, &thumb.Time, &thumb.Score,
+		&thumb.Width, &thumb.Height, &thumb.Title, &thumb.SourceID,
+		&thumb.Selected, &thumb.FilePath, &thumb.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	thumb.URL = "/api/thumbnails/" + thumb.ID + "/download"
+	return nil
+}
+
+// newThumbnail assigns an ID and an image path to a thumbnail of the video
+func (s *ThumbnailService) newThumbnail(videoID, clipID, kind string) (*models.Thumbnail, error) {
+	dir := filepath.Join(s.processingService.StoragePath(), "thumbnails", videoID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create thumbnails directory: %v", err)
+	}
+
+	id := uuid.New().String()
+	return &models.Thumbnail{
+		ID:        id,
+		VideoID:   videoID,
+		ClipID:    clipID,
+		Kind:      kind,
+		FilePath:  filepath.Join(dir, id+".jpg"),
+		URL:       "/api/thumbnails/" + id + "/download",
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (s *ThumbnailService) createThumbnail(thumb *models.Thumbnail) error {
+	query := `INSERT INTO thumbnails (id, video_id, clip_id, kind, time, score, width, height, title, source_id, selected, file_path, created_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.db.Exec(query, thumb.ID, thumb.VideoID, thumb.ClipID, thumb.Kind, thumb.Time, thumb.Score,
+		thumb.Width, thumb.Height, thumb.Title, thumb.SourceID, thumb.Selected, thumb.FilePath, thumb.CreatedAt)
+
+	return err
+}
+
+func (s *ThumbnailService) GetThumbnail(id string) (*models.Thumbnail, error) {
+	thumb := &models.Thumbnail{}
+
+	query := `SELECT ` + thumbnailColumns + ` FROM thumbnails WHERE id = ?`
+	if err := scanThumbnail(s.db.QueryRow(query, id), thumb); err != nil {
+		return nil, err
+	}
+
+	return thumb, nil
+}
+
+// GetThumbnails lists the thumbnails of a video, only those of one clip when clipID
+// is set: covers first (vertical, then horizontal), then candidates by score
+func (s *ThumbnailService) GetThumbnails(videoID, clipID string) ([]models.Thumbnail, error) {
+	query := `SELECT ` + thumbnailColumns + ` FROM thumbnails WHERE video_id = ?`
+	args := []interface{}{videoID}
+	if clipID != "" {
+		query += ` AND clip_id = ?`
+		args = append(args, clipID)
+	}
+	query += ` ORDER BY kind = 'candidate', score DESC, height > width DESC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	thumbs := []models.Thumbnail{}
+	for rows.Next() {
+		var thumb models.Thumbnail
+		if err := scanThumbnail(rows, &thumb); err != nil {
+			return nil, err
+		}
+		thumbs = append(thumbs, thumb)
+	}
+
+	return thumbs, rows.Err()
+}
+
+// ClipCover is the vertical cover of a clip, or its chosen candidate when no cover
+// has been rendered
+func (s *ThumbnailService) ClipCover(clipID string) (*models.Thumbnail, error) {
+	thumb := &models.Thumbnail{}
+
+	query := `SELECT ` + thumbnailColumns + ` FROM thumbnails
+			  WHERE clip_id = ? AND (kind = 'cover' OR selected = 1)
+			  ORDER BY kind = 'candidate', height > width DESC, created_at DESC LIMIT 1`
+	if err := scanThumbnail(s.db.QueryRow(query, clipID), thumb); err != nil {
+		return nil, err
+	}
+
+	return thumb, nil
+}
+
+// deleteThumbnails removes the thumbnails of a video and clip that match the extra
+// condition, with their images
+func (s *ThumbnailService) deleteThumbnails(videoID, clipID, condition string) error {
+	rows, err := s.db.Query(`SELECT file_path FROM thumbnails WHERE video_id = ? AND clip_id = ? AND `+condition, videoID, clipID)
+	if err != nil {
+		return err
+	}
+	paths := []string{}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return err
+		}
+		paths = append(paths, path)
+	}
+	rows.Close()
+
+	if _, err := s.db.Exec(`DELETE FROM thumbnails WHERE video_id = ? AND clip_id = ? AND `+condition, videoID, clipID); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		os.Remove(path)
+	}
+	return nil
+}
+
+// DeleteClipThumbnails removes every candidate and cover of a clip, with their images
+func (s *ThumbnailService) DeleteClipThumbnails(clip *models.Clip) error {
+	return s.deleteThumbnails(clip.VideoID, clip.ID, `1 = 1`)
+}
+
+// GenerateCandidates scores the frames of the source ranges and stores the best
+// count of them as candidates, best first. They replace the unchosen candidates of
+// the same clip (or, without a clip, of the video).
+func (s *ThumbnailService) GenerateCandidates(video *models.Video, clipID string, ranges []models.ClipSegment, count int) ([]models.Thumbnail, error) {
+	frames := []CoverFrame{}
+	for _, r := range ranges {
+		picked, err := s.processingService.ScoreFrames(video.FilePath, video.ID, r.StartTime, r.EndTime, count)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, picked...)
+	}
+	// Every range returned its own best frames, keep the best overall
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].Score > frames[j].Score })
+	if len(frames) > count {
+		frames = frames[:count]
+	}
+
+	if err := s.deleteThumbnails(video.ID, clipID, `kind = 'candidate' AND selected = 0`); err != nil {
+		return nil, fmt.Errorf("failed to remove old candidates: %v", err)
+	}
+
+	info, err := s.processingService.ProbeMedia(video.FilePath)
+	if err != nil {
+		info = &MediaInfo{}
+	}
+
+	thumbs := []models.Thumbnail{}
+	for _, frame := range frames {
+		thumb, err := s.newThumbnail(video.ID, clipID, models.ThumbnailCandidate)
+		if err != nil {
+			return nil, err
+		}
+		thumb.Time = frame.At
+		thumb.Score = frame.Score
+		thumb.Width, thumb.Height = info.Width, info.Height
+
+		if err := s.processingService.ExtractFrame(video.FilePath, frame.At, thumb.FilePath); err != nil {
+			return nil, err
+		}
+		if err := s.createThumbnail(thumb); err != nil {
+			os.Remove(thumb.FilePath)
+			return nil, err
+		}
+		thumbs = append(thumbs, *thumb)
+	}
+
+	log.Printf("🖼️  [%s] %d cover candidates for clip %q", video.ID, len(thumbs), clipID)
+	return thumbs, nil
+}
+
+// SelectCandidate makes the candidate the cover of its clip: it renders it at every
+// cover size, with the title when there is one, replacing the previous covers
+func (s *ThumbnailService) SelectCandidate(candidate *models.Thumbnail, title string) ([]models.Thumbnail, error) {
+	if candidate.Kind != models.ThumbnailCandidate {
+		return nil, fmt.Errorf("thumbnail is not a candidate")
+	}
+
+	covers := []models.Thumbnail{}
+	for _, size := range coverSizes {
+		cover, err := s.newThumbnail(candidate.VideoID, candidate.ClipID, models.ThumbnailCover)
+		if err != nil {
+			return nil, err
+		}
+		cover.Time = candidate.Time
+		cover.Width, cover.Height = size[0], size[1]
+		cover.Title = title
+		cover.SourceID = candidate.ID
+
+		if err := s.processingService.RenderCover(candidate.FilePath, cover.Width, cover.Height, title, cover.FilePath); err != nil {
+			for _, c := range covers {
+				os.Remove(c.FilePath)
+			}
+			return nil, err
+		}
+		covers = append(covers, *cover)
+	}
+
+	if err := s.deleteThumbnails(candidate.VideoID, candidate.ClipID, `kind = 'cover'`); err != nil {
+		return nil, fmt.Errorf("failed to remove old covers: %v", err)
+	}
+	if _, err := s.db.Exec(`UPDATE thumbnails SET selected = (id = ?) WHERE video_id = ? AND clip_id = ?`,
+		candidate.ID, candidate.VideoID, candidate.ClipID); err != nil {
+		return nil, err
+	}
+	candidate.Selected = true
+
+	for i := range covers {
+		if err := s.createThumbnail(&covers[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return covers, nil
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"regexp"
+	"shortgenerator/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Cover frame scoring
+const (
+	thumbnailSampleFPS   = 2   // frames scored per second of the range
+	thumbnailCutSettle   = 0.5 // frames this close after a cut are mid-transition
+	thumbnailMinSpacing  = 1.0 // seconds between candidates of the same shot
+	thumbnailSharpWeight = 0.6 // the rest of the score is exposure
+	thumbnailCutScore    = 0.3 // scene score that starts a new shot
+	thumbnailMidGray     = 0.5 // best average luma
+	thumbnailJPEGQuality = "2" // ffmpeg -q:v, lower is better
+)
+
+// Candidates returned for a clip range
+const (
+	DefaultThumbnailCount = 6
+	MaxThumbnailCount     = 12
+)
+
+// coverSizes are the covers rendered for a chosen candidate: vertical for Shorts,
+// Reels and TikTok, horizontal for YouTube
+var coverSizes = [][2]int{{1080, 1920}, {1280, 720}}
+
+// ffmpeg's metadata=print keys of the frame scoring pass
+var (
+	frameLumaRe = regexp.MustCompile(`lavfi\.signalstats\.YAVG=([\d.]+)`)
+	frameBlurRe = regexp.MustCompile(`lavfi\.blur=([\d.]+)`)
+)
+
+// CoverFrame is a sampled frame and how good a cover it makes
+type CoverFrame struct {
+	At    float64 // source seconds
+	Score float64 // 0 to 1
+
+	luma  float64 // average luma, 0 to 1
+	blur  float64 // blurdetect estimate, higher is blurrier
+	scene float64 // scene change score against the previous sample
+}
+
+// ScoreFrames samples the source range at thumbnailSampleFPS and returns the best
+// cover frames, at most count: the sharpest and best exposed frame of each shot,
+// then the next best ones when there are few shots
+func (s *ProcessingService) ScoreFrames(videoPath, videoID string, start, end float64, count int) ([]CoverFrame, error) {
+	// Scores are computed on a small copy: exposure and blur do not need full resolution
+	output, err := s.runFFmpegWithProgress([]string{
+		"-ss", fmt.Sprintf("%.3f", start),
+		"-t", fmt.Sprintf("%.3f", end-start),
+		"-i", videoPath,
+		"-an",
+		"-vf", fmt.Sprintf("fps=%d,scale=320:-2,select='gte(scene\\,0)',signalstats,blurdetect,metadata=print", thumbnailSampleFPS),
+		"-f", "null", "-",
+	}, end-start, func(update ProgressUpdate) {
+		update.VideoID = videoID
+		update.Stage = StageThumbnails
+		s.progress.report(update)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to score frames: %v, output: %s", err, lastLines(string(output), 20))
+	}
+
+	frames := parseFrameScores(string(output), start)
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no frames in range %.2f - %.2f", start, end)
+	}
+	scoreFrames(frames)
+
+	return pickCoverFrames(frames, count), nil
+}
+
+// parseFrameScores reads the metadata=print output. Each frame prints its time
+// first and its scene score, luma and blur after; times are relative to start.
+func parseFrameScores(output string, start float64) []CoverFrame {
+	var frames []CoverFrame
+	for _, line := range strings.Split(output, "\n") {
+		if m := scenePtsTimeRe.FindStringSubmatch(line); m != nil {
+			at, _ := strconv.ParseFloat(m[1], 64)
+			frames = append(frames, CoverFrame{At: start + at})
+			continue
+		}
+		if len(frames) == 0 {
+			continue
+		}
+		frame := &frames[len(frames)-1]
+		if m := sceneScoreRe.FindStringSubmatch(line); m != nil {
+			frame.scene, _ = strconv.ParseFloat(m[1], 64)
+		} else if m := frameLumaRe.FindStringSubmatch(line); m != nil {
+			luma, _ := strconv.ParseFloat(m[1], 64)
+			frame.luma = luma / 255
+		} else if m := frameBlurRe.FindStringSubmatch(line); m != nil {
+			frame.blur, _ = strconv.ParseFloat(m[1], 64)
+		}
+	}
+	return frames
+}
+
+// scoreFrames rates every frame from 0 to 1. Blur estimates depend on the footage,
+// so sharpness is relative to the sharpest and blurriest frames of the range;
+// exposure is best at mid gray and worst on black or white frames.
+func scoreFrames(frames []CoverFrame) {
+	minBlur, maxBlur := math.Inf(1), math.Inf(-1)
+	for _, f := range frames {
+		minBlur = math.Min(minBlur, f.blur)
+		maxBlur = math.Max(maxBlur, f.blur)
+	}
+
+	for i := range frames {
+		sharpness := 1.0
+		if maxBlur > minBlur {
+			sharpness = (maxBlur - frames[i].blur) / (maxBlur - minBlur)
+		}
+		exposure := 1 - math.Min(1, math.Abs(frames[i].luma-thumbnailMidGray)/thumbnailMidGray)
+		frames[i].Score = thumbnailSharpWeight*sharpness + (1-thumbnailSharpWeight)*exposure
+	}
+}
+
+// pickCoverFrames picks the best frame of every shot, skipping frames that are
+// still settling after a cut, and fills up to count with the next best frames
+// spaced out from those already picked. Picks are sorted by score.
+func pickCoverFrames(frames []CoverFrame, count int) []CoverFrame {
+	shots := [][]CoverFrame{}
+	lastCut := math.Inf(-1)
+	for i, f := range frames {
+		if i == 0 || f.scene >= thumbnailCutScore {
+			shots = append(shots, nil)
+			if i > 0 {
+				lastCut = f.At
+			}
+		}
+		if f.At-lastCut < thumbnailCutSettle {
+			continue
+		}
+		shots[len(shots)-1] = append(shots[len(shots)-1], f)
+	}
+
+	byScore := func(list []CoverFrame) {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Score > list[j].Score })
+	}
+
+	picked := []CoverFrame{}
+	rest := []CoverFrame{}
+	for _, shot := range shots {
+		if len(shot) == 0 {
+			continue
+		}
+		byScore(shot)
+		picked = append(picked, shot[0])
+		rest = append(rest, shot[1:]...)
+	}
+	byScore(picked)
+	if len(picked) >= count {
+		return picked[:count]
+	}
+
+	byScore(rest)
+	for _, f := range rest {
+		if len(picked) == count {
+			break
+		}
+		spaced := true
+		for _, p := range picked {
+			if math.Abs(p.At-f.At) < thumbnailMinSpacing {
+				spaced = false
+				break
+			}
+		}
+		if spaced {
+			picked = append(picked, f)
+		}
+	}
+	byScore(picked)
+	return picked
+}
+
+// ExtractFrame saves the source frame at second `at`, at full resolution, as a JPEG
+func (s *ProcessingService) ExtractFrame(videoPath string, at float64, outputPath string) error {
+	cmd := exec.Command(s.ffmpegPath,
+		"-y",
+		"-ss", fmt.Sprintf("%.3f", at),
+		"-i", videoPath,
+		"-frames:v", "1",
+		"-q:v", thumbnailJPEGQuality,
+		outputPath,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to extract frame: %v, output: %s", err, lastLines(string(output), 20))
+	}
+	return nil
+}
+
+// RenderCover fills a width x height cover with the frame, cropping what overflows,
+// and draws the title over it like a headline: centered on vertical covers, near
+// the bottom on horizontal ones
+func (s *ProcessingService) RenderCover(framePath string, width, height int, title, outputPath string) error {
+	filters := []string{fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d,setsar=1", width, height, width, height)}
+
+	if title = strings.TrimSpace(title); title != "" {
+		headline := models.HeadlineOverlay{
+			Text:       title,
+			FontSize:   40,
+			FontWeight: 800,
+			Color:      "#FFFFFF",
+			BgColor:    "#000000",
+			BgOpacity:  0.6,
+			Position:   "center",
+		}
+		if width > height {
+			// The canvas scales with the height, so landscape text needs a bigger size
+			headline.FontSize = 72
+			headline.Position = "bottom"
+		}
+		filters = append(filters, s.headlineFilters(headline, newSubtitleLayout(width, height))...)
+	}
+
+	cmd := exec.Command(s.ffmpegPath,
+		"-y",
+		"-i", framePath,
+		"-vf", strings.Join(filters, ","),
+		"-frames:v", "1",
+		"-q:v", thumbnailJPEGQuality,
+		outputPath,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to render cover: %v, output: %s", err, lastLines(string(output), 20))
+	}
+
+	log.Printf("🖼️  Cover rendered: %dx%d %s", width, height, outputPath)
+	return nil
+}
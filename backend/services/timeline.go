@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"shortgenerator/models"
+	"sort"
+	"strings"
+)
+
+// timeRange is a span of a clip in seconds from the clip start
+type timeRange struct {
+	start float64
+	end   float64
+}
+
+// minKeptRange drops kept fragments too short to be seen or heard as anything but a glitch
+const minKeptRange = 0.1
+
+// clipTimeline lists the parts of a clip that end up in the export, in order. A
+// timeline with one range covering the clip keeps everything; removed ranges (silence,
+// pauses, filler words) are closed up, shifting everything after them earlier.
+type clipTimeline struct {
+	duration float64 // source clip length
+	ranges   []timeRange
+}
+
+// fullTimeline keeps the whole clip
+func fullTimeline(duration float64) clipTimeline {
+	return clipTimeline{duration: duration, ranges: []timeRange{{0, duration}}}
+}
+
+// without removes the given ranges from the timeline
+func (tl clipTimeline) without(removed []timeRange) clipTimeline {
+	sort.Slice(removed, func(i, j int) bool { return removed[i].start < removed[j].start })
+
+	kept := tl.ranges
+	for _, cut := range removed {
+		if cut.end <= cut.start {
+			continue
+		}
+		next := []timeRange{}
+		for _, r := range kept {
+			if cut.end <= r.start || cut.start >= r.end {
+				next = append(next, r)
+				continue
+			}
+			if cut.start > r.start {
+				next = append(next, timeRange{r.start, cut.start})
+			}
+			if cut.end < r.end {
+				next = append(next, timeRange{cut.end, r.end})
+			}
+		}
+		kept = next
+	}
+
+	result := clipTimeline{duration: tl.duration}
+	for _, r := range kept {
+		if r.end-r.start >= minKeptRange {
+			result.ranges = append(result.ranges, r)
+		}
+	}
+	return result
+}
+
+// isFull reports whether nothing is removed
+func (tl clipTimeline) isFull() bool {
+	return len(tl.ranges) == 1 && tl.ranges[0].start <= 0 && tl.ranges[0].end >= tl.duration
+}
+
+// outputDuration is the length of the export
+func (tl clipTimeline) outputDuration() float64 {
+	total := 0.0
+	for _, r := range tl.ranges {
+		total += r.end - r.start
+	}
+	return total
+}
+
+// remap converts a clip time to the export time. Times inside a removed range move
+// to where the next kept range starts.
+func (tl clipTimeline) remap(t float64) float64 {
+	elapsed := 0.0
+	for _, r := range tl.ranges {
+		if t < r.start {
+			return elapsed
+		}
+		if t <= r.end {
+			return elapsed + t - r.start
+		}
+		elapsed += r.end - r.start
+	}
+	return elapsed
+}
+
+// remapSubtitles moves subtitles (and their word timings) to new times with remap.
+// Subtitles left shorter than minKeptRange were removed and are dropped.
+func remapSubtitles(subtitles []models.SubtitleConfig, remap func(float64) float64) []models.SubtitleConfig {
+	remapped := []models.SubtitleConfig{}
+	for _, sub := range subtitles {
+		sub.StartTime, sub.EndTime = remap(sub.StartTime), remap(sub.EndTime)
+		if sub.EndTime-sub.StartTime < minKeptRange {
+			continue
+		}
+		if len(sub.Words) > 0 {
+			words := make([]models.Word, len(sub.Words))
+			for i, w := range sub.Words {
+				w.Start, w.End = remap(w.Start), remap(w.End)
+				words[i] = w
+			}
+			sub.Words = words
+		}
+		remapped = append(remapped, sub)
+	}
+	return remapped
+}
+
+// selectExpr is true for the frames and samples of the kept ranges
+func (tl clipTimeline) selectExpr() string {
+	terms := make([]string, len(tl.ranges))
+	for i, r := range tl.ranges {
+		terms[i] = fmt.Sprintf("between(t,%.3f,%.3f)", r.start, r.end)
+	}
+	return strings.Join(terms, "+")
+}
+
+// videoSelect keeps the frames of the kept ranges. It must come first in the chain,
+// before anything that changes timestamps, and be followed by videoSetPTS.
+func (tl clipTimeline) videoSelect() string {
+	if tl.isFull() {
+		return ""
+	}
+	return "select=" + filterExpr(tl.selectExpr())
+}
+
+// videoSetPTS closes the gaps left by videoSelect
+func (tl clipTimeline) videoSetPTS() string {
+	if tl.isFull() {
+		return ""
+	}
+	return "setpts=N/FRAME_RATE/TB"
+}
+
+// audioSelect keeps the samples of the kept ranges and closes the gaps
+func (tl clipTimeline) audioSelect() string {
+	if tl.isFull() {
+		return ""
+	}
+	return "aselect=" + filterExpr(tl.selectExpr()) + ",asetpts=N/SR/TB"
+}
+
+// removedSeconds is how much the timeline cuts from the clip
+func (tl clipTimeline) removedSeconds() float64 {
+	return math.Max(0, tl.duration-tl.outputDuration())
+}
+
+// joinFilters chains the non-empty filters of a filtergraph
+func joinFilters(filters ...string) string {
+	kept := []string{}
+	for _, f := range filters {
+		if f != "" {
+			kept = append(kept, f)
+		}
+	}
+	return strings.Join(kept, ",")
+}
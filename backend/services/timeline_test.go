@@ -0,0 +1,120 @@
+package services
+
+import (
+	"reflect"
+	"shortgenerator/models"
+	"testing"
+)
+
+func TestTimelineWithout(t *testing.T) {
+	tests := []struct {
+		name    string
+		removed []timeRange
+		want    []timeRange
+	}{
+		{name: "nothing removed", want: []timeRange{{0, 10}}},
+		{name: "one cut", removed: []timeRange{{2, 3}}, want: []timeRange{{0, 2}, {3, 10}}},
+		{
+			name:    "unsorted and overlapping cuts",
+			removed: []timeRange{{6, 8}, {1, 2}, {7, 9}},
+			want:    []timeRange{{0, 1}, {2, 6}, {9, 10}},
+		},
+		{name: "cuts at the edges", removed: []timeRange{{0, 1.5}, {8.5, 10}}, want: []timeRange{{1.5, 8.5}}},
+		{name: "fragments shorter than minKeptRange are dropped", removed: []timeRange{{0, 4.95}, {5, 6}}, want: []timeRange{{6, 10}}},
+		{name: "empty and inverted cuts are ignored", removed: []timeRange{{4, 4}, {6, 5}}, want: []timeRange{{0, 10}}},
+		{name: "everything removed", removed: []timeRange{{0, 10}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fullTimeline(10).without(tt.removed)
+			if got.duration != 10 || !reflect.DeepEqual(got.ranges, tt.want) {
+				t.Errorf("without() = %+v, want ranges %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimelineDurations(t *testing.T) {
+	full := fullTimeline(10)
+	if !full.isFull() || full.outputDuration() != 10 || full.removedSeconds() != 0 {
+		t.Errorf("full timeline = %+v", full)
+	}
+	if full.videoSelect() != "" || full.videoSetPTS() != "" || full.audioSelect() != "" {
+		t.Error("a full timeline needs no filters")
+	}
+
+	cut := full.without([]timeRange{{2, 3}, {5, 6.5}})
+	if cut.isFull() || cut.outputDuration() != 7.5 || cut.removedSeconds() != 2.5 {
+		t.Errorf("cut timeline = %+v, output %v, removed %v", cut, cut.outputDuration(), cut.removedSeconds())
+	}
+	if want := "between(t,0.000,2.000)+between(t,3.000,5.000)+between(t,6.500,10.000)"; cut.selectExpr() != want {
+		t.Errorf("selectExpr() = %q, want %q", cut.selectExpr(), want)
+	}
+}
+
+func TestTimelineRemap(t *testing.T) {
+	tl := fullTimeline(10).without([]timeRange{{2, 3}, {5, 6}})
+
+	tests := []struct {
+		t    float64
+		want float64
+	}{
+		{0, 0},
+		{1.5, 1.5},
+		{2, 2},
+		{2.5, 2}, // removed: moves to the start of the next kept range
+		{3, 2},
+		{4, 3},
+		{5.5, 4},
+		{8, 6},
+		{10, 8},
+		{12, 8}, // past the clip: the end of the export
+	}
+
+	for _, tt := range tests {
+		if got := tl.remap(tt.t); got != tt.want {
+			t.Errorf("remap(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestRemapSubtitles(t *testing.T) {
+	tl := fullTimeline(10).without([]timeRange{{2, 3}, {5, 6}})
+	subtitles := []models.SubtitleConfig{
+		{Text: "hola a todos", StartTime: 0.5, EndTime: 4, Words: []models.Word{
+			{Start: 0.5, End: 1, Text: "hola"}, {Start: 1.2, End: 1.4, Text: "a"}, {Start: 3.2, End: 4, Text: "todos"},
+		}},
+		{Text: "um", StartTime: 5.1, EndTime: 5.9},
+		{Text: "fin", StartTime: 6, EndTime: 9},
+	}
+
+	want := []models.SubtitleConfig{
+		{Text: "hola a todos", StartTime: 0.5, EndTime: 3, Words: []models.Word{
+			{Start: 0.5, End: 1, Text: "hola"}, {Start: 1.2, End: 1.4, Text: "a"}, {Start: 2.2, End: 3, Text: "todos"},
+		}},
+		{Text: "fin", StartTime: 4, EndTime: 7},
+	}
+
+	got := remapSubtitles(subtitles, tl.remap)
+	if len(got) != len(want) {
+		t.Fatalf("remapSubtitles() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Text != want[i].Text || !floatsClose(got[i].StartTime, want[i].StartTime) || !floatsClose(got[i].EndTime, want[i].EndTime) {
+			t.Errorf("subtitle %d = %+v, want %+v", i, got[i], want[i])
+		}
+		for j := range want[i].Words {
+			if !floatsClose(got[i].Words[j].Start, want[i].Words[j].Start) || !floatsClose(got[i].Words[j].End, want[i].Words[j].End) {
+				t.Errorf("subtitle %d word %d = %+v, want %+v", i, j, got[i].Words[j], want[i].Words[j])
+			}
+		}
+	}
+	if subtitles[0].Words[2].Start != 3.2 {
+		t.Error("remapSubtitles changed the input words")
+	}
+}
+
+func floatsClose(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
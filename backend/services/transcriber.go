@@ -0,0 +1,220 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"shortgenerator/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Transcriber turns the extracted 16 kHz mono WAV of a video into a transcript
+type Transcriber interface {
+	Name() string
+	Transcribe(audioPath string, videoID string) (*models.Transcript, error)
+}
+
+// newTranscriber picks the backend from TRANSCRIBER (openai, local, mock).
+// When unset it prefers the Whisper API if OPENAI_API_KEY is configured, then a
+// local whisper binary at WHISPER_PATH, and finally the mock transcript.
+// The Whisper API is wrapped in a chunkedTranscriber because of its upload limit.
+func newTranscriber(whisperPath, ffmpegPath string) Transcriber {
+	switch strings.ToLower(getEnv("TRANSCRIBER", "")) {
+	case "openai":
+		return newChunkedTranscriber(&openAITranscriber{}, ffmpegPath)
+	case "local":
+		return newLocalWhisperTranscriber(whisperPath)
+	case "mock":
+		return mockTranscriber{}
+	}
+
+	if os.Getenv("OPENAI_API_KEY") != "" {
+		return newChunkedTranscriber(&openAITranscriber{}, ffmpegPath)
+	}
+
+	if _, err := os.Stat(whisperPath); err == nil {
+		return newLocalWhisperTranscriber(whisperPath)
+	}
+
+	log.Printf("⚠️  OPENAI_API_KEY not set and no whisper binary at %s, using mock transcript", whisperPath)
+	return mockTranscriber{}
+}
+
+// mockTranscriber returns a fixed two-segment transcript so the pipeline can run without Whisper
+type mockTranscriber struct{}
+
+func (mockTranscriber) Name() string { return "mock" }
+
+func (mockTranscriber) Transcribe(audioPath string, videoID string) (*models.Transcript, error) {
+	return createMockTranscript(videoID), nil
+}
+
+func createMockTranscript(videoID string) *models.Transcript {
+	return &models.Transcript{
+		ID:       uuid.New().String(),
+		VideoID:  videoID,
+		Language: "en",
+		Segments: []models.Segment{
+			{Start: 0, End: 5, Text: "This is a sample transcript."},
+			{Start: 5, End: 10, Text: "Whisper integration needed."},
+		},
+		FullText:  "This is a sample transcript. Whisper integration needed.",
+		CreatedAt: time.Now(),
+	}
+}
+
+// openAITranscriber uses the hosted Whisper API (OPENAI_API_URL / OPENAI_API_KEY)
+type openAITranscriber struct{}
+
+func (*openAITranscriber) Name() string { return "openai" }
+
+func (*openAITranscriber) Transcribe(audioPath string, videoID string) (*models.Transcript, error) {
+	apiURL := getEnv("OPENAI_API_URL", "https://api.openai.com/v1") + "/audio/transcriptions"
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("OPENAI_API_KEY not set")
+	}
+
+	// Open audio file
+	file, err := os.Open(audioPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %v", err)
+	}
+	defer file.Close()
+
+	// Create multipart form
+	var requestBody bytes.Buffer
+	writer := multipart.NewWriter(&requestBody)
+
+	// Add file
+	part, err := writer.CreateFormFile("file", filepath.Base(audioPath))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, err
+	}
+
+	// Add model
+	if err := writer.WriteField("model", "whisper-1"); err != nil {
+		return nil, err
+	}
+
+	// Add response format with timestamps
+	if err := writer.WriteField("response_format", "verbose_json"); err != nil {
+		return nil, err
+	}
+
+	// Request word timings alongside segments for karaoke subtitles
+	for _, granularity := range []string{"word", "segment"} {
+		if err := writer.WriteField("timestamp_granularities[]", granularity); err != nil {
+			return nil, err
+		}
+	}
+
+	// Close the writer
+	contentType := writer.FormDataContentType()
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	// Make request
+	req, err := http.NewRequest("POST", apiURL, &requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Content-Type", contentType)
+
+	log.Printf("🎤 Calling Whisper API...")
+	client := &http.Client{Timeout: 120 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Whisper API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	log.Printf("✅ Whisper API response received")
+
+	// Parse response
+	var whisperResp struct {
+		Text     string `json:"text"`
+		Language string `json:"language"`
+		Segments []struct {
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+			Text  string  `json:"text"`
+		} `json:"segments"`
+		Words []struct {
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+			Word  string  `json:"word"`
+		} `json:"words"`
+	}
+
+	if err := json.Unmarshal(respBody, &whisperResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	// Convert to our format
+	segments := make([]models.Segment, len(whisperResp.Segments))
+	for i, seg := range whisperResp.Segments {
+		segments[i] = models.Segment{
+			Start: seg.Start,
+			End:   seg.End,
+			Text:  strings.TrimSpace(seg.Text),
+		}
+	}
+
+	words := make([]models.Word, 0, len(whisperResp.Words))
+	for _, w := range whisperResp.Words {
+		words = append(words, models.Word{Start: w.Start, End: w.End, Text: strings.TrimSpace(w.Word)})
+	}
+	assignWordsToSegments(segments, words)
+
+	transcript := &models.Transcript{
+		ID:        uuid.New().String(),
+		VideoID:   videoID,
+		Language:  whisperResp.Language,
+		Segments:  segments,
+		FullText:  whisperResp.Text,
+		CreatedAt: time.Now(),
+	}
+
+	return transcript, nil
+}
+
+// assignWordsToSegments distributes a flat word list over segments by the word's midpoint
+func assignWordsToSegments(segments []models.Segment, words []models.Word) {
+	if len(segments) == 0 {
+		return
+	}
+
+	i := 0
+	for _, word := range words {
+		if word.Text == "" {
+			continue
+		}
+		mid := (word.Start + word.End) / 2
+		for i < len(segments)-1 && mid >= segments[i].End && mid >= segments[i+1].Start {
+			i++
+		}
+		segments[i].Words = append(segments[i].Words, word)
+	}
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"shortgenerator/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUploadNotFound       = errors.New("upload not found")
+	ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+	ErrUploadTooLarge       = errors.New("upload exceeds declared size")
+)
+
+var supportedUploadExtensions = map[string]bool{
+	".mp4":  true,
+	".mov":  true,
+	".m4v":  true,
+	".mkv":  true,
+	".webm": true,
+	".avi":  true,
+}
+
+// IsSupportedUpload reports whether a filename has a video extension we can ingest
+func IsSupportedUpload(filename string) bool {
+	return supportedUploadExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// UploadSession tracks a resumable upload. Sessions are persisted as JSON sidecars
+// next to the partial file so an interrupted upload can continue after a restart.
+type UploadSession struct {
+	ID        string    `json:"id"`
+	Filename  string    `json:"filename"`
+	Title     string    `json:"title"`
+	Size      int64     `json:"size"`
+	Offset    int64     `json:"offset"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Applied to the video created once the upload completes
+	Options models.AnalysisOptions `json:"options"`
+
+	// The video created from the upload. Finalized sessions keep their JSON sidecar
+	// so a retried last chunk gets the same video back.
+	VideoID string `json:"video_id,omitempty"`
+}
+
+// Complete reports whether every byte of the upload has been received
+func (u *UploadSession) Complete() bool {
+	return u.Offset >= u.Size
+}
+
+type UploadService struct {
+	dir     string
+	maxSize int64
+
+	// Each session has its own lock so a slow chunk only holds up its own upload;
+	// mu guards the map
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func NewUploadService(storagePath string) *UploadService {
+	var maxSizeMB int64 = 4096
+	fmt.Sscanf(getEnv("MAX_UPLOAD_SIZE_MB", "4096"), "%d", &maxSizeMB)
+
+	return &UploadService{
+		dir:     filepath.Join(storagePath, "uploads"),
+		maxSize: maxSizeMB * 1024 * 1024,
+		locks:   make(map[string]*sync.Mutex),
+	}
+}
+
+// lockSession locks the session and returns its unlock function
+func (s *UploadService) lockSession(id string) func() {
+	s.mu.Lock()
+	lock, ok := s.locks[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[id] = lock
+	}
+	s.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// forgetSession drops the lock of a session that no longer exists
+func (s *UploadService) forgetSession(id string) {
+	s.mu.Lock()
+	delete(s.locks, id)
+	s.mu.Unlock()
+}
+
+// MaxSize returns the largest accepted upload in bytes
+func (s *UploadService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// CreateSession starts a new resumable upload
+func (s *UploadService) CreateSession(filename string, title string, size int64, options models.AnalysisOptions) (*UploadSession, error) {
+	if !IsSupportedUpload(filename) {
+		return nil, fmt.Errorf("unsupported file type: %s", filepath.Ext(filename))
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid upload size: %d", size)
+	}
+	if size > s.maxSize {
+		return nil, ErrUploadTooLarge
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create uploads directory: %v", err)
+	}
+
+	session := &UploadSession{
+		ID:        uuid.New().String(),
+		Filename:  filepath.Base(filename),
+		Title:     title,
+		Size:      size,
+		CreatedAt: time.Now(),
+		Options:   options,
+	}
+
+	file, err := os.Create(s.partPath(session.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload file: %v", err)
+	}
+	file.Close()
+
+	if err := s.saveSession(session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetSession loads an upload session; the offset reflects the bytes on disk. It
+// does not wait for a chunk in progress, whose bytes are counted as they land.
+func (s *UploadService) GetSession(id string) (*UploadSession, error) {
+	return s.loadSession(id)
+}
+
+// AppendChunk writes a chunk that must start at the current offset. Once the session
+// is loaded it is returned with any error, so callers can report the offset to
+// resume from.
+func (s *UploadService) AppendChunk(id string, offset int64, chunk io.Reader) (*UploadSession, error) {
+	defer s.lockSession(id)()
+
+	session, err := s.loadSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset != session.Offset {
+		return session, ErrUploadOffsetMismatch
+	}
+	if session.VideoID != "" {
+		// A retry of the last chunk: it must not carry more bytes
+		if n, _ := io.Copy(io.Discard, io.LimitReader(chunk, 1)); n > 0 {
+			return session, ErrUploadTooLarge
+		}
+		return session, nil
+	}
+
+	file, err := os.OpenFile(s.partPath(id), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return session, fmt.Errorf("failed to open upload file: %v", err)
+	}
+	defer file.Close()
+
+	// Read one byte past the remaining size so oversize chunks are detected
+	remaining := session.Size - session.Offset
+	written, err := io.Copy(file, io.LimitReader(chunk, remaining+1))
+	if written > remaining {
+		file.Truncate(session.Size)
+		session.Offset = session.Size
+		return session, ErrUploadTooLarge
+	}
+	session.Offset += written
+	if err != nil {
+		return session, fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	return session, nil
+}
+
+// Finalize turns a completed upload into a video once. Under the session lock it
+// calls create, which creates the video and returns its ID and file path, moves the
+// upload there and records the video on the session. Later calls return the session
+// with created false and create is not called again.
+func (s *UploadService) Finalize(id string, create func(session *UploadSession) (videoID string, destPath string, err error)) (*UploadSession, bool, error) {
+	defer s.lockSession(id)()
+
+	session, err := s.loadSession(id)
+	if err != nil {
+		return nil, false, err
+	}
+	if session.VideoID != "" {
+		return session, false, nil
+	}
+	if !session.Complete() {
+		return session, false, fmt.Errorf("upload incomplete: %d of %d bytes", session.Offset, session.Size)
+	}
+
+	videoID, destPath, err := create(session)
+	if err != nil {
+		return session, false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return session, false, fmt.Errorf("failed to create destination directory: %v", err)
+	}
+	if err := os.Rename(s.partPath(id), destPath); err != nil {
+		return session, false, fmt.Errorf("failed to move upload: %v", err)
+	}
+
+	session.VideoID = videoID
+	if err := s.saveSession(session); err != nil {
+		return session, true, fmt.Errorf("failed to record upload video: %v", err)
+	}
+	return session, true, nil
+}
+
+// Cancel discards an upload session and its partial data
+func (s *UploadService) Cancel(id string) error {
+	defer s.lockSession(id)()
+
+	if _, err := s.loadSession(id); err != nil {
+		return err
+	}
+
+	os.Remove(s.partPath(id))
+	s.forgetSession(id)
+	return os.Remove(s.sessionPath(id))
+}
+
+func (s *UploadService) loadSession(id string) (*UploadSession, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrUploadNotFound
+	}
+
+	data, err := os.ReadFile(s.sessionPath(id))
+	if os.IsNotExist(err) {
+		return nil, ErrUploadNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var session UploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse upload session: %v", err)
+	}
+	if session.VideoID != "" {
+		// Finalized: the file now belongs to the video
+		session.Offset = session.Size
+		return &session, nil
+	}
+
+	info, err := os.Stat(s.partPath(id))
+	if err != nil {
+		return nil, ErrUploadNotFound
+	}
+	session.Offset = info.Size()
+
+	return &session, nil
+}
+
+func (s *UploadService) saveSession(session *UploadSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.sessionPath(session.ID), data, 0644)
+}
+
+func (s *UploadService) partPath(id string) string {
+	return filepath.Join(s.dir, id+".part")
+}
+
+func (s *UploadService) sessionPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"shortgenerator/models"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newTestUpload(t *testing.T, data string) (*UploadService, *UploadSession) {
+	t.Helper()
+	service := &UploadService{dir: t.TempDir(), maxSize: 1024, locks: make(map[string]*sync.Mutex)}
+	session, err := service.CreateSession("talk.mp4", "Talk", int64(len(data)), models.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.AppendChunk(session.ID, 0, strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	return service, session
+}
+
+func TestFinalizeCreatesOneVideo(t *testing.T) {
+	service, session := newTestUpload(t, "data")
+	dest := filepath.Join(t.TempDir(), "videos", "video-1.mp4")
+
+	var mu sync.Mutex
+	calls := 0
+	create := func(*UploadSession) (string, string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return "video-1", dest, nil
+	}
+
+	// Two requests finishing the same upload at once
+	var wg sync.WaitGroup
+	created := make([]bool, 2)
+	videoIDs := make([]string, 2)
+	for i := range created {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			finalized, ok, err := service.Finalize(session.ID, create)
+			if err != nil {
+				t.Errorf("Finalize() error = %v", err)
+				return
+			}
+			created[i], videoIDs[i] = ok, finalized.VideoID
+		}(i)
+	}
+	wg.Wait()
+
+	if calls != 1 || created[0] == created[1] {
+		t.Errorf("create called %d times, created = %v; want one video", calls, created)
+	}
+	if videoIDs[0] != "video-1" || videoIDs[1] != "video-1" {
+		t.Errorf("video IDs = %v, want video-1 for both", videoIDs)
+	}
+	if data, err := os.ReadFile(dest); err != nil || string(data) != "data" {
+		t.Errorf("moved file = %q, %v", data, err)
+	}
+
+	// A retried last chunk sees the finished upload
+	retried, err := service.AppendChunk(session.ID, 4, strings.NewReader(""))
+	if err != nil || retried.VideoID != "video-1" || !retried.Complete() {
+		t.Errorf("retried chunk = %+v, %v", retried, err)
+	}
+	if _, err := service.AppendChunk(session.ID, 4, strings.NewReader("more")); !errors.Is(err, ErrUploadTooLarge) {
+		t.Errorf("extra bytes after the end: error = %v, want ErrUploadTooLarge", err)
+	}
+}
+
+func TestFinalizeKeepsSessionWhenCreateFails(t *testing.T) {
+	service, session := newTestUpload(t, "data")
+	dest := filepath.Join(t.TempDir(), "video-2.mp4")
+
+	failure := errors.New("database is locked")
+	if _, created, err := service.Finalize(session.ID, func(*UploadSession) (string, string, error) {
+		return "", "", failure
+	}); created || !errors.Is(err, failure) {
+		t.Fatalf("Finalize() = %v, %v; want the create error", created, err)
+	}
+
+	// The upload is still there to finalize again
+	finalized, created, err := service.Finalize(session.ID, func(*UploadSession) (string, string, error) {
+		return "video-2", dest, nil
+	})
+	if err != nil || !created || finalized.VideoID != "video-2" {
+		t.Errorf("second Finalize() = %+v, %v, %v", finalized, created, err)
+	}
+}
+
+func TestFinalizeIncompleteUpload(t *testing.T) {
+	service, session := newTestUpload(t, "da")
+	session.Size = 4
+	if err := service.saveSession(session); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, created, err := service.Finalize(session.ID, func(*UploadSession) (string, string, error) {
+		t.Error("create called for an incomplete upload")
+		return "", "", nil
+	}); created || err == nil {
+		t.Errorf("Finalize() = %v, %v; want an error", created, err)
+	}
+}
+
+func TestAppendChunkUnreadableSession(t *testing.T) {
+	service, session := newTestUpload(t, "da")
+	if err := os.WriteFile(service.sessionPath(session.ID), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := service.AppendChunk(session.ID, 2, strings.NewReader("ta"))
+	if err == nil || got != nil {
+		t.Errorf("AppendChunk() = %+v, %v; want no session and an error", got, err)
+	}
+}
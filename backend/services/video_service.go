@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"shortgenerator/models"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,18 +18,39 @@ func NewVideoService(db *sql.DB) *VideoService {
 	return &VideoService{db: db}
 }
 
-func (s *VideoService) CreateVideo(url string) (*models.Video, error) {
+// videoColumns is the SELECT list matching scanVideo
+const videoColumns = `id, url, COALESCE(source_type, 'url'), COALESCE(title, ''), COALESCE(duration, 0), COALESCE(file_path, ''),
+			  COALESCE(thumbnail_url, ''), status, COALESCE(output_language, ''), COALESCE(platform, ''),
+			  COALESCE(prompt_template, ''), created_at, updated_at`
+
+func scanVideo(row interface{ Scan(...interface{}) error }, video *models.Video) error {
+	return row.Scan(
+		&video.ID, &video.URL, &video.SourceType, &video.Title, &video.Duration,
+		&video.FilePath, &video.ThumbnailURL, &video.Status,
+		&video.OutputLanguage, &video.Platform, &video.PromptTemplate,
+		&video.CreatedAt, &video.UpdatedAt,
+	)
+}
+
+func (s *VideoService) CreateVideo(url string, sourceType string, options models.AnalysisOptions) (*models.Video, error) {
+	if sourceType == "" {
+		sourceType = models.SourceTypeURL
+	}
+
 	video := &models.Video{
-		ID:        uuid.New().String(),
-		URL:       url,
-		Status:    "pending",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:              uuid.New().String(),
+		URL:             url,
+		SourceType:      sourceType,
+		Status:          "pending",
+		AnalysisOptions: options,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
 	}
 
-	query := `INSERT INTO videos (id, url, status, created_at, updated_at) 
-			  VALUES (?, ?, ?, ?, ?)`
-	_, err := s.db.Exec(query, video.ID, video.URL, video.Status, video.CreatedAt, video.UpdatedAt)
+	query := `INSERT INTO videos (id, url, source_type, status, output_language, platform, prompt_template, created_at, updated_at) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.db.Exec(query, video.ID, video.URL, video.SourceType, video.Status,
+		video.OutputLanguage, video.Platform, video.PromptTemplate, video.CreatedAt, video.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -38,14 +60,10 @@ func (s *VideoService) CreateVideo(url string) (*models.Video, error) {
 
 func (s *VideoService) GetVideo(id string) (*models.Video, error) {
 	video := &models.Video{}
-	query := `SELECT id, url, title, duration, file_path, thumbnail_url, status, created_at, updated_at 
+	query := `SELECT ` + videoColumns + `
 			  FROM videos WHERE id = ?`
 	
-	err := s.db.QueryRow(query, id).Scan(
-		&video.ID, &video.URL, &video.Title, &video.Duration,
-		&video.FilePath, &video.ThumbnailURL, &video.Status,
-		&video.CreatedAt, &video.UpdatedAt,
-	)
+	err := scanVideo(s.db.QueryRow(query, id), video)
 	if err != nil {
 		return nil, err
 	}
@@ -54,7 +72,7 @@ func (s *VideoService) GetVideo(id string) (*models.Video, error) {
 }
 
 func (s *VideoService) GetAllVideos() ([]models.Video, error) {
-	query := `SELECT id, url, title, duration, file_path, thumbnail_url, status, created_at, updated_at 
+	query := `SELECT ` + videoColumns + `
 			  FROM videos ORDER BY created_at DESC`
 	
 	rows, err := s.db.Query(query)
@@ -66,12 +84,40 @@ func (s *VideoService) GetAllVideos() ([]models.Video, error) {
 	videos := []models.Video{}
 	for rows.Next() {
 		var video models.Video
-		err := rows.Scan(
-			&video.ID, &video.URL, &video.Title, &video.Duration,
-			&video.FilePath, &video.ThumbnailURL, &video.Status,
-			&video.CreatedAt, &video.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanVideo(rows, &video); err != nil {
+			return nil, err
+		}
+		videos = append(videos, video)
+	}
+
+	return videos, nil
+}
+
+// GetVideosByStatus returns the videos whose status is one of the given values, oldest first
+func (s *VideoService) GetVideosByStatus(statuses ...string) ([]models.Video, error) {
+	if len(statuses) == 0 {
+		return []models.Video{}, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+
+	query := `SELECT ` + videoColumns + `
+			  FROM videos WHERE status IN (` + placeholders + `) ORDER BY created_at ASC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	videos := []models.Video{}
+	for rows.Next() {
+		var video models.Video
+		if err := scanVideo(rows, &video); err != nil {
 			return nil, err
 		}
 		videos = append(videos, video)
@@ -135,12 +181,16 @@ func (s *VideoService) SaveSuggestedClips(clips []models.SuggestedClip) error {
 	}
 	defer tx.Rollback()
 
-	query := `INSERT INTO suggested_clips (id, video_id, start_time, end_time, title, description, score, reason)
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO suggested_clips (id, video_id, start_time, end_time, title, description, score, reason, prompt_version, segments)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	
 	for _, clip := range clips {
-		_, err := tx.Exec(query, clip.ID, clip.VideoID, clip.StartTime, clip.EndTime,
-			clip.Title, clip.Description, clip.Score, clip.Reason)
+		segmentsJSON, err := json.Marshal(clip.Segments)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(query, clip.ID, clip.VideoID, clip.StartTime, clip.EndTime,
+			clip.Title, clip.Description, clip.Score, clip.Reason, clip.PromptVersion, string(segmentsJSON))
 		if err != nil {
 			return err
 		}
@@ -150,7 +200,8 @@ func (s *VideoService) SaveSuggestedClips(clips []models.SuggestedClip) error {
 }
 
 func (s *VideoService) GetSuggestedClips(videoID string) ([]models.SuggestedClip, error) {
-	query := `SELECT id, video_id, start_time, end_time, title, description, score, reason
+	query := `SELECT id, video_id, start_time, end_time, title, description, score, reason, COALESCE(prompt_version, ''),
+			  COALESCE(segments, '[]')
 			  FROM suggested_clips WHERE video_id = ? ORDER BY score DESC`
 	
 	rows, err := s.db.Query(query, videoID)
@@ -162,13 +213,78 @@ func (s *VideoService) GetSuggestedClips(videoID string) ([]models.SuggestedClip
 	clips := []models.SuggestedClip{}
 	for rows.Next() {
 		var clip models.SuggestedClip
+		var segmentsJSON string
 		err := rows.Scan(&clip.ID, &clip.VideoID, &clip.StartTime, &clip.EndTime,
-			&clip.Title, &clip.Description, &clip.Score, &clip.Reason)
+			&clip.Title, &clip.Description, &clip.Score, &clip.Reason, &clip.PromptVersion, &segmentsJSON)
 		if err != nil {
 			return nil, err
 		}
+		if err := json.Unmarshal([]byte(segmentsJSON), &clip.Segments); err != nil {
+			return nil, err
+		}
 		clips = append(clips, clip)
 	}
 
 	return clips, nil
 }
+
+// UpdateSuggestedClipTimes stores new start and end times for existing suggestions
+func (s *VideoService) UpdateSuggestedClipTimes(clips []models.SuggestedClip) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, clip := range clips {
+		if _, err := tx.Exec(`UPDATE suggested_clips SET start_time = ?, end_time = ? WHERE id = ?`,
+			clip.StartTime, clip.EndTime, clip.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SaveScenes replaces the detected shots of a video
+func (s *VideoService) SaveScenes(videoID string, scenes []models.Scene) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM scenes WHERE video_id = ?`, videoID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO scenes (video_id, idx, start_time, end_time, score) VALUES (?, ?, ?, ?, ?)`
+	for _, scene := range scenes {
+		if _, err := tx.Exec(query, videoID, scene.Index, scene.StartTime, scene.EndTime, scene.Score); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetScenes returns the detected shots of a video in order
+func (s *VideoService) GetScenes(videoID string) ([]models.Scene, error) {
+	rows, err := s.db.Query(`SELECT idx, start_time, end_time, COALESCE(score, 0)
+			  FROM scenes WHERE video_id = ? ORDER BY idx`, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scenes := []models.Scene{}
+	for rows.Next() {
+		var scene models.Scene
+		if err := rows.Scan(&scene.Index, &scene.StartTime, &scene.EndTime, &scene.Score); err != nil {
+			return nil, err
+		}
+		scenes = append(scenes, scene)
+	}
+
+	return scenes, rows.Err()
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"shortgenerator/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// analysisWindow is a time slice of the transcript analyzed on its own
+type analysisWindow struct {
+	index    int
+	start    float64
+	end      float64
+	segments []models.Segment
+}
+
+// analysisConfig controls when and how long transcripts are split for analysis
+type analysisConfig struct {
+	windowSeconds  float64
+	overlapSeconds float64
+	concurrency    int
+	maxClips       int
+}
+
+func loadAnalysisConfig() analysisConfig {
+	config := analysisConfig{windowSeconds: 900, overlapSeconds: 90, concurrency: 2, maxClips: 8}
+
+	if v, err := strconv.ParseFloat(getEnv("ANALYSIS_WINDOW_SECONDS", "900"), 64); err == nil && v >= 120 {
+		config.windowSeconds = v
+	}
+	if v, err := strconv.ParseFloat(getEnv("ANALYSIS_WINDOW_OVERLAP_SECONDS", "90"), 64); err == nil && v >= 0 && v < config.windowSeconds/2 {
+		config.overlapSeconds = v
+	}
+	if v, err := strconv.Atoi(getEnv("ANALYSIS_CONCURRENCY", "2")); err == nil && v > 0 {
+		config.concurrency = v
+	}
+	if v, err := strconv.Atoi(getEnv("ANALYSIS_MAX_CLIPS", "8")); err == nil && v > 0 {
+		config.maxClips = v
+	}
+
+	return config
+}
+
+// transcriptDuration is the end time of the last segment
+func transcriptDuration(transcript *models.Transcript) float64 {
+	if len(transcript.Segments) == 0 {
+		return 0
+	}
+	return transcript.Segments[len(transcript.Segments)-1].End
+}
+
+// formatTimestampedSegments renders one "[start - end] text" line per segment, in seconds
+func formatTimestampedSegments(segments []models.Segment) string {
+	var b strings.Builder
+	for _, seg := range segments {
+		fmt.Fprintf(&b, "[%.1f - %.1f] %s\n", seg.Start, seg.End, seg.Text)
+	}
+	return b.String()
+}
+
+// splitAnalysisWindows cuts segments into windows of windowSeconds that overlap by
+// overlapSeconds, so a clip near a window edge is fully visible in at least one window
+func splitAnalysisWindows(segments []models.Segment, windowSeconds, overlapSeconds float64) []analysisWindow {
+	windows := []analysisWindow{}
+	if len(segments) == 0 {
+		return windows
+	}
+
+	duration := segments[len(segments)-1].End
+	step := windowSeconds - overlapSeconds
+
+	for start := 0.0; start < duration; start += step {
+		end := start + windowSeconds
+		window := analysisWindow{index: len(windows), start: start, end: minFloat(end, duration)}
+		for _, seg := range segments {
+			if seg.End > start && seg.Start < end {
+				window.segments = append(window.segments, seg)
+			}
+		}
+		if len(window.segments) > 0 {
+			windows = append(windows, window)
+		}
+		if end >= duration {
+			break
+		}
+	}
+
+	return windows
+}
+
+// analyzeWindowed is the map-reduce path for long transcripts: every window yields a
+// few candidates, which are refined, merged when they overlap and re-ranked globally
+func (s *ProcessingService) analyzeWindowed(transcript *models.Transcript, videoDuration float64, sceneCuts []float64, config analysisConfig, tmpl *PromptTemplate, vars PromptVars) ([]models.SuggestedClip, error) {
+	windows := splitAnalysisWindows(transcript.Segments, config.windowSeconds, config.overlapSeconds)
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("transcript has no segments to analyze")
+	}
+	log.Printf("🪟 Analyzing %.0fs transcript in %d windows of %.0fs", transcriptDuration(transcript), len(windows), config.windowSeconds)
+
+	results := make([][]models.SuggestedClip, len(windows))
+	errs := make([]error, len(windows))
+	sem := make(chan struct{}, config.concurrency)
+	var wg sync.WaitGroup
+
+	for _, window := range windows {
+		wg.Add(1)
+		go func(window analysisWindow) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[window.index], errs[window.index] = s.analyzeWindow(window, len(windows), tmpl, vars)
+		}(window)
+	}
+	wg.Wait()
+
+	candidates, failed, err := collectWindowCandidates(results, errs)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := refineSuggestedClips(candidates, transcript, videoDuration, sceneCuts)
+	log.Printf("🔀 %d candidates from %d windows merged into %d", len(candidates), len(windows)-failed, len(merged))
+
+	return s.rerankCandidates(merged, config.maxClips, vars), nil
+}
+
+// collectWindowCandidates gathers the candidates of the windows that answered and
+// counts the failed ones. It errors when there is no candidate at all, telling
+// windows that all failed apart from windows that answered without clips.
+func collectWindowCandidates(results [][]models.SuggestedClip, errs []error) ([]models.SuggestedClip, int, error) {
+	candidates := []models.SuggestedClip{}
+	failed := 0
+	var firstErr error
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			log.Printf("⚠️  Analysis window %d/%d failed: %v", i+1, len(errs), err)
+			continue
+		}
+		candidates = append(candidates, results[i]...)
+	}
+
+	switch {
+	case len(candidates) > 0:
+		return candidates, failed, nil
+	case failed > 0 && failed == len(errs):
+		return nil, failed, fmt.Errorf("all %d analysis windows failed: %v", failed, firstErr)
+	case failed > 0:
+		return nil, failed, fmt.Errorf("no clip candidates in %d analysis windows, %d failed: %v", len(errs)-failed, failed, firstErr)
+	default:
+		return nil, failed, fmt.Errorf("no clip candidates in %d analysis windows", len(errs))
+	}
+}
+
+func (s *ProcessingService) analyzeWindow(window analysisWindow, total int, tmpl *PromptTemplate, vars PromptVars) ([]models.SuggestedClip, error) {
+	vars.ClipCount = "2-4"
+	vars.Transcript = formatTimestampedSegments(window.segments)
+	vars.Window = &PromptWindow{Index: window.index + 1, Count: total, Start: window.start, End: window.end}
+
+	request, err := tmpl.Render(vars)
+	if err != nil {
+		return nil, err
+	}
+	request.Temperature = 0.7
+	request.MaxTokens = 2000
+
+	content, err := completeWithRetry(s.llm, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// A window without a good moment may answer with no clips
+	clips, err := decodeSuggestedClips(s.llm.Name(), content)
+	if err != nil {
+		return nil, err
+	}
+
+	// Discard answers that ignored the window, they are guesses
+	inWindow := clips[:0]
+	for _, clip := range clips {
+		if clip.EndTime > clip.StartTime && clip.StartTime >= window.start-1 && clip.EndTime <= window.end+1 {
+			inWindow = append(inWindow, clip)
+		}
+	}
+
+	return inWindow, nil
+}
+
+// clipOverlapRatio is the shared duration of two clips relative to the shorter one.
+// Multi-part clips only count the ranges they play.
+func clipOverlapRatio(a, b models.SuggestedClip) float64 {
+	rangesA := SourceRanges(a.StartTime, a.EndTime, a.Segments)
+	rangesB := SourceRanges(b.StartTime, b.EndTime, b.Segments)
+
+	overlap := 0.0
+	for _, ra := range rangesA {
+		for _, rb := range rangesB {
+			overlap += maxFloat(0, minFloat(ra.EndTime, rb.EndTime)-maxFloat(ra.StartTime, rb.StartTime))
+		}
+	}
+	shorter := minFloat(rangesDuration(rangesA), rangesDuration(rangesB))
+	if overlap <= 0 || shorter <= 0 {
+		return 0
+	}
+	return overlap / shorter
+}
+
+// mergeCandidateClips keeps the best scored clip of every group of candidates that
+// cover mostly the same moment (typically found twice in overlapping windows)
+func mergeCandidateClips(candidates []models.SuggestedClip) []models.SuggestedClip {
+	sorted := append([]models.SuggestedClip(nil), candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+
+	merged := []models.SuggestedClip{}
+	for _, candidate := range sorted {
+		duplicate := false
+		for _, kept := range merged {
+			if clipOverlapRatio(candidate, kept) > 0.5 {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			merged = append(merged, candidate)
+		}
+	}
+
+	return merged
+}
+
+// rerankCandidates asks the model to score the merged candidates against each other,
+// since scores from separate windows were given without seeing the rest of the video.
+// If the call fails the per-window scores are kept.
+func (s *ProcessingService) rerankCandidates(candidates []models.SuggestedClip, maxClips int, vars PromptVars) []models.SuggestedClip {
+	if len(candidates) > 1 {
+		if err := s.rerankWithLLM(candidates, vars); err != nil {
+			log.Printf("⚠️  Global re-ranking failed, keeping window scores: %v", err)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	if len(candidates) > maxClips {
+		candidates = candidates[:maxClips]
+	}
+
+	return candidates
+}
+
+func (s *ProcessingService) rerankWithLLM(candidates []models.SuggestedClip, vars PromptVars) error {
+	tmpl, err := s.prompts.Get(PromptClipRerank)
+	if err != nil {
+		return err
+	}
+
+	var list strings.Builder
+	for i, clip := range candidates {
+		parts := []string{}
+		for _, r := range SourceRanges(clip.StartTime, clip.EndTime, clip.Segments) {
+			parts = append(parts, fmt.Sprintf("%.1f - %.1f", r.StartTime, r.EndTime))
+		}
+		fmt.Fprintf(&list, "%d. [%s] %s — %s (%s)\n", i, strings.Join(parts, " + "), clip.Title, clip.Description, clip.Reason)
+	}
+	vars.Candidates = list.String()
+
+	request, err := tmpl.Render(vars)
+	if err != nil {
+		return err
+	}
+	request.Temperature = 0.2
+	request.MaxTokens = 1000
+
+	content, err := completeWithRetry(s.llm, request)
+	if err != nil {
+		return err
+	}
+
+	var scores []struct {
+		Index int     `json:"index"`
+		Score float64 `json:"score"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(content)), &scores); err != nil {
+		return fmt.Errorf("failed to parse re-ranking response: %v", err)
+	}
+
+	for _, item := range scores {
+		if item.Index >= 0 && item.Index < len(candidates) {
+			candidates[item.Index].Score = item.Score
+		}
+	}
+
+	return nil
+}
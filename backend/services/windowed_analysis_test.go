@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"shortgenerator/models"
+	"testing"
+)
+
+func TestCollectWindowCandidates(t *testing.T) {
+	clip := models.SuggestedClip{StartTime: 10, EndTime: 40}
+	timeout := errors.New("timeout")
+
+	tests := []struct {
+		name       string
+		results    [][]models.SuggestedClip
+		errs       []error
+		wantCount  int
+		wantFailed int
+		wantErr    string
+	}{
+		{
+			name:      "every window answered",
+			results:   [][]models.SuggestedClip{{clip}, {clip, clip}},
+			errs:      []error{nil, nil},
+			wantCount: 3,
+		},
+		{
+			name:       "some windows failed",
+			results:    [][]models.SuggestedClip{nil, {clip}},
+			errs:       []error{timeout, nil},
+			wantCount:  1,
+			wantFailed: 1,
+		},
+		{
+			name:       "all failed",
+			results:    [][]models.SuggestedClip{nil, nil},
+			errs:       []error{timeout, timeout},
+			wantFailed: 2,
+			wantErr:    "all 2 analysis windows failed: timeout",
+		},
+		{
+			name:    "answers without clips",
+			results: [][]models.SuggestedClip{{}, {}},
+			errs:    []error{nil, nil},
+			wantErr: "no clip candidates in 2 analysis windows",
+		},
+		{
+			name:       "answers without clips and a failure",
+			results:    [][]models.SuggestedClip{{}, nil, {}},
+			errs:       []error{nil, timeout, nil},
+			wantFailed: 1,
+			wantErr:    "no clip candidates in 2 analysis windows, 1 failed: timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, failed, err := collectWindowCandidates(tt.results, tt.errs)
+			if failed != tt.wantFailed {
+				t.Errorf("failed = %d, want %d", failed, tt.wantFailed)
+			}
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || len(candidates) != tt.wantCount {
+				t.Errorf("got %d candidates, %v; want %d", len(candidates), err, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestAnalyzeWindowedConcurrently(t *testing.T) {
+	backoff := llmRetryBackoff
+	llmRetryBackoff = 0
+	t.Cleanup(func() { llmRetryBackoff = backoff })
+
+	transcript := &models.Transcript{}
+	for start := 0.0; start < 300; start += 10 {
+		transcript.Segments = append(transcript.Segments, models.Segment{
+			Start: start, End: start + 10, Text: fmt.Sprintf("Frase número %.0f.", start/10),
+		})
+	}
+
+	// The canned clips only fit the first window, the others answer outside theirs
+	provider := &fakeLLMProvider{}
+	service := &ProcessingService{llm: provider, prompts: NewPromptLibrary(t.TempDir())}
+	tmpl, err := service.prompts.Get(PromptClipAnalysis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := analysisConfig{windowSeconds: 100, overlapSeconds: 10, concurrency: 3, maxClips: 8}
+
+	clips, err := service.analyzeWindowed(transcript, 300, nil, config, tmpl, service.basePromptVars(&models.Video{}))
+	if err != nil {
+		t.Fatalf("analyzeWindowed() error = %v", err)
+	}
+	if len(clips) != 2 {
+		t.Errorf("got %d clips, want the 2 from the first window: %+v", len(clips), clips)
+	}
+	// Four windows plus the global re-ranking
+	if provider.calls != 5 {
+		t.Errorf("%d LLM calls, want 5", provider.calls)
+	}
+}
+
+func TestAnalyzeWindowWithoutClips(t *testing.T) {
+	service := &ProcessingService{llm: &fakeLLMProvider{Response: "[]"}, prompts: NewPromptLibrary(t.TempDir())}
+	tmpl, err := service.prompts.Get(PromptClipAnalysis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	window := analysisWindow{start: 0, end: 60, segments: []models.Segment{{Start: 0, End: 60, Text: "Hola."}}}
+
+	clips, err := service.analyzeWindow(window, 1, tmpl, service.basePromptVars(&models.Video{}))
+	if err != nil || len(clips) != 0 {
+		t.Errorf("analyzeWindow() = %+v, %v; want no clips and no error", clips, err)
+	}
+}
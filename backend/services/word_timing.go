@@ -0,0 +1,74 @@
+package services
+
+import (
+	"math"
+	"shortgenerator/models"
+	"strings"
+)
+
+// AttachWordTimings fills SubtitleConfig.Words from the transcript for subtitles that
+// did not bring their own. Subtitle times are clip time, the source ranges of the clip
+// played back to back; transcript words are absolute. Timings are only attached when
+// the word count matches the subtitle text, so edited captions fall back to the
+// approximate karaoke fade.
+func AttachWordTimings(subtitles []models.SubtitleConfig, transcript *models.Transcript, ranges []models.ClipSegment) {
+	if transcript == nil {
+		return
+	}
+
+	var words []models.Word
+	for _, segment := range transcript.Segments {
+		words = append(words, segment.Words...)
+	}
+	if len(words) == 0 {
+		return
+	}
+
+	for i := range subtitles {
+		sub := &subtitles[i]
+		if len(sub.Words) > 0 {
+			continue
+		}
+
+		// The source range the subtitle starts in; clipStart maps its clip time to
+		// source time
+		var source *models.ClipSegment
+		clipStart, offset := 0.0, 0.0
+		for j := range ranges {
+			duration := ranges[j].EndTime - ranges[j].StartTime
+			if sub.StartTime < offset+duration {
+				source, clipStart = &ranges[j], ranges[j].StartTime-offset
+				break
+			}
+			offset += duration
+		}
+		if source == nil {
+			continue
+		}
+
+		lineWords := strings.Fields(sub.Text)
+		from := clipStart + sub.StartTime
+		to := math.Min(clipStart+sub.EndTime, source.EndTime)
+
+		matched := []models.Word{}
+		for _, word := range words {
+			mid := (word.Start + word.End) / 2
+			if mid >= from && mid < to {
+				matched = append(matched, word)
+			}
+		}
+
+		if len(matched) == 0 || len(matched) != len(lineWords) {
+			continue
+		}
+
+		sub.Words = make([]models.Word, len(matched))
+		for j, word := range matched {
+			sub.Words[j] = models.Word{
+				Start: word.Start - clipStart,
+				End:   word.End - clipStart,
+				Text:  lineWords[j],
+			}
+		}
+	}
+}
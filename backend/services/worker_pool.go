@@ -0,0 +1,97 @@
+package services
+
+import (
+	"log"
+	"sync"
+)
+
+// WorkerPool runs queued tasks in FIFO order with a fixed number of workers.
+// Enqueue never blocks, so HTTP handlers can hand off work immediately.
+type WorkerPool struct {
+	name    string
+	workers int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []func()
+	running int
+	started bool
+}
+
+func NewWorkerPool(name string, workers int) *WorkerPool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	pool := &WorkerPool{
+		name:    name,
+		workers: workers,
+	}
+	pool.cond = sync.NewCond(&pool.mu)
+
+	return pool
+}
+
+// Start launches the worker goroutines. Calling it more than once is a no-op.
+func (p *WorkerPool) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started {
+		return
+	}
+	p.started = true
+
+	for i := 0; i < p.workers; i++ {
+		go p.work()
+	}
+
+	log.Printf("⚙️  Worker pool %q started with %d workers", p.name, p.workers)
+}
+
+// Enqueue adds a task to the back of the queue
+func (p *WorkerPool) Enqueue(task func()) {
+	p.mu.Lock()
+	p.pending = append(p.pending, task)
+	p.mu.Unlock()
+
+	p.cond.Signal()
+}
+
+// Stats returns the number of queued and currently running tasks
+func (p *WorkerPool) Stats() (queued int, running int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.pending), p.running
+}
+
+func (p *WorkerPool) work() {
+	for {
+		p.mu.Lock()
+		for len(p.pending) == 0 {
+			p.cond.Wait()
+		}
+		task := p.pending[0]
+		p.pending[0] = nil
+		p.pending = p.pending[1:]
+		p.running++
+		p.mu.Unlock()
+
+		p.run(task)
+
+		p.mu.Lock()
+		p.running--
+		p.mu.Unlock()
+	}
+}
+
+func (p *WorkerPool) run(task func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ Worker pool %q task panicked: %v", p.name, r)
+		}
+	}()
+
+	task()
+}
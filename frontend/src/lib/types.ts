@@ -1,6 +1,7 @@
 export interface Video {
   id: string;
   url: string;
+  source_type: "url" | "upload";
   title: string;
   duration: number;
   file_path: string;
@@ -8,18 +9,30 @@ export interface Video {
   status:
     | "pending"
     | "downloading"
+    | "probing"
+    | "detecting_scenes"
     | "transcribing"
     | "analyzing"
     | "completed"
     | "error";
+  output_language: string;
+  platform: string;
+  prompt_template: string;
   created_at: string;
   updated_at: string;
 }
 
+export interface Word {
+  start: number;
+  end: number;
+  text: string;
+}
+
 export interface Segment {
   start: number;
   end: number;
   text: string;
+  words?: Word[];
 }
 
 export interface Transcript {
@@ -40,6 +53,22 @@ export interface SuggestedClip {
   description: string;
   score: number;
   reason: string;
+  prompt_version: string;
+  segments?: ClipSegment[]; // clip en varias partes; start_time/end_time abarcan todas
+}
+
+// Rango del video que forma una parte de un clip
+export interface ClipSegment {
+  start_time: number;
+  end_time: number;
+}
+
+// Plano detectado entre dos cortes (GET /api/videos/:id/scenes)
+export interface Scene {
+  index: number;
+  start_time: number;
+  end_time: number;
+  score: number;
 }
 
 export interface SubtitleConfig {
@@ -71,6 +100,8 @@ export interface SubtitleConfig {
     | "elastic"
     | "spring";
   active_text_color: string;
+  // Tiempos por palabra relativos al clip (karaoke exacto en el backend)
+  words?: Word[];
   // Campos opcionales para la sincronización con la transcripción
   segmentId?: number;
   originalStart?: number;
@@ -90,8 +121,129 @@ export interface Clip {
   start_time: number;
   end_time: number;
   file_path: string;
-  status: "processing" | "completed" | "error";
+  status: "queued" | "processing" | "completed" | "error";
+  error?: string;
   subtitles: SubtitleConfig[];
+  segments?: ClipSegment[]; // partes en orden de reproducción
+  preview_path?: string; // GIF o WebP, si se pidió preview
   created_at: string;
   completed_at?: string;
+  subtitle_renderer?: "drawtext" | "ass";
+  aspect_ratio?: "9:16" | "4:5" | "1:1" | "16:9";
+  resolution?: number;
+  framing?: "crop" | "letterbox" | "blur";
+  reframe?: boolean;
+  loudness?: "tiktok" | "youtube_shorts" | "instagram_reels" | "youtube" | "podcast" | "broadcast";
+  trim_silence?: boolean;
+  max_pause?: number; // segundos; 0 conserva las pausas
+  jump_cut?: boolean; // quita muletillas y pausas
+  brand_kit_id?: string; // logo, intro y outro del kit
+  music_id?: string; // música de fondo con ducking
+  music_start?: number; // segundos desde el inicio de la pista
+  music_volume?: number; // 0 a 1, 0.25 por defecto
+  headline?: HeadlineOverlay;
+  progress_bar?: ProgressBarOverlay;
+  preview?: PreviewOptions;
+}
+
+// Vista previa animada en bucle y sin sonido, junto al MP4
+export interface PreviewOptions {
+  format?: "gif" | "webp"; // gif por defecto
+  width?: number; // píxeles, 480 por defecto
+  fps?: number; // 12 por defecto
+  subtitles?: boolean; // quemar los subtítulos
+}
+
+// Titular fijo durante todo el clip; tamaños en píxeles del lienzo del editor
+export interface HeadlineOverlay {
+  text: string;
+  font_family?: string;
+  font_size?: number; // 28 por defecto
+  font_weight?: number; // 800 por defecto
+  italic?: boolean;
+  color?: string;
+  bg_color?: string; // caja detrás de cada línea
+  bg_opacity?: number;
+  position?: "top" | "center" | "bottom";
+}
+
+// Barra que se llena a lo largo del clip
+export interface ProgressBarOverlay {
+  color?: string;
+  bg_color?: string; // pista de fondo
+  bg_opacity?: number;
+  thickness?: number; // píxeles del lienzo, 4 por defecto
+  position?: "bottom" | "top";
+}
+
+export interface MusicTrack {
+  id: string;
+  name: string;
+  file_path: string;
+  duration: number; // segundos
+  created_at: string;
+}
+
+export interface BrandCard {
+  video_path: string;
+  image_path: string;
+  text?: string;
+  text_color?: string;
+  bg_color?: string;
+  duration?: number; // segundos, para imagen y texto
+}
+
+// Márgenes que tapa la interfaz de la plataforma, como fracción del cuadro
+export interface SafeZone {
+  top: number;
+  bottom: number;
+  left: number;
+  right: number;
+}
+
+export interface BrandKit {
+  id: string;
+  name: string;
+  logo_path: string;
+  logo_position: "top_left" | "top_right" | "bottom_left" | "bottom_right";
+  logo_opacity: number;
+  logo_width: number; // fracción del ancho del cuadro
+  intro?: BrandCard;
+  outro?: BrandCard;
+  safe_zone: SafeZone;
+  created_at: string;
+  updated_at: string;
+}
+
+// Fotograma candidato para la portada de un clip, o portada renderizada a partir de uno
+export interface Thumbnail {
+  id: string;
+  video_id: string;
+  clip_id?: string;
+  kind: "candidate" | "cover";
+  time: number; // segundo del video original
+  score: number; // 0 a 1, solo candidatas
+  width: number;
+  height: number;
+  title?: string; // texto dibujado en la portada
+  source_id?: string; // candidata de la que sale la portada
+  selected: boolean;
+  file_path: string;
+  url: string;
+  created_at: string;
+}
+
+// Mensaje "progress" del WebSocket de un video
+export interface ProgressUpdate {
+  video_id: string;
+  clip_id?: string;
+  stage: "download" | "scenes" | "extract_audio" | "transcribe" | "reframe" | "analyze_audio" | "render" | "thumbnails" | "preview";
+  percent: number;
+  eta_seconds?: number;
+  bytes?: number;
+  total_bytes?: number;
+  fps?: number;
+  speed?: string;
+  chunk?: number;
+  chunks?: number;
 }